@@ -3,14 +3,19 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
+	"fmt"
 	"meily/config"
 	"meily/internal/handler"
+	"meily/internal/i18n"
 	"meily/internal/repository"
+	"meily/internal/service"
 	"meily/traits/database"
 	"meily/traits/logger"
 	"os"
 	"os/signal"
 	"syscall"
+	_ "time/tzdata"
 
 	"github.com/go-telegram/bot"
 	_ "github.com/mattn/go-sqlite3"
@@ -18,11 +23,32 @@ import (
 )
 
 func main() {
+	backfillGeo := flag.Bool("backfill-geo", false, "resolve city and region for saved coordinates and exit")
+	forceBackfill := flag.Bool("force", false, "with -backfill-geo, also re-resolve rows that already have a region")
+	backfillPhones := flag.Bool("backfill-phones", false, "normalize saved phones to E.164, link accounts to customers and exit")
+	flowDiagram := flag.String("flow-diagram", "", "print the conversation flows as markdown (Mermaid) or dot and exit")
+	flag.Parse()
+
+	if *flowDiagram != "" {
+		diagram, err := handler.FlowDiagram(*flowDiagram)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Print(diagram)
+		return
+	}
+
 	zapLogger, err := logger.NewLogger()
 	if err != nil {
 		panic(err)
 	}
 
+	if err := i18n.Load(); err != nil {
+		zapLogger.Error("error loading translations", zap.Error(err))
+		return
+	}
+
 	cfg, err := config.NewConfig()
 	if err != nil {
 		zapLogger.Error("error initializing config", zap.Error(err))
@@ -41,6 +67,33 @@ func main() {
 		return
 	}
 
+	geocoder, err := service.NewReverseGeocoder(cfg.RegionBoundariesFile)
+	if err != nil {
+		zapLogger.Error("error loading geocoding data", zap.Error(err))
+		return
+	}
+	userRepo := repository.NewUserRepository(db)
+	userRepo.SetPlaceResolver(geocoder)
+
+	if *backfillGeo {
+		updated, err := userRepo.BackfillGeoPlaces(context.Background(), *forceBackfill)
+		if err != nil {
+			zapLogger.Error("error backfilling geo places", zap.Error(err))
+			return
+		}
+		zapLogger.Info("geo places backfilled", zap.Int("updated", updated))
+		return
+	}
+	if *backfillPhones {
+		updated, err := userRepo.BackfillPhones(context.Background())
+		if err != nil {
+			zapLogger.Error("error backfilling phones", zap.Error(err))
+			return
+		}
+		zapLogger.Info("phones backfilled", zap.Int("updated", updated))
+		return
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	redisClient, err := database.ConnectRedis(ctx, zapLogger)
 	if err != nil {
@@ -49,14 +102,34 @@ func main() {
 	}
 	defer database.CloseRedis(redisClient, zapLogger)
 
-	userRepo := repository.NewUserRepository(db)
 	redisRepo := repository.NewRedisRepository(redisClient)
 	handl := handler.NewHandler(cfg, zapLogger, ctx, userRepo, redisRepo)
+	if err := handl.LoadMessageTemplates(ctx); err != nil {
+		zapLogger.Error("error loading message templates", zap.Error(err))
+	}
+
+	addressGeocoder, err := service.NewAddressGeocoder()
+	if err != nil {
+		zapLogger.Error("error loading address gazetteer", zap.Error(err))
+		return
+	}
+	handl.SetAddressGeocoder(addressGeocoder)
+
+	// Without a gateway buyers are not offered SMS codes they could never receive
+	switch cfg.SMSGateway {
+	case config.SMSGatewayLog:
+		zapLogger.Warn("SMS codes are only written to the log")
+		handl.SetSMSGateway(service.NewLogSMSGateway(zapLogger))
+	}
 
 	opts := []bot.Option{
 		bot.WithDefaultHandler(handl.DefaultHandler),
 		bot.WithCallbackQueryDataHandler("buy_cosmetics", bot.MatchTypePrefix, handl.BuyCosmeticsCallbackHandler),
 		bot.WithCallbackQueryDataHandler("count_", bot.MatchTypePrefix, handl.CountHandler),
+		bot.WithCallbackQueryDataHandler("reminder_optout", bot.MatchTypeExact, handl.ReminderOptOutHandler),
+		bot.WithCallbackQueryDataHandler("phone_verify_sms", bot.MatchTypeExact, handl.PhoneVerifyCallbackHandler),
+		bot.WithCallbackQueryDataHandler("invoice_", bot.MatchTypePrefix, handl.InvoiceCallbackHandler),
+		bot.WithCallbackQueryDataHandler("bcast_", bot.MatchTypePrefix, handl.BroadcastCallbackHandler),
 
 		bot.WithMessageTextHandler("/admin", bot.MatchTypeExact, handl.AdminHandler),
 		bot.WithMessageTextHandler("💰 Ақша (Money)", bot.MatchTypeExact, handl.AdminHandler),
@@ -66,7 +139,57 @@ func main() {
 		bot.WithMessageTextHandler("📢 Хабарлама (Messages)", bot.MatchTypeExact, handl.AdminHandler),
 		bot.WithMessageTextHandler("🎁 Сыйлық (Gift)", bot.MatchTypeExact, handl.AdminHandler),
 		bot.WithMessageTextHandler("📊 Статистика (Statistics)", bot.MatchTypeExact, handl.AdminHandler),
+		bot.WithMessageTextHandler("🚚 Курьерлер (Couriers)", bot.MatchTypeExact, handl.AdminHandler),
+		bot.WithMessageTextHandler("📤 Экспорт (Export)", bot.MatchTypeExact, handl.AdminHandler),
 		bot.WithMessageTextHandler("❌ Жабу (Close)", bot.MatchTypeExact, handl.AdminHandler),
+
+		bot.WithMessageTextHandler("/addcourier", bot.MatchTypePrefix, handl.AdminCourierCommandHandler),
+		bot.WithMessageTextHandler("/delcourier", bot.MatchTypePrefix, handl.AdminCourierCommandHandler),
+		bot.WithMessageTextHandler("/assign", bot.MatchTypePrefix, handl.AdminCourierCommandHandler),
+		bot.WithMessageTextHandler("/courier", bot.MatchTypeExact, handl.CourierMenuHandler),
+		bot.WithMessageTextHandler("/route", bot.MatchTypeExact, handl.CourierRouteHandler),
+		bot.WithCallbackQueryDataHandler("courier_", bot.MatchTypePrefix, handl.CourierCallbackHandler),
+		bot.WithMessageTextHandler("/start pod_", bot.MatchTypePrefix, handl.CourierProofDeepLinkHandler),
+		bot.WithMessageTextHandler("/setstatus", bot.MatchTypePrefix, handl.AdminSetStatusHandler),
+		bot.WithMessageTextHandler("/export", bot.MatchTypePrefix, handl.AdminExportCommandHandler),
+		bot.WithCallbackQueryDataHandler("export_", bot.MatchTypePrefix, handl.ExportCallbackHandler),
+		bot.WithMessageTextHandler("/status", bot.MatchTypeExact, handl.StatusCommandHandler),
+		bot.WithMessageTextHandler("/tracking", bot.MatchTypeExact, handl.AdminTrackingDocumentHandler),
+		bot.WithPhotoCaptionHandler("/tracking", bot.MatchTypePrefix, handl.AdminTrackingDocumentHandler),
+		bot.WithMessageTextHandler("/language", bot.MatchTypeExact, handl.LanguageCommandHandler),
+		bot.WithCallbackQueryDataHandler("lang_", bot.MatchTypePrefix, handl.LanguageCallbackHandler),
+		bot.WithMessageTextHandler("/templates", bot.MatchTypeExact, handl.AdminTemplatesHandler),
+		bot.WithMessageTextHandler("/media", bot.MatchTypePrefix, handl.AdminMediaHandler),
+		bot.WithCallbackQueryDataHandler("tpl_", bot.MatchTypePrefix, handl.TemplateCallbackHandler),
+		bot.WithMessageTextHandler("/help", bot.MatchTypeExact, handl.HelpHandler),
+		bot.WithCallbackQueryDataHandler("support_open", bot.MatchTypeExact, handl.SupportCallbackHandler),
+		bot.WithMessageTextHandler("/tickets", bot.MatchTypeExact, handl.AdminTicketsHandler),
+		bot.WithMessageTextHandler("/admins", bot.MatchTypeExact, handl.AdminsCommandHandler),
+		bot.WithMessageTextHandler("/addadmin", bot.MatchTypePrefix, handl.AdminsCommandHandler),
+		bot.WithMessageTextHandler("/deladmin", bot.MatchTypePrefix, handl.AdminsCommandHandler),
+	}
+	// Courier menu buttons are shown in the courier's language, so every translation is routed
+	for _, button := range []struct {
+		key     string
+		handler bot.HandlerFunc
+	}{
+		{"button.courier_orders", handl.CourierMenuHandler},
+		{"button.courier_route", handl.CourierRouteHandler},
+	} {
+		seen := make(map[string]bool)
+		for _, text := range i18n.All(button.key) {
+			if !seen[text] {
+				seen[text] = true
+				opts = append(opts, bot.WithMessageTextHandler(text, bot.MatchTypeExact, button.handler))
+			}
+		}
+	}
+
+	if cfg.Webhook {
+		opts = append(opts, bot.WithWebhookSecretToken(cfg.WebhookSecret))
+	}
+	if cfg.BotAPIURL != "" {
+		opts = append(opts, bot.WithServerURL(cfg.BotAPIURL))
 	}
 
 	b, err := bot.New(cfg.Token, opts...)
@@ -75,7 +198,7 @@ func main() {
 		return
 	}
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGINT)
+	signal.Notify(stop, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		<-stop
@@ -84,7 +207,21 @@ func main() {
 	}()
 
 	go handl.StartWebServer(ctx, b)
+	go handl.StartReminderScheduler(ctx, b)
+	go handl.StartBroadcastWorker(ctx, b)
 	zapLogger.Info("Starting web server", zap.String("port", cfg.Port))
-	zapLogger.Info("Bot started successfully")
+
+	if cfg.Webhook {
+		if err := handl.SetWebhook(ctx, b); err != nil {
+			zapLogger.Error("error in set webhook", zap.Error(err))
+			return
+		}
+		zapLogger.Info("Bot started successfully", zap.String("mode", "webhook"))
+		b.StartWebhook(ctx)
+		handl.DeleteWebhook(b)
+		return
+	}
+
+	zapLogger.Info("Bot started successfully", zap.String("mode", "long polling"))
 	b.Start(ctx)
 }
@@ -2,7 +2,14 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config contains application configuration parameters
@@ -12,15 +19,65 @@ type Config struct {
 	BaseURL           string `json:"base_url"`
 	DBName            string `json:"db_name"`
 	SavePaymentsDir   string `json:"save_payments_dir"`
-	AdminID           int64  `json:"admin_id"`
-	StartPhotoId      string `json:"start_photo_id"`
+	AdminID           int64  `json:"admin_id"`        // the owner; the rest of the team is added with /addadmin
+	SupportChatID     int64  `json:"support_chat_id"` // customer support relay; the owner's chat when 0
+	StartPhotoId      string `json:"start_photo_id"`  // until #start_photo is in the media library
 	StartVideoId      string `json:"start_video_id"`
-	InstructorVideoId string `json:"instructor_video"`
+	InstructorVideoId string `json:"instructor_video"` // until #instruction_video is in the media library
 	Cost              int    `json:"cost"`
 	BotUsername       string `json:"bot_username"`
 	Bin               string `json:"bin"`
+	PaymentURL        string `json:"payment_url"`
+	ExportPresetsFile string `json:"export_presets_file"`
+
+	// PaymentProviderToken from @BotFather enables paying with a Telegram invoice
+	// besides sending the Kaspi receipt
+	PaymentProviderToken string `json:"-"`
+
+	// BotAPIURL points the bot at another Bot API server, e.g. a local fake one in tests
+	BotAPIURL string `json:"bot_api_url"`
+
+	// SMSGateway sends phone confirmation codes; the SMS option is not offered while it is
+	// empty. "log" only writes the codes to the server log, for development.
+	SMSGateway string `json:"sms_gateway"`
+
+	// RegionBoundariesFile is an optional GeoJSON with precise oblast boundaries used
+	// instead of the simplified ones built into the binary
+	RegionBoundariesFile string `json:"region_boundaries_file"`
+
+	// Carrier tracking pages; %s is replaced with the tracking number
+	KazpostTrackingURL string `json:"kazpost_tracking_url"`
+	CDEKTrackingURL    string `json:"cdek_tracking_url"`
+
+	// Warehouse is the origin of every courier route
+	WarehouseLat float64 `json:"warehouse_lat"`
+	WarehouseLon float64 `json:"warehouse_lon"`
+
+	// Courier ETA estimate: average speed and time spent at each stop
+	CourierSpeedKmh     float64       `json:"courier_speed_kmh"`
+	CourierStopDuration time.Duration `json:"courier_stop_duration"`
+
+	// Updates come by long polling unless Webhook is set; then Telegram posts them to
+	// WebhookURL (BaseURL + /telegram/webhook by default) with WebhookSecret in the
+	// X-Telegram-Bot-Api-Secret-Token header
+	Webhook       bool   `json:"webhook"`
+	WebhookURL    string `json:"webhook_url"`
+	WebhookSecret string `json:"-"`
+
+	// RejectOutOfZone rejects delivery addresses outside every enabled zone instead of flagging them
+	RejectOutOfZone bool `json:"reject_out_of_zone"`
+
+	// Abandoned checkout reminders
+	ReminderIntervals     []time.Duration `json:"reminder_intervals"`
+	ReminderCheckInterval time.Duration   `json:"reminder_check_interval"`
+	QuietHoursStart       int             `json:"quiet_hours_start"`
+	QuietHoursEnd         int             `json:"quiet_hours_end"`
+	Location              *time.Location  `json:"-"`
 }
 
+// SMSGatewayLog is the development SMS gateway that only logs the codes
+const SMSGatewayLog = "log"
+
 // NewConfig creates and returns a new configuration instance
 func NewConfig() (*Config, error) {
 	cfg := &Config{
@@ -36,6 +93,20 @@ func NewConfig() (*Config, error) {
 		Cost:              18900,
 		BotUsername:       "meilly_cosmetics_bot",
 		Bin:               "870304301209",
+		PaymentURL:        "https://pay.kaspi.kz/pay/ndy27jz5",
+
+		KazpostTrackingURL: "https://post.kz/mail-app/track/%s",
+		CDEKTrackingURL:    "https://www.cdek.kz/ru/tracking?order_id=%s",
+		WarehouseLat:       43.238949,
+		WarehouseLon:       76.889709,
+
+		CourierSpeedKmh:     25,
+		CourierStopDuration: 10 * time.Minute,
+
+		ReminderIntervals:     []time.Duration{time.Hour, 12 * time.Hour},
+		ReminderCheckInterval: 5 * time.Minute,
+		QuietHoursStart:       22,
+		QuietHoursEnd:         9,
 	}
 
 	// Override with environment variables if set
@@ -59,5 +130,211 @@ func NewConfig() (*Config, error) {
 		cfg.DBName = savePaymentsDir
 	}
 
+	if paymentURL := os.Getenv("PAYMENT_URL"); paymentURL != "" {
+		cfg.PaymentURL = paymentURL
+	}
+
+	if providerToken := os.Getenv("PAYMENT_PROVIDER_TOKEN"); providerToken != "" {
+		cfg.PaymentProviderToken = providerToken
+	}
+
+	// BOT_API_URL replaces https://api.telegram.org, e.g. http://localhost:8082
+	if apiURL := os.Getenv("BOT_API_URL"); apiURL != "" {
+		cfg.BotAPIURL = strings.TrimSuffix(apiURL, "/")
+	}
+
+	switch gateway := os.Getenv("SMS_GATEWAY"); gateway {
+	case "":
+	case SMSGatewayLog:
+		cfg.SMSGateway = gateway
+	default:
+		return nil, fmt.Errorf("invalid SMS_GATEWAY: %q, only %q is supported", gateway, SMSGatewayLog)
+	}
+
+	// EXPORT_PRESETS_FILE is a JSON file with extra order export column presets
+	if presets := os.Getenv("EXPORT_PRESETS_FILE"); presets != "" {
+		cfg.ExportPresetsFile = presets
+	}
+
+	// REGION_BOUNDARIES_FILE replaces the built-in simplified oblast boundaries
+	if boundaries := os.Getenv("REGION_BOUNDARIES_FILE"); boundaries != "" {
+		cfg.RegionBoundariesFile = boundaries
+	}
+
+	if kazpostURL := os.Getenv("KAZPOST_TRACKING_URL"); kazpostURL != "" {
+		cfg.KazpostTrackingURL = kazpostURL
+	}
+
+	if cdekURL := os.Getenv("CDEK_TRACKING_URL"); cdekURL != "" {
+		cfg.CDEKTrackingURL = cdekURL
+	}
+
+	if lat := os.Getenv("WAREHOUSE_LAT"); lat != "" {
+		v, err := strconv.ParseFloat(lat, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WAREHOUSE_LAT: %w", err)
+		}
+		cfg.WarehouseLat = v
+	}
+
+	if lon := os.Getenv("WAREHOUSE_LON"); lon != "" {
+		v, err := strconv.ParseFloat(lon, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WAREHOUSE_LON: %w", err)
+		}
+		cfg.WarehouseLon = v
+	}
+
+	if speed := os.Getenv("COURIER_SPEED_KMH"); speed != "" {
+		v, err := strconv.ParseFloat(speed, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid COURIER_SPEED_KMH: %q", speed)
+		}
+		cfg.CourierSpeedKmh = v
+	}
+
+	if stop := os.Getenv("COURIER_STOP_DURATION"); stop != "" {
+		d, err := time.ParseDuration(stop)
+		if err != nil {
+			return nil, fmt.Errorf("invalid COURIER_STOP_DURATION: %w", err)
+		}
+		cfg.CourierStopDuration = d
+	}
+
+	// SUPPORT_CHAT_ID is the group where customer messages are relayed, e.g. -1001234567890
+	if supportChat := os.Getenv("SUPPORT_CHAT_ID"); supportChat != "" {
+		v, err := strconv.ParseInt(supportChat, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SUPPORT_CHAT_ID: %w", err)
+		}
+		cfg.SupportChatID = v
+	}
+
+	if webhook := os.Getenv("WEBHOOK"); webhook != "" {
+		v, err := strconv.ParseBool(webhook)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK: %w", err)
+		}
+		cfg.Webhook = v
+	}
+
+	if webhookURL := os.Getenv("WEBHOOK_URL"); webhookURL != "" {
+		cfg.WebhookURL = webhookURL
+	}
+
+	// WEBHOOK_SECRET may contain only A-Z, a-z, 0-9, _ and -; a random one is used when unset,
+	// since the webhook is registered again on every start
+	if secret := os.Getenv("WEBHOOK_SECRET"); secret != "" {
+		if !validWebhookSecret(secret) {
+			return nil, fmt.Errorf("invalid WEBHOOK_SECRET: 1-256 characters A-Z, a-z, 0-9, _ and - allowed")
+		}
+		cfg.WebhookSecret = secret
+	}
+
+	if cfg.Webhook {
+		if cfg.WebhookURL == "" {
+			cfg.WebhookURL = strings.TrimSuffix(cfg.BaseURL, "/") + "/telegram/webhook"
+		}
+		u, err := url.Parse(cfg.WebhookURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return nil, fmt.Errorf("invalid WEBHOOK_URL: %q must be an https URL with a path", cfg.WebhookURL)
+		}
+		if cfg.WebhookSecret == "" {
+			secret := make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, fmt.Errorf("generate webhook secret: %w", err)
+			}
+			cfg.WebhookSecret = hex.EncodeToString(secret)
+		}
+	}
+
+	if reject := os.Getenv("REJECT_OUT_OF_ZONE"); reject != "" {
+		v, err := strconv.ParseBool(reject)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REJECT_OUT_OF_ZONE: %w", err)
+		}
+		cfg.RejectOutOfZone = v
+	}
+
+	// REMINDER_INTERVALS is a comma separated list of delays after checkout start, e.g. "1h,12h"
+	if intervals := os.Getenv("REMINDER_INTERVALS"); intervals != "" {
+		parsed, err := parseDurations(intervals)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REMINDER_INTERVALS: %w", err)
+		}
+		cfg.ReminderIntervals = parsed
+	}
+
+	if checkInterval := os.Getenv("REMINDER_CHECK_INTERVAL"); checkInterval != "" {
+		d, err := time.ParseDuration(checkInterval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid REMINDER_CHECK_INTERVAL: %q", checkInterval)
+		}
+		cfg.ReminderCheckInterval = d
+	}
+
+	if start := os.Getenv("QUIET_HOURS_START"); start != "" {
+		hour, err := parseHour(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QUIET_HOURS_START: %w", err)
+		}
+		cfg.QuietHoursStart = hour
+	}
+
+	if end := os.Getenv("QUIET_HOURS_END"); end != "" {
+		hour, err := parseHour(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QUIET_HOURS_END: %w", err)
+		}
+		cfg.QuietHoursEnd = hour
+	}
+
+	// All business hours are counted in Almaty time; fall back to a fixed UTC+5 zone
+	// when the host has no tzdata installed.
+	loc, err := time.LoadLocation("Asia/Almaty")
+	if err != nil {
+		loc = time.FixedZone("Asia/Almaty", 5*60*60)
+	}
+	cfg.Location = loc
+
 	return cfg, nil
 }
+
+func parseDurations(raw string) ([]time.Duration, error) {
+	var durations []time.Duration
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			return nil, err
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
+
+func validWebhookSecret(secret string) bool {
+	if len(secret) > 256 {
+		return false
+	}
+	for _, r := range secret {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func parseHour(raw string) (int, error) {
+	hour, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return 0, err
+	}
+	if hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("hour %d out of range 0-23", hour)
+	}
+	return hour, nil
+}
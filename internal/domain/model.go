@@ -4,6 +4,8 @@ package domain
 
 import (
 	"database/sql"
+	"encoding/json"
+	"time"
 )
 
 type PdfResult struct {
@@ -14,11 +16,24 @@ type PdfResult struct {
 }
 
 type UserState struct {
-	State         string `json:"state"`
-	BroadCastType string `json:"broadcast_type"`
-	Count         int    `json:"count"`
-	Contact       string `json:"contact"`
-	IsPaid        bool   `json:"is_paid"`
+	State     string `json:"state"`
+	Count     int    `json:"count"`
+	Contact   string `json:"contact"`
+	IsPaid    bool   `json:"is_paid"`
+	OrderID   int64  `json:"order_id,omitempty"`
+	Attempts  int    `json:"attempts,omitempty"`
+	Code      string `json:"code,omitempty"`
+	EnteredAt int64  `json:"entered_at,omitempty"` // when the chat entered State, see fsm.State.Timeout
+
+	// Broadcast audience being built by the admin; AudienceField is the filter
+	// whose value the admin is typing, see AudienceFilter
+	Audience      *AudienceFilter `json:"audience,omitempty"`
+	AudienceField string          `json:"audience_field,omitempty"`
+
+	// Message template being edited by the admin
+	TemplateKey string `json:"template_key,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Draft       string `json:"draft,omitempty"`
 }
 
 // JustEntry represents a user registration in the just table
@@ -40,8 +55,41 @@ type ClientEntry struct {
 	DateRegister sql.NullString `json:"dateRegister" db:"dateRegister"`
 	DatePay      string         `json:"dataPay" db:"dataPay"`
 	Checks       bool           `json:"checks" db:"checks"`
+
+	// AddressDetails is the structured form of Address, nil for old free-form addresses
+	AddressDetails *Address `json:"addressDetails,omitempty"`
+
+	// PhoneVerifiedBy tells how Contact was confirmed to belong to the buyer
+	PhoneVerifiedBy string `json:"phoneVerifiedBy,omitempty"`
+
+	// Sets is the number of sets paid for the order, 0 for orders saved before it was kept
+	Sets int `json:"sets,omitempty" db:"sets"`
+}
+
+// How the buyer's phone was verified
+const (
+	PhoneVerifiedTelegram = "telegram" // own contact shared with the Telegram button
+	PhoneVerifiedSMS      = "sms"      // confirmation code sent to the number
+)
+
+// Address is a structured Kazakhstan delivery address
+type Address struct {
+	Region         string `json:"region,omitempty"`
+	City           string `json:"city"`
+	District       string `json:"district,omitempty"`
+	Street         string `json:"street"`
+	House          string `json:"house"`
+	Apartment      string `json:"apartment,omitempty"`
+	Entrance       string `json:"entrance,omitempty"`
+	Floor          string `json:"floor,omitempty"`
+	Intercom       string `json:"intercom,omitempty"`
+	CourierComment string `json:"courierComment,omitempty"`
+	PrivateHouse   bool   `json:"privateHouse,omitempty"`
 }
 
+// TicketsPerSet is the number of lottery tickets every paid set brings
+const TicketsPerSet = 3
+
 // LotoEntry represents a lottery participant in the loto table
 type LotoEntry struct {
 	ID      int64          `json:"id" db:"id"`
@@ -63,3 +111,511 @@ type GeoEntry struct {
 	Location string `json:"location" db:"location"`
 	DataReg  string `json:"dataReg" db:"dataReg"`
 }
+
+// Confidence of the coordinates stored for an order
+const (
+	GeoConfidenceGPS    = "gps"    // sent by the Mini App
+	GeoConfidenceManual = "manual" // placed by the admin
+	GeoConfidenceHigh   = "high"   // known street in a known city
+	GeoConfidenceMedium = "medium" // known street without a city
+	GeoConfidenceLow    = "low"    // city centre only
+	GeoConfidenceNone   = "none"   // address not found, no coordinates
+)
+
+// AddressGeocode is the result of geocoding a typed address
+type AddressGeocode struct {
+	Lat        float64 `json:"latitude,omitempty"`
+	Lon        float64 `json:"longitude,omitempty"`
+	City       string  `json:"city,omitempty"`
+	Street     string  `json:"street,omitempty"`
+	Confidence string  `json:"confidence"`
+}
+
+// NeedsPin reports whether the admin has to place the order pin by hand
+func (g AddressGeocode) NeedsPin() bool {
+	return GeoNeedsPin(g.Confidence)
+}
+
+// GeoNeedsPin reports whether coordinates of the given confidence are too rough to deliver to
+func GeoNeedsPin(confidence string) bool {
+	return confidence == GeoConfidenceLow || confidence == GeoConfidenceNone
+}
+
+// PinRequest is an order whose coordinates could not be trusted
+type PinRequest struct {
+	OrderID    int64    `json:"orderID"`
+	UserID     int64    `json:"userID"`
+	Fio        string   `json:"fio"`
+	Contact    string   `json:"contact"`
+	Address    string   `json:"address"`
+	Confidence string   `json:"confidence"`
+	Latitude   *float64 `json:"latitude,omitempty"`
+	Longitude  *float64 `json:"longitude,omitempty"`
+}
+
+// Place is the settlement and region resolved from coordinates.
+// City is empty for points between settlements.
+type Place struct {
+	City       string  `json:"city,omitempty"`
+	Region     string  `json:"region"`
+	DistanceKm float64 `json:"distanceKm"`
+}
+
+// Checkout reminder statuses
+const (
+	ReminderStatusPending   = "pending"
+	ReminderStatusConverted = "converted"
+	ReminderStatusOptedOut  = "opted_out"
+	ReminderStatusExhausted = "exhausted"
+)
+
+// CheckoutReminder tracks a started but unpaid checkout in the checkout_reminders table
+type CheckoutReminder struct {
+	ID             int64      `json:"id" db:"id"`
+	UserID         int64      `json:"userID" db:"id_user"`
+	Count          int        `json:"count" db:"count"`
+	Amount         int        `json:"amount" db:"amount"`
+	Status         string     `json:"status" db:"status"`
+	RemindersSent  int        `json:"remindersSent" db:"reminders_sent"`
+	OptedOut       bool       `json:"optedOut" db:"opted_out"`
+	StartedAt      time.Time  `json:"startedAt" db:"started_at"`
+	LastRemindedAt *time.Time `json:"lastRemindedAt,omitempty" db:"last_reminded_at"`
+	ConvertedAt    *time.Time `json:"convertedAt,omitempty" db:"converted_at"`
+}
+
+// ReminderStats summarizes abandoned checkout reminders and their conversions
+type ReminderStats struct {
+	Pending            int `json:"pending"`
+	Reminded           int `json:"reminded"`
+	Converted          int `json:"converted"`
+	ConvertedAfterPing int `json:"convertedAfterPing"`
+	OptedOut           int `json:"optedOut"`
+}
+
+// Order statuses stored in client.status
+const (
+	OrderStatusNew       = "new"
+	OrderStatusPacked    = "packed"
+	OrderStatusShipped   = "shipped"
+	OrderStatusAssigned  = "assigned"
+	OrderStatusPickedUp  = "picked_up"
+	OrderStatusDelivered = "delivered"
+	OrderStatusFailed    = "failed_attempt"
+)
+
+// Courier represents a delivery courier in the couriers table
+type Courier struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"userID" db:"id_user"`
+	Name      string    `json:"name" db:"name"`
+	Phone     string    `json:"phone" db:"phone"`
+	City      string    `json:"city" db:"city"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// CourierOrder is an order (client row) as seen by a courier or the assignment screens
+type CourierOrder struct {
+	OrderID         int64      `json:"orderID"`
+	UserID          int64      `json:"userID"`
+	Fio             string     `json:"fio"`
+	Contact         string     `json:"contact"`
+	Address         string     `json:"address"`
+	Status          string     `json:"status"`
+	TrackingNumber  string     `json:"trackingNumber,omitempty"`
+	Carrier         string     `json:"carrier,omitempty"`
+	CourierID       *int64     `json:"courierID,omitempty"`
+	Latitude        *float64   `json:"latitude,omitempty"`
+	Longitude       *float64   `json:"longitude,omitempty"`
+	City            string     `json:"city"`
+	GeoConfidence   string     `json:"geoConfidence,omitempty"`
+	AssignedAt      *time.Time `json:"assignedAt,omitempty"`
+	StatusUpdatedAt *time.Time `json:"statusUpdatedAt,omitempty"`
+}
+
+// RouteStop is one order in a courier's optimized route
+type RouteStop struct {
+	Sequence  int     `json:"sequence"`
+	OrderID   int64   `json:"orderID"`
+	UserID    int64   `json:"userID"`
+	Fio       string  `json:"fio"`
+	Contact   string  `json:"contact"`
+	Address   string  `json:"address"`
+	Status    string  `json:"status"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	LegKm     float64 `json:"legKm"`
+}
+
+// Route is the optimized visiting order of a courier's orders for one day
+type Route struct {
+	CourierID int64          `json:"courierID"`
+	Date      string         `json:"date"`
+	OriginLat float64        `json:"originLat"`
+	OriginLon float64        `json:"originLon"`
+	Stops     []RouteStop    `json:"stops"`
+	TotalKm   float64        `json:"totalKm"`
+	Unlocated []CourierOrder `json:"unlocated,omitempty"`
+}
+
+// DeliveryZone is a delivery area drawn as a GeoJSON polygon in the delivery_zones table
+type DeliveryZone struct {
+	ID           int64           `json:"id" db:"id"`
+	Name         string          `json:"name" db:"name"`
+	GeoJSON      json.RawMessage `json:"geojson" db:"geojson"`
+	Fee          int             `json:"fee" db:"fee"`
+	DeliveryDays int             `json:"deliveryDays" db:"delivery_days"`
+	Enabled      bool            `json:"enabled" db:"enabled"`
+	CreatedAt    time.Time       `json:"createdAt" db:"created_at"`
+}
+
+// Delivery proof verification methods
+const (
+	ProofVerifiedByCode = "code"
+	ProofVerifiedByQR   = "qr"
+)
+
+// DeliveryProof is the customer code, doorstep photo and courier location
+// confirming a delivery in the delivery_proofs table
+type DeliveryProof struct {
+	ID          int64     `json:"id" db:"id"`
+	OrderID     int64     `json:"orderID" db:"order_id"`
+	CourierID   int64     `json:"courierID" db:"courier_id"`
+	CourierName string    `json:"courierName,omitempty"`
+	Code        string    `json:"-" db:"code"`
+	CodeSentAt  time.Time `json:"codeSentAt" db:"code_sent_at"`
+	// FailedAttempts counts wrong codes for the order across all issued codes
+	FailedAttempts int        `json:"failedAttempts,omitempty" db:"failed_attempts"`
+	VerifiedBy     string     `json:"verifiedBy,omitempty" db:"verified_by"`
+	VerifiedAt     *time.Time `json:"verifiedAt,omitempty" db:"verified_at"`
+	PhotoFileID    string     `json:"photoFileID,omitempty" db:"photo_file_id"`
+	Latitude       *float64   `json:"latitude,omitempty" db:"latitude"`
+	Longitude      *float64   `json:"longitude,omitempty" db:"longitude"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty" db:"delivered_at"`
+	Fio            string     `json:"fio,omitempty"`
+	Address        string     `json:"address,omitempty"`
+}
+
+// OrderTracking is what a customer sees about one order in /status and the Mini App
+type OrderTracking struct {
+	OrderID        int64      `json:"orderID"`
+	Status         string     `json:"status"`
+	StatusLabel    string     `json:"statusLabel"`
+	Address        string     `json:"address"`
+	TrackingNumber string     `json:"trackingNumber,omitempty"`
+	TrackingURL    string     `json:"trackingURL,omitempty"`
+	CourierName    string     `json:"courierName,omitempty"`
+	CourierPhone   string     `json:"courierPhone,omitempty"`
+	ETA            *time.Time `json:"eta,omitempty"`
+	UpdatedAt      *time.Time `json:"updatedAt,omitempty"`
+}
+
+// OrderExportFilter selects orders for /api/admin/export/orders; empty fields are not filtered
+type OrderExportFilter struct {
+	Status string `json:"status,omitempty"`
+	From   string `json:"from,omitempty"` // YYYY-MM-DD, inclusive
+	To     string `json:"to,omitempty"`   // YYYY-MM-DD, inclusive
+	City   string `json:"city,omitempty"`
+}
+
+// ExportOrder is one order row handed to logistics partners
+type ExportOrder struct {
+	OrderID        int64    `json:"orderID"`
+	UserID         int64    `json:"userID"`
+	Fio            string   `json:"fio"`
+	Phone          string   `json:"phone"`
+	Address        string   `json:"address"`
+	City           string   `json:"city"`
+	Latitude       *float64 `json:"latitude,omitempty"`
+	Longitude      *float64 `json:"longitude,omitempty"`
+	Items          int      `json:"items"`
+	Status         string   `json:"status"`
+	TrackingNumber string   `json:"trackingNumber,omitempty"`
+	DatePay        string   `json:"dataPay"`
+	CustomerID     int64    `json:"customerID,omitempty"`
+}
+
+// Carriers for orders shipped by post
+const (
+	CarrierKazpost = "kazpost"
+	CarrierCDEK    = "cdek"
+)
+
+// TrackingImportRow is one line of an uploaded tracking number file.
+// Key is either an order ID or a customer phone.
+type TrackingImportRow struct {
+	Line           int    `json:"line"`
+	Key            string `json:"key"`
+	TrackingNumber string `json:"trackingNumber"`
+	Carrier        string `json:"carrier,omitempty"`
+}
+
+// TrackingImportFailure is a line that could not be attached to an order
+type TrackingImportFailure struct {
+	TrackingImportRow
+	Reason string `json:"reason"`
+}
+
+// TrackingImportReport summarizes a tracking number import
+type TrackingImportReport struct {
+	Total     int                     `json:"total"`
+	Updated   int                     `json:"updated"`
+	Unchanged int                     `json:"unchanged"`
+	Failed    []TrackingImportFailure `json:"failed"`
+}
+
+// Customer is one buyer behind one or more Telegram accounts sharing a phone
+type Customer struct {
+	ID        int64             `json:"id"`
+	Phone     string            `json:"phone"`
+	CreatedAt string            `json:"createdAt"`
+	Accounts  []CustomerAccount `json:"accounts"`
+	Orders    []CustomerOrder   `json:"orders"`
+	Tickets   []CustomerTicket  `json:"tickets"`
+}
+
+// CustomerAccount is a Telegram account linked to a customer
+type CustomerAccount struct {
+	UserID   int64  `json:"userID"`
+	UserName string `json:"userName"`
+	LinkedAt string `json:"linkedAt"`
+}
+
+// CustomerOrder is an order placed from one of the customer's accounts
+type CustomerOrder struct {
+	OrderID         int64  `json:"orderID"`
+	UserID          int64  `json:"userID"`
+	Fio             string `json:"fio"`
+	Contact         string `json:"contact"`
+	Address         string `json:"address"`
+	Status          string `json:"status"`
+	TrackingNumber  string `json:"trackingNumber,omitempty"`
+	DatePay         string `json:"dataPay"`
+	PhoneVerifiedBy string `json:"phoneVerifiedBy,omitempty"`
+}
+
+// CustomerTicket is a lottery ticket of one of the customer's accounts
+type CustomerTicket struct {
+	UserID  int64  `json:"userID"`
+	LotoID  int    `json:"lotoID"`
+	WhoPaid string `json:"whoPaid"`
+	DatePay string `json:"datePay"`
+}
+
+// CustomerSummary is a row of the customer list: a phone used by several accounts
+type CustomerSummary struct {
+	ID         int64   `json:"id"`
+	Phone      string  `json:"phone"`
+	UserIDs    []int64 `json:"userIDs"`
+	Orders     int     `json:"orders"`
+	Tickets    int     `json:"tickets"`
+	Dispatched int     `json:"dispatched"` // orders handed to a courier or carrier
+	LastPaid   string  `json:"lastPaid"`
+}
+
+// MessageTemplate is a version of a customer-facing text edited by an admin. The
+// latest version that is not reverted replaces the built-in text.
+type MessageTemplate struct {
+	ID         int64      `json:"id" db:"id"`
+	Key        string     `json:"key" db:"template_key"`
+	Language   string     `json:"language" db:"language"`
+	Body       string     `json:"body" db:"body"`
+	Version    int        `json:"version" db:"version"`
+	CreatedBy  int64      `json:"createdBy" db:"created_by"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	RevertedAt *time.Time `json:"revertedAt,omitempty" db:"reverted_at"`
+}
+
+// MediaAsset is a version of a photo, video or document uploaded by an admin and
+// referenced by name. The latest version that is not deleted is in use.
+type MediaAsset struct {
+	ID        int64      `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Kind      string     `json:"kind" db:"kind"` // photo, video or document
+	FileID    string     `json:"fileId" db:"file_id"`
+	Version   int        `json:"version" db:"version"`
+	CreatedBy int64      `json:"createdBy" db:"created_by"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty" db:"deleted_at"`
+}
+
+// SourceStats is the campaign report row of one /start source. Users who came without
+// a payload are reported under "direct".
+type SourceStats struct {
+	Source     string  `json:"source"`
+	Users      int     `json:"users"`
+	Buyers     int     `json:"buyers"`
+	Orders     int     `json:"orders"`
+	Revenue    int     `json:"revenue"`
+	Conversion float64 `json:"conversion"` // buyers / users, percent
+}
+
+// Support ticket statuses
+const (
+	SupportTicketOpen   = "open"
+	SupportTicketClosed = "closed"
+)
+
+// Support message directions: from the customer to the support chat and back
+const (
+	SupportMessageIn  = "in"
+	SupportMessageOut = "out"
+)
+
+// SupportTicket is a customer's conversation with the support chat
+type SupportTicket struct {
+	ID                int64      `json:"id" db:"id"`
+	UserID            int64      `json:"userID" db:"id_user"`
+	UserName          string     `json:"userName"`
+	Status            string     `json:"status" db:"status"`
+	LastUserMessageAt *time.Time `json:"lastUserMessageAt,omitempty" db:"last_user_message_at"`
+	ClosedAt          *time.Time `json:"closedAt,omitempty" db:"closed_at"`
+	CreatedAt         time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// SupportMessage is a message of a ticket. ChatID and MessageID locate it in the support
+// chat: the relayed copy of a customer's message or the support agent's reply.
+type SupportMessage struct {
+	ID        int64  `json:"id" db:"id"`
+	TicketID  int64  `json:"ticketID" db:"ticket_id"`
+	Direction string `json:"direction" db:"direction"`
+	ChatID    int64  `json:"chatID" db:"chat_id"`
+	MessageID int    `json:"messageID" db:"message_id"`
+	FromID    int64  `json:"fromID" db:"from_id"`
+	Kind      string `json:"kind" db:"kind"`
+	Text      string `json:"text,omitempty" db:"text"`
+}
+
+// Admin roles. The owner from the config is always an admin and cannot be removed.
+const (
+	AdminRoleOwner    = "owner"
+	AdminRoleManager  = "manager"
+	AdminRoleOperator = "operator"
+	AdminRoleCourier  = "courier"
+	AdminRoleViewer   = "viewer"
+)
+
+// AdminRoles lists the roles from the most to the least privileged
+var AdminRoles = []string{AdminRoleOwner, AdminRoleManager, AdminRoleOperator, AdminRoleCourier, AdminRoleViewer}
+
+// Admin permissions: each admin menu action, command and admin HTTP endpoint requires one
+const (
+	PermissionAdmins    = "admins"    // add and remove admins
+	PermissionStats     = "stats"     // statistics, dashboards and reports
+	PermissionBroadcast = "broadcast" // broadcasts, message templates and the media library
+	PermissionOrders    = "orders"    // order statuses, tracking numbers and exports
+	PermissionDelivery  = "delivery"  // couriers, zones, routes, pins and delivery proofs
+	PermissionReceipts  = "receipts"  // payment receipt notifications
+	PermissionSupport   = "support"   // customer support tickets
+)
+
+var rolePermissions = map[string][]string{
+	AdminRoleOwner: {PermissionAdmins, PermissionStats, PermissionBroadcast, PermissionOrders,
+		PermissionDelivery, PermissionReceipts, PermissionSupport},
+	AdminRoleManager:  {PermissionStats, PermissionBroadcast, PermissionOrders, PermissionDelivery, PermissionReceipts, PermissionSupport},
+	AdminRoleOperator: {PermissionOrders, PermissionReceipts, PermissionSupport},
+	AdminRoleCourier:  {PermissionDelivery},
+	AdminRoleViewer:   {PermissionStats},
+}
+
+// ValidAdminRole reports whether role is one of AdminRoles
+func ValidAdminRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleAllows reports whether the role grants the permission
+func RoleAllows(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Admin is a team member with access to the admin panel
+type Admin struct {
+	UserID    int64     `json:"userID" db:"id_user"`
+	Name      string    `json:"name" db:"name"`
+	Role      string    `json:"role" db:"role"`
+	AddedBy   int64     `json:"addedBy" db:"added_by"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Broadcast job statuses. A running job is sent by the background worker, also after
+// a restart; paused and running jobs can be cancelled.
+const (
+	BroadcastRunning   = "running"
+	BroadcastPaused    = "paused"
+	BroadcastCancelled = "cancelled"
+	BroadcastDone      = "done"
+)
+
+// Broadcast recipient statuses. Blocked means the user blocked the bot or deleted the account.
+const (
+	BroadcastRecipientPending = "pending"
+	BroadcastRecipientSent    = "sent"
+	BroadcastRecipientFailed  = "failed"
+	BroadcastRecipientBlocked = "blocked"
+)
+
+// BroadcastJob is an admin's message to an audience in the broadcast_jobs table, with
+// the delivery counts of its recipients. The status message is edited with the progress.
+type BroadcastJob struct {
+	ID              int64          `json:"id" db:"id"`
+	AdminID         int64          `json:"adminID" db:"admin_id"`
+	Audience        AudienceFilter `json:"audience" db:"audience"`
+	Kind            string         `json:"kind" db:"kind"`
+	FileID          string         `json:"fileID,omitempty" db:"file_id"`
+	Text            string         `json:"text,omitempty" db:"text"`
+	Status          string         `json:"status" db:"status"`
+	StatusChatID    int64          `json:"statusChatID,omitempty" db:"status_chat_id"`
+	StatusMessageID int            `json:"statusMessageID,omitempty" db:"status_message_id"`
+	Total           int            `json:"total"`
+	Pending         int            `json:"pending"`
+	Sent            int            `json:"sent"`
+	Failed          int            `json:"failed"`
+	Blocked         int            `json:"blocked"`
+	CreatedAt       time.Time      `json:"createdAt" db:"created_at"`
+	FinishedAt      *time.Time     `json:"finishedAt,omitempty" db:"finished_at"`
+}
+
+// BroadcastResult is the outcome of sending a broadcast to one recipient. ErrorCode is
+// the Bot API error code, 0 when the request did not reach Telegram. Retry marks a
+// failure that may pass: the recipient stays pending until the attempts run out.
+type BroadcastResult struct {
+	UserID    int64
+	Status    string
+	ErrorCode int
+	Error     string
+	Retry     bool
+}
+
+// Broadcast audience segments, see AudienceFilter
+const (
+	AudienceAll           = "all"            // everyone who started the bot
+	AudienceBuyers        = "buyers"         // paid at least once
+	AudienceTicketHolders = "ticket_holders" // hold lottery tickets
+	AudienceNeverPaid     = "never_paid"     // started the bot but never paid
+	AudienceUndelivered   = "undelivered"    // paid, but the order is not delivered yet
+)
+
+// AudienceSegments lists the segments in the order the broadcast menu shows them
+var AudienceSegments = []string{AudienceAll, AudienceBuyers, AudienceTicketHolders, AudienceNeverPaid, AudienceUndelivered}
+
+// Filters of AudienceFilter the admin can set one by one in the broadcast builder
+const (
+	AudienceFieldSource   = "source"
+	AudienceFieldCity     = "city"
+	AudienceFieldPaidDays = "paid_days"
+)
+
+// AudienceFilter selects the recipients of a broadcast: a segment narrowed by the
+// optional filters; empty fields are not filtered
+type AudienceFilter struct {
+	Segment  string `json:"segment"`
+	Source   string `json:"source,omitempty"`    // first-touch campaign source, "direct" for none
+	City     string `json:"city,omitempty"`      // city or region of the user's location
+	PaidDays int    `json:"paid_days,omitempty"` // paid within the last N days
+}
@@ -0,0 +1,95 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Mermaid renders the machine as a Mermaid state diagram. Dashed timeout edges are not
+// supported by Mermaid, so they are labelled instead.
+func (m *Machine[E]) Mermaid() string {
+	var sb strings.Builder
+	sb.WriteString("stateDiagram-v2\n")
+	fmt.Fprintf(&sb, "    [*] --> %s\n", m.initial)
+	for _, name := range m.order {
+		st := m.states[name]
+		if inputs := m.inputs(st); inputs != "" {
+			fmt.Fprintf(&sb, "    %s : %s\n", name, inputs)
+		}
+	}
+	for _, name := range m.order {
+		st := m.states[name]
+		for _, next := range st.Next {
+			fmt.Fprintf(&sb, "    %s --> %s\n", name, next)
+		}
+		if st.Timeout > 0 {
+			fmt.Fprintf(&sb, "    %s --> %s : timeout %s\n", name, m.timeoutTo(st), formatDuration(st.Timeout))
+		}
+	}
+	return sb.String()
+}
+
+// DOT renders the machine in the Graphviz DOT language
+func (m *Machine[E]) DOT() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %q {\n", m.name)
+	sb.WriteString("    rankdir=LR;\n    node [shape=box, style=rounded];\n")
+	for _, name := range m.order {
+		st := m.states[name]
+		label := name
+		if inputs := m.inputs(st); inputs != "" {
+			label += "\\n" + inputs
+		}
+		shape := ""
+		if name == m.initial {
+			shape = ", peripheries=2"
+		}
+		fmt.Fprintf(&sb, "    %q [label=\"%s\"%s];\n", name, label, shape)
+	}
+	for _, name := range m.order {
+		st := m.states[name]
+		for _, next := range st.Next {
+			fmt.Fprintf(&sb, "    %q -> %q;\n", name, next)
+		}
+		if st.Timeout > 0 {
+			fmt.Fprintf(&sb, "    %q -> %q [style=dashed, label=\"timeout %s\"];\n", name, m.timeoutTo(st), formatDuration(st.Timeout))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func (m *Machine[E]) timeoutTo(st *State[E]) string {
+	if st.TimeoutTo != "" {
+		return st.TimeoutTo
+	}
+	return m.initial
+}
+
+// inputs lists the accepted input types in declaration order of Input
+func (m *Machine[E]) inputs(st *State[E]) string {
+	kinds := make([]Input, 0, len(st.On))
+	for in := range st.On {
+		kinds = append(kinds, in)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+	names := make([]string, len(kinds))
+	for i, in := range kinds {
+		names[i] = in.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
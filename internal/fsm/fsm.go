@@ -0,0 +1,277 @@
+// Package fsm drives the bot conversations. A Machine is declared as a table of states:
+// the input types each state accepts and their handlers, the states it may move to,
+// enter and exit actions and an inactivity timeout. The current state of every chat is
+// kept in a Store (Redis in production) as a domain.UserState.
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"meily/internal/domain"
+)
+
+// Input is the kind of update a state may accept
+type Input int
+
+const (
+	Text Input = iota
+	Callback
+	Document
+	Photo
+	Video
+	Contact
+	Location
+	// Other covers the remaining messages: stickers, audio, voice, video notes
+	Other
+)
+
+var inputNames = [...]string{"text", "callback", "document", "photo", "video", "contact", "location", "other"}
+
+func (i Input) String() string {
+	if i < 0 || int(i) >= len(inputNames) {
+		return fmt.Sprintf("input(%d)", int(i))
+	}
+	return inputNames[i]
+}
+
+// ErrNotAllowed is returned by Transition when the table has no edge between the states
+var ErrNotAllowed = errors.New("transition not allowed")
+
+// Func is a handler or an action. E is the event type of the machine, s the state of
+// the chat: the current one for handlers, the state being left or entered for actions.
+type Func[E any] func(ctx context.Context, e E, s *domain.UserState)
+
+// State declares one state of the conversation
+type State[E any] struct {
+	Name string
+	// On maps the accepted input types to their handlers; any other input is unexpected
+	On map[Input]Func[E]
+	// Next lists the states Transition may move to. Staying in the same state and
+	// Reset are always allowed.
+	Next []string
+	// Enter and Exit run when Transition or Reset moves the chat between states
+	Enter, Exit Func[E]
+	// Timeout is how long the chat may stay in the state without a transition.
+	// The next update after it moves the chat to TimeoutTo (the initial state when
+	// empty) and runs OnTimeout. Without OnTimeout the update is then handled by the
+	// new state, with it the update is considered answered.
+	Timeout   time.Duration
+	TimeoutTo string
+	OnTimeout Func[E]
+	// Hint is passed to the machine's unexpected input handler, e.g. a message key
+	// explaining what the state is waiting for
+	Hint string
+}
+
+// Store keeps the state of each chat
+type Store struct {
+	// Load returns nil without an error when the chat has no saved state
+	Load   func(ctx context.Context, id int64) (*domain.UserState, error)
+	Save   func(ctx context.Context, id int64, s *domain.UserState) error
+	Delete func(ctx context.Context, id int64) error
+}
+
+// Machine is a declared conversation flow
+type Machine[E any] struct {
+	name       string
+	initial    string
+	states     map[string]*State[E]
+	order      []string
+	store      Store
+	unexpected func(ctx context.Context, e E, s *domain.UserState, hint string)
+	now        func() time.Time
+}
+
+// New builds a machine from its states; the first one is the initial state. unexpected
+// answers input that the current state does not accept.
+func New[E any](name string, store Store, unexpected func(ctx context.Context, e E, s *domain.UserState, hint string), states ...State[E]) (*Machine[E], error) {
+	if len(states) == 0 {
+		return nil, fmt.Errorf("fsm %s: no states", name)
+	}
+	m := &Machine[E]{
+		name:       name,
+		initial:    states[0].Name,
+		states:     make(map[string]*State[E], len(states)),
+		store:      store,
+		unexpected: unexpected,
+		now:        time.Now,
+	}
+	for i := range states {
+		st := &states[i]
+		if st.Name == "" {
+			return nil, fmt.Errorf("fsm %s: state %d has no name", name, i)
+		}
+		if _, ok := m.states[st.Name]; ok {
+			return nil, fmt.Errorf("fsm %s: state %s declared twice", name, st.Name)
+		}
+		m.states[st.Name] = st
+		m.order = append(m.order, st.Name)
+	}
+	for _, st := range states {
+		for _, next := range st.Next {
+			if _, ok := m.states[next]; !ok {
+				return nil, fmt.Errorf("fsm %s: state %s moves to unknown state %s", name, st.Name, next)
+			}
+		}
+		if st.TimeoutTo != "" {
+			if _, ok := m.states[st.TimeoutTo]; !ok {
+				return nil, fmt.Errorf("fsm %s: state %s times out to unknown state %s", name, st.Name, st.TimeoutTo)
+			}
+		}
+	}
+	return m, nil
+}
+
+// MustNew is like New but panics on an invalid table
+func MustNew[E any](name string, store Store, unexpected func(ctx context.Context, e E, s *domain.UserState, hint string), states ...State[E]) *Machine[E] {
+	m, err := New(name, store, unexpected, states...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Initial returns the name of the initial state
+func (m *Machine[E]) Initial() string {
+	return m.initial
+}
+
+// Hint returns the hint of the state, or of the initial state for an unknown name
+func (m *Machine[E]) Hint(state string) string {
+	return m.state(state).Hint
+}
+
+func (m *Machine[E]) state(name string) *State[E] {
+	if st, ok := m.states[name]; ok {
+		return st
+	}
+	return m.states[m.initial]
+}
+
+// Current returns the saved state of the chat. A chat without a saved state, or with a
+// state the table does not know, is in the initial state. On a store error the initial
+// state is returned together with the error.
+func (m *Machine[E]) Current(ctx context.Context, id int64) (*domain.UserState, error) {
+	s, err := m.store.Load(ctx, id)
+	if err != nil {
+		return &domain.UserState{State: m.initial}, err
+	}
+	if s == nil {
+		return &domain.UserState{State: m.initial}, nil
+	}
+	if _, ok := m.states[s.State]; !ok {
+		s.State = m.initial
+	}
+	return s, nil
+}
+
+// Active reports whether the chat is in a state other than the initial one
+func (m *Machine[E]) Active(ctx context.Context, id int64) (bool, error) {
+	s, err := m.Current(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return s.State != m.initial, nil
+}
+
+// Dispatch hands the update to the handler of the chat's current state. A store error
+// is returned after the update has been handled in the initial state.
+func (m *Machine[E]) Dispatch(ctx context.Context, id int64, in Input, e E) error {
+	s, loadErr := m.Current(ctx, id)
+	st := m.state(s.State)
+
+	if m.expired(st, s) {
+		to := st.TimeoutTo
+		if to == "" {
+			to = m.initial
+		}
+		next := *s
+		next.State = to
+		if err := m.move(ctx, id, s, &next, e); err != nil {
+			return err
+		}
+		if st.OnTimeout != nil {
+			st.OnTimeout(ctx, e, s)
+			return loadErr
+		}
+		return m.Dispatch(ctx, id, in, e)
+	}
+
+	if handle := st.On[in]; handle != nil {
+		handle(ctx, e, s)
+	} else if m.unexpected != nil {
+		m.unexpected(ctx, e, s, st.Hint)
+	}
+	return loadErr
+}
+
+func (m *Machine[E]) expired(st *State[E], s *domain.UserState) bool {
+	return st.Timeout > 0 && s.EnteredAt > 0 && m.now().Sub(time.Unix(s.EnteredAt, 0)) > st.Timeout
+}
+
+// Transition moves the chat to next.State and saves next. It fails with ErrNotAllowed
+// when the current state does not list the target in Next. Moving to the current state
+// only saves next and restarts its timeout.
+func (m *Machine[E]) Transition(ctx context.Context, id int64, next *domain.UserState, e E) error {
+	if _, ok := m.states[next.State]; !ok {
+		return fmt.Errorf("fsm %s: unknown state %s", m.name, next.State)
+	}
+	cur, err := m.Current(ctx, id)
+	if err != nil {
+		return err
+	}
+	if cur.State != next.State && next.State != m.initial && !m.allowed(cur.State, next.State) {
+		return fmt.Errorf("fsm %s: %s → %s: %w", m.name, cur.State, next.State, ErrNotAllowed)
+	}
+	return m.move(ctx, id, cur, next, e)
+}
+
+func (m *Machine[E]) allowed(from, to string) bool {
+	for _, next := range m.state(from).Next {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset runs the exit action of the current state and forgets the chat's state
+func (m *Machine[E]) Reset(ctx context.Context, id int64, e E) error {
+	cur, err := m.Current(ctx, id)
+	if err != nil {
+		return err
+	}
+	return m.move(ctx, id, cur, &domain.UserState{State: m.initial}, e)
+}
+
+// Save stores changed data of the current state without a transition
+func (m *Machine[E]) Save(ctx context.Context, id int64, s *domain.UserState) error {
+	return m.store.Save(ctx, id, s)
+}
+
+func (m *Machine[E]) move(ctx context.Context, id int64, cur, next *domain.UserState, e E) error {
+	from, to := m.state(cur.State), m.state(next.State)
+	if from != to && from.Exit != nil {
+		from.Exit(ctx, e, cur)
+	}
+
+	if to.Name == m.initial {
+		// The initial state carries no data, so it is not stored at all
+		if err := m.store.Delete(ctx, id); err != nil {
+			return err
+		}
+	} else {
+		next.EnteredAt = m.now().Unix()
+		if err := m.store.Save(ctx, id, next); err != nil {
+			return err
+		}
+	}
+
+	if from != to && to.Enter != nil {
+		to.Enter(ctx, e, next)
+	}
+	return nil
+}
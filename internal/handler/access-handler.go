@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// webAppAuthMaxAge limits how long the admin panel opened from Telegram stays signed in
+const webAppAuthMaxAge = 24 * time.Hour
+
+// adminRole returns the role of the Telegram user, or "" when they are not an admin.
+// The admin from the config is the owner.
+func (h *Handler) adminRole(ctx context.Context, userID int64) string {
+	if userID == h.cfg.AdminID {
+		return domain.AdminRoleOwner
+	}
+	admin, err := h.repo.GetAdmin(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get admin", zap.Int64("user_id", userID), zap.Error(err))
+		return ""
+	}
+	if admin == nil {
+		return ""
+	}
+	return admin.Role
+}
+
+// can reports whether the user is an admin whose role grants the permission
+func (h *Handler) can(ctx context.Context, userID int64, permission string) bool {
+	return domain.RoleAllows(h.adminRole(ctx, userID), permission)
+}
+
+// allowed checks the permission for a bot command. An admin without it is told so;
+// anyone else is ignored, as if the command did not exist.
+func (h *Handler) allowed(ctx context.Context, b *bot.Bot, userID int64, permission string) bool {
+	role := h.adminRole(ctx, userID)
+	if role == "" {
+		return false
+	}
+	if !domain.RoleAllows(role, permission) {
+		h.sendHint(ctx, b, userID, i18n.T(h.userLang(ctx, userID), "admin.access_denied"))
+		return false
+	}
+	return true
+}
+
+// adminRecipients returns the admins whose role grants the permission, the owner first
+func (h *Handler) adminRecipients(ctx context.Context, permission string) []int64 {
+	ids := []int64{h.cfg.AdminID}
+	admins, err := h.repo.GetAdmins(ctx)
+	if err != nil {
+		h.logger.Error("Failed to get admins", zap.Error(err))
+		return ids
+	}
+	for _, a := range admins {
+		if a.UserID != h.cfg.AdminID && domain.RoleAllows(a.Role, permission) {
+			ids = append(ids, a.UserID)
+		}
+	}
+	return ids
+}
+
+// notifyAdmins sends a notification to every admin whose role grants the permission,
+// each in their own language
+func (h *Handler) notifyAdmins(ctx context.Context, permission string, send func(chatID int64, lang string) error) {
+	for _, id := range h.adminRecipients(ctx, permission) {
+		if err := send(id, h.userLang(ctx, id)); err != nil {
+			h.logger.Warn("Failed to notify admin", zap.Int64("admin_id", id), zap.String("permission", permission), zap.Error(err))
+		}
+	}
+}
+
+// AdminsCommandHandler handles /admins, /addadmin <telegram_id> <role> [name] and
+// /deladmin <telegram_id>. Only the owner manages the team.
+func (h *Handler) AdminsCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || update.Message.From == nil {
+		return
+	}
+	ownerID := update.Message.From.ID
+	if !h.allowed(ctx, b, ownerID, domain.PermissionAdmins) {
+		return
+	}
+	lang := h.userLang(ctx, ownerID)
+
+	fields := strings.Fields(update.Message.Text)
+	var reply string
+	switch fields[0] {
+	case "/admins":
+		reply = h.adminList(ctx, lang)
+	case "/addadmin":
+		reply = h.addAdmin(ctx, b, lang, ownerID, fields[1:])
+	case "/deladmin":
+		reply = h.deleteAdmin(ctx, b, lang, fields[1:])
+	default:
+		return
+	}
+	h.sendHint(ctx, b, update.Message.Chat.ID, reply)
+}
+
+func (h *Handler) adminList(ctx context.Context, lang string) string {
+	admins, err := h.repo.GetAdmins(ctx)
+	if err != nil {
+		h.logger.Error("Failed to get admins", zap.Error(err))
+	}
+
+	lines := []string{i18n.T(lang, "admin.admins.item", i18n.Args{
+		"id":   h.cfg.AdminID,
+		"role": i18n.T(lang, "admin.role."+domain.AdminRoleOwner),
+		"name": "",
+	})}
+	for _, a := range admins {
+		if a.UserID == h.cfg.AdminID {
+			continue
+		}
+		name := ""
+		if a.Name != "" {
+			name = " · " + a.Name
+		}
+		lines = append(lines, i18n.T(lang, "admin.admins.item", i18n.Args{
+			"name": name,
+			"id":   a.UserID,
+			"role": i18n.T(lang, "admin.role."+a.Role),
+		}))
+	}
+	return i18n.T(lang, "admin.admins.title", i18n.Args{"items": strings.Join(lines, "\n")}) +
+		"\n" + i18n.T(lang, "admin.admins.help", i18n.Args{"roles": assignableRoles()})
+}
+
+func (h *Handler) addAdmin(ctx context.Context, b *bot.Bot, lang string, ownerID int64, args []string) string {
+	if len(args) < 2 {
+		return i18n.T(lang, "admin.admins.help", i18n.Args{"roles": assignableRoles()})
+	}
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || userID <= 0 {
+		return i18n.T(lang, "admin.admins.bad_id")
+	}
+	role := strings.ToLower(args[1])
+	if !domain.ValidAdminRole(role) || role == domain.AdminRoleOwner {
+		return i18n.T(lang, "admin.admins.bad_role", i18n.Args{"roles": assignableRoles()})
+	}
+	if userID == h.cfg.AdminID {
+		return i18n.T(lang, "admin.admins.owner_fixed")
+	}
+
+	admin := domain.Admin{
+		UserID:  userID,
+		Name:    strings.Join(args[2:], " "),
+		Role:    role,
+		AddedBy: ownerID,
+	}
+	if err := h.repo.SaveAdmin(ctx, admin); err != nil {
+		h.logger.Error("Failed to save admin", zap.Int64("user_id", userID), zap.Error(err))
+		return i18n.T(lang, "admin.admins.save_failed")
+	}
+	h.logger.Info("Admin saved", zap.Int64("user_id", userID), zap.String("role", role), zap.Int64("by", ownerID))
+
+	// The new admin may not have started the bot yet; then they learn it from /admin
+	adminLang := h.userLang(ctx, userID)
+	h.sendHint(ctx, b, userID, i18n.T(adminLang, "admin.admins.granted", i18n.Args{"role": i18n.T(adminLang, "admin.role."+role)}))
+
+	return i18n.T(lang, "admin.admins.saved", i18n.Args{"id": userID, "role": i18n.T(lang, "admin.role."+role)})
+}
+
+func (h *Handler) deleteAdmin(ctx context.Context, b *bot.Bot, lang string, args []string) string {
+	if len(args) != 1 {
+		return i18n.T(lang, "admin.admins.help", i18n.Args{"roles": assignableRoles()})
+	}
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return i18n.T(lang, "admin.admins.bad_id")
+	}
+	if userID == h.cfg.AdminID {
+		return i18n.T(lang, "admin.admins.owner_fixed")
+	}
+
+	removed, err := h.repo.DeleteAdmin(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to delete admin", zap.Int64("user_id", userID), zap.Error(err))
+		return i18n.T(lang, "admin.admins.save_failed")
+	}
+	if !removed {
+		return i18n.T(lang, "admin.admins.not_found", i18n.Args{"id": userID})
+	}
+	h.logger.Info("Admin removed", zap.Int64("user_id", userID))
+
+	// A broadcast or template edit in progress must not outlive the access
+	if err := h.adminFlow.Reset(ctx, userID, &flowEvent{b: b, userID: userID}); err != nil {
+		h.logger.Warn("Failed to reset admin state", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        i18n.T(h.userLang(ctx, userID), "admin.admins.revoked"),
+		ReplyMarkup: &models.ReplyKeyboardRemove{RemoveKeyboard: true},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to notify removed admin", zap.Int64("user_id", userID), zap.Error(err))
+	}
+
+	return i18n.T(lang, "admin.admins.removed", i18n.Args{"id": userID})
+}
+
+func assignableRoles() string {
+	return strings.Join(domain.AdminRoles[1:], ", ")
+}
+
+// requireAdmin checks the admin HTTP request: the admin panel runs as a Telegram Web App
+// and sends its signed init data in the "Authorization: tma <init data>" header, or in
+// the tma query parameter for links and images. It writes the error and reports false
+// when the sender is not an admin with the permission.
+func (h *Handler) requireAdmin(w http.ResponseWriter, r *http.Request, permission string) bool {
+	userID, err := webAppUserID(webAppInitData(r), h.cfg.Token, time.Now())
+	if err != nil {
+		h.logger.Warn("Admin API request rejected", zap.String("path", r.URL.Path), zap.Error(err))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Open the admin panel from Telegram",
+		})
+		return false
+	}
+
+	if !h.can(r.Context(), userID, permission) {
+		h.logger.Warn("Admin API access denied",
+			zap.Int64("user_id", userID), zap.String("path", r.URL.Path), zap.String("permission", permission))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Access denied",
+		})
+		return false
+	}
+	return true
+}
+
+// webAppInitData returns the Web App init data of the request: the "tma" Authorization
+// header of fetch calls, or the tma parameter of links and images
+func webAppInitData(r *http.Request) string {
+	initData, ok := strings.CutPrefix(r.Header.Get("Authorization"), "tma ")
+	if !ok {
+		initData = r.URL.Query().Get("tma")
+	}
+	return initData
+}
+
+// webAppUserID validates Telegram Web App init data signed with the bot token and
+// returns the ID of the user who opened the app
+func webAppUserID(initData, token string, now time.Time) (int64, error) {
+	if initData == "" {
+		return 0, errors.New("no init data")
+	}
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return 0, err
+	}
+	hash := values.Get("hash")
+	if hash == "" {
+		return 0, errors.New("init data is not signed")
+	}
+
+	pairs := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hash" {
+			pairs = append(pairs, key+"="+values.Get(key))
+		}
+	}
+	sort.Strings(pairs)
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(token))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(hash)) {
+		return 0, errors.New("init data signature mismatch")
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return 0, errors.New("init data has no auth_date")
+	}
+	if now.Sub(time.Unix(authDate, 0)) > webAppAuthMaxAge {
+		return 0, errors.New("init data expired")
+	}
+
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(values.Get("user")), &user); err != nil || user.ID == 0 {
+		return 0, errors.New("init data has no user")
+	}
+	return user.ID, nil
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBotToken = "123456:test-token"
+
+// signInitData signs Web App init data the way Telegram does
+// (https://core.telegram.org/bots/webapps#validating-data-received-via-the-mini-app)
+func signInitData(values url.Values, token string) url.Values {
+	pairs := make([]string, 0, len(values))
+	for key := range values {
+		pairs = append(pairs, key+"="+values.Get(key))
+	}
+	sort.Strings(pairs)
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(token))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+
+	signed := url.Values{}
+	for key := range values {
+		signed.Set(key, values.Get(key))
+	}
+	signed.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+	return signed
+}
+
+func TestWebAppUserID(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	initData := func(authDate time.Time) url.Values {
+		return url.Values{
+			"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+			"user":      {`{"id":42,"first_name":"Айгерим","language_code":"kk"}`},
+			"auth_date": {strconv.FormatInt(authDate.Unix(), 10)},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		initData string
+		wantID   int64
+		wantErr  bool
+	}{
+		{
+			name:     "valid hash",
+			initData: signInitData(initData(now.Add(-time.Minute)), testBotToken).Encode(),
+			wantID:   42,
+		},
+		{
+			name: "tampered user",
+			initData: func() string {
+				v := signInitData(initData(now.Add(-time.Minute)), testBotToken)
+				v.Set("user", `{"id":43,"first_name":"Айгерим","language_code":"kk"}`)
+				return v.Encode()
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "tampered auth_date",
+			initData: func() string {
+				v := signInitData(initData(now.Add(-2*webAppAuthMaxAge)), testBotToken)
+				v.Set("auth_date", strconv.FormatInt(now.Unix(), 10))
+				return v.Encode()
+			}(),
+			wantErr: true,
+		},
+		{
+			name:     "stale auth_date",
+			initData: signInitData(initData(now.Add(-webAppAuthMaxAge-time.Minute)), testBotToken).Encode(),
+			wantErr:  true,
+		},
+		{
+			name:     "missing hash",
+			initData: initData(now.Add(-time.Minute)).Encode(),
+			wantErr:  true,
+		},
+		{
+			name:     "signed with another token",
+			initData: signInitData(initData(now.Add(-time.Minute)), "654321:other-token").Encode(),
+			wantErr:  true,
+		},
+		{
+			name:     "no init data",
+			initData: "",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := webAppUserID(tt.initData, testBotToken, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("webAppUserID() = %d, want an error", id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("webAppUserID() error: %v", err)
+			}
+			if id != tt.wantID {
+				t.Errorf("webAppUserID() = %d, want %d", id, tt.wantID)
+			}
+		})
+	}
+}
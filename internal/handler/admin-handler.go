@@ -2,197 +2,256 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"meily/internal/domain"
-	"sync"
-	"sync/atomic"
+	"meily/internal/i18n"
+	"strings"
 	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"go.uber.org/zap"
-	"golang.org/x/time/rate"
 )
 
+// adminMenuButton is a button of the admin panel and the permission it needs
+type adminMenuButton struct {
+	text       string
+	permission string
+}
+
+// adminMenu is the admin panel keyboard. The button labels are routing keys registered
+// in main, so they stay bilingual instead of following the admin's language.
+var adminMenu = [][]adminMenuButton{
+	{{"💰 Ақша (Money)", domain.PermissionStats}, {"👥 Тіркелгендер (Just Clicked)", domain.PermissionStats}},
+	{{"🛍 Клиенттер (Clients)", domain.PermissionStats}, {"🎲 Лото (Loto)", domain.PermissionStats}},
+	{{"📢 Хабарлама (Messages)", domain.PermissionBroadcast}, {"🎁 Сыйлық (Gift)", domain.PermissionBroadcast}},
+	{{"📊 Статистика (Statistics)", domain.PermissionStats}, {"🚚 Курьерлер (Couriers)", domain.PermissionDelivery}},
+	{{"📤 Экспорт (Export)", domain.PermissionOrders}, {"❌ Жабу (Close)", ""}},
+}
+
+// adminKeyboard returns the admin panel with the buttons the role may use
+func adminKeyboard(role string) *models.ReplyKeyboardMarkup {
+	var rows [][]models.KeyboardButton
+	var row []models.KeyboardButton
+	for _, menuRow := range adminMenu {
+		for _, button := range menuRow {
+			if button.permission == "" || domain.RoleAllows(role, button.permission) {
+				row = append(row, models.KeyboardButton{Text: button.text})
+			}
+			if len(row) == 2 {
+				rows = append(rows, row)
+				row = nil
+			}
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return &models.ReplyKeyboardMarkup{
+		Keyboard:        rows,
+		ResizeKeyboard:  true,
+		Selective:       true,
+		OneTimeKeyboard: true,
+	}
+}
+
+// adminMenuPermission returns the permission the admin panel button needs
+func adminMenuPermission(text string) string {
+	for _, menuRow := range adminMenu {
+		for _, button := range menuRow {
+			if button.text == text {
+				return button.permission
+			}
+		}
+	}
+	return ""
+}
+
 func (h *Handler) AdminHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	if update.Message.From.ID != h.cfg.AdminID {
+	adminId := update.Message.From.ID
+	role := h.adminRole(ctx, adminId)
+	if role == "" {
 		return
 	}
 
-	adminId := h.cfg.AdminID
+	lang := h.userLang(ctx, adminId)
 	h.logger.Info("Admin handler", zap.Any("update", update))
 
-	state, err := h.redisRepo.GetUserState(ctx, adminId)
-	if err != nil {
-		h.logger.Error("Failed to get admin state from Redis", zap.Error(err))
-	}
-	if state != nil && state.State == stateBroadcast {
-		h.SendMessage(ctx, b, update)
-		return
+	// While a broadcast is being prepared the admin buttons are part of it
+	if update.Message.Text != "/admin" {
+		active, err := h.adminFlow.Active(ctx, adminId)
+		if err != nil {
+			h.logger.Error("Failed to get admin state from Redis", zap.Error(err))
+		}
+		if active {
+			h.dispatchFlow(ctx, b, update, adminId)
+			return
+		}
 	}
 
-	adminKeyboard := &models.ReplyKeyboardMarkup{
-		Keyboard: [][]models.KeyboardButton{
-			{
-				{Text: "💰 Ақша (Money)"},
-				{Text: "👥 Тіркелгендер (Just Clicked)"},
-			},
-			{
-				{Text: "🛍 Клиенттер (Clients)"},
-				{Text: "🎲 Лото (Loto)"},
-			},
-			{
-				{Text: "📢 Хабарлама (Messages)"},
-				{Text: "🎁 Сыйлық (Gift)"},
-			},
-			{
-				{Text: "📊 Статистика (Statistics)"},
-				{Text: "❌ Жабу (Close)"},
-			},
-		},
-		ResizeKeyboard:  true,
-		Selective:       true,
-		OneTimeKeyboard: true,
+	keyboard := adminKeyboard(role)
+	if permission := adminMenuPermission(update.Message.Text); permission != "" && !domain.RoleAllows(role, permission) {
+		h.sendHint(ctx, b, adminId, i18n.T(lang, "admin.access_denied"))
+		return
 	}
 
 	switch update.Message.Text {
 	case "/admin":
-		newAdminState := &domain.UserState{
-			State: stateAdminPanel,
-		}
-		if err := h.redisRepo.SaveUserState(ctx, adminId, newAdminState); err != nil {
-			h.logger.Error("Failed to save admin state to Redis", zap.Error(err))
+		if err := h.adminFlow.Reset(ctx, adminId, &flowEvent{b: b, update: update, userID: adminId}); err != nil {
+			h.logger.Error("Failed to reset admin state in Redis", zap.Error(err))
 		}
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:      adminId,
-			Text:        "🔧 Админ панеліне қош келдіңіз!\n\nТаңдаңыз:",
-			ReplyMarkup: adminKeyboard,
+			Text:        i18n.T(lang, "admin.panel.welcome"),
+			ReplyMarkup: keyboard,
 		})
 		if err != nil {
 			h.logger.Error("Failed to send admin panel", zap.Error(err))
 		}
 	case "💰 Ақша (Money)":
-		h.handleMoneyStats(ctx, b)
+		h.handleMoneyStats(ctx, b, adminId)
 
 	case "👥 Тіркелгендер (Just Clicked)":
-		h.handleJustUsers(ctx, b)
+		h.handleJustUsers(ctx, b, adminId)
 
 	case "🛍 Клиенттер (Clients)":
-		h.handleClients(ctx, b)
+		h.handleClients(ctx, b, adminId)
 
 	case "🎲 Лото (Loto)":
-		h.handleLoto(ctx, b)
+		h.handleLoto(ctx, b, adminId)
 
 	case "📢 Хабарлама (Messages)":
-		h.handleBroadcastMenu(ctx, b)
+		h.handleBroadcastMenu(ctx, b, adminId)
 
 	case "🎁 Сыйлық (Gift)":
-		h.handleGift(ctx, b)
+		h.handleGift(ctx, b, adminId)
 
 	case "📊 Статистика (Statistics)":
-		h.handleStatistics(ctx, b)
+		h.handleStatistics(ctx, b, adminId)
+
+	case "🚚 Курьерлер (Couriers)":
+		h.handleCouriers(ctx, b, adminId)
+
+	case "📤 Экспорт (Export)":
+		h.handleExport(ctx, b, adminId)
 
 	case "❌ Жабу (Close)":
-		h.handleCloseAdmin(ctx, b)
+		h.handleCloseAdmin(ctx, b, adminId)
 	default:
-		if state != nil && state.State == stateAdminPanel {
-			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID:      adminId,
-				Text:        "Белгісіз команда. Төмендегі батырмаларды пайдаланыңыз:",
-				ReplyMarkup: adminKeyboard,
-			})
-			if err != nil {
-				h.logger.Error("Failed to send admin panel", zap.Error(err))
-			}
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      adminId,
+			Text:        i18n.T(lang, "admin.panel.unknown_command"),
+			ReplyMarkup: keyboard,
+		})
+		if err != nil {
+			h.logger.Error("Failed to send admin panel", zap.Error(err))
 		}
 	}
 }
 
-func (h *Handler) SendMessage(ctx context.Context, b *bot.Bot, update *models.Update) {
-	if update.Message == nil || update.Message.From.ID != h.cfg.AdminID {
-		return
-	}
+// broadcastSegmentButton is a button of the broadcast menu and the audience segment it picks
+type broadcastSegmentButton struct {
+	text    string
+	segment string
+}
 
-	adminId := h.cfg.AdminID
-	adminState, errRedis := h.redisRepo.GetUserState(ctx, adminId)
-	if errRedis != nil {
-		h.logger.Error("Failed to get admin state from Redis", zap.Error(errRedis))
-	}
+// broadcastSegmentButtons is the broadcast menu keyboard, bilingual like the admin panel
+var broadcastSegmentButtons = [][]broadcastSegmentButton{
+	{{"📢 Барлығына жіберу", domain.AudienceAll}, {"🛍 Клиенттерге жіберу", domain.AudienceBuyers}},
+	{{"🎲 Лото қатысушыларына", domain.AudienceTicketHolders}, {"🆕 Төлемегендерге", domain.AudienceNeverPaid}},
+	{{"🚚 Жеткізілмегендерге", domain.AudienceUndelivered}},
+}
 
-	if adminState == nil || adminState.State != stateBroadcast {
-		h.logger.Warn("Admin not in broadcast state",
-			zap.String("current_state", func() string {
-				if adminState == nil {
-					return "nil"
-				}
-				return adminState.State
-			}()))
+// BroadcastAudienceHandler handles the segment buttons of the broadcast menu
+func (h *Handler) BroadcastAudienceHandler(ctx context.Context, b *bot.Bot, update *models.Update, state *domain.UserState) {
+	if update.Message == nil || !h.can(ctx, update.Message.From.ID, domain.PermissionBroadcast) {
 		return
 	}
+	adminId := update.Message.From.ID
 
-	switch update.Message.Text {
-	case "📢 Барлығына жіберу":
-		h.startBroadcast(ctx, b, "all")
-		return
-	case "🛍 Клиенттерге жіберу":
-		h.startBroadcast(ctx, b, "clients")
+	if i18n.Matches("button.back", update.Message.Text) {
+		h.backToAdminPanel(ctx, b, adminId)
 		return
-	case "🎲 Лото қатысушыларына":
-		h.startBroadcast(ctx, b, "loto")
-		return
-	case "👥 Тіркелгендерге":
-		h.startBroadcast(ctx, b, "just")
-		return
-	case "🔙 Артқа (Back)":
-		if err := h.redisRepo.DeleteUserState(ctx, adminId); err != nil {
-			h.logger.Error("Failed to delete admin state from Redis", zap.Error(err))
+	}
+	for _, row := range broadcastSegmentButtons {
+		for _, button := range row {
+			if button.text == update.Message.Text {
+				h.startBroadcast(ctx, b, adminId, button.segment)
+				return
+			}
 		}
-		h.AdminHandler(ctx, b, &models.Update{
-			Message: &models.Message{
-				Text: "/admin",
-				From: &models.User{
-					ID: adminId,
-				},
+	}
+	h.unexpectedInput(ctx, &flowEvent{b: b, update: update, userID: adminId}, state, h.adminFlow.Hint(state.State))
+}
+
+// backToAdminPanel leaves the broadcast and shows the admin panel again
+func (h *Handler) backToAdminPanel(ctx context.Context, b *bot.Bot, adminId int64) {
+	if err := h.adminFlow.Reset(ctx, adminId, &flowEvent{b: b, userID: adminId}); err != nil {
+		h.logger.Error("Failed to delete admin state from Redis", zap.Error(err))
+	}
+	h.AdminHandler(ctx, b, &models.Update{
+		Message: &models.Message{
+			Text: "/admin",
+			From: &models.User{
+				ID: adminId,
 			},
-		})
+		},
+	})
+}
+
+// SendMessage saves the admin's message as a broadcast job for the audience chosen in state
+func (h *Handler) SendMessage(ctx context.Context, b *bot.Bot, update *models.Update, adminState *domain.UserState) {
+	if update.Message == nil || !h.can(ctx, update.Message.From.ID, domain.PermissionBroadcast) {
 		return
 	}
 
-	if adminState.State != stateBroadcast {
-		h.logger.Warn("Admin not in broadcast state", zap.String("current_state", adminState.State))
+	adminId := update.Message.From.ID
+	lang := h.userLang(ctx, adminId)
+	if i18n.Matches("button.back", update.Message.Text) {
+		// Back to the filters, keeping the audience built so far
+		back := *adminState
+		back.State = stateBroadcastFilter
+		if err := h.adminFlow.Transition(ctx, adminId, &back, &flowEvent{b: b, update: update, userID: adminId}); err != nil {
+			h.logger.Error("Failed to save broadcast state to Redis", zap.Error(err))
+		}
 		return
 	}
-
-	broadcastType := ""
-	if adminState != nil {
-		broadcastType = adminState.BroadCastType
+	if adminState.Audience == nil {
+		h.backToAdminPanel(ctx, b, adminId)
+		return
 	}
-	h.logger.Info("Starting broadcast", zap.String("type", broadcastType))
+
+	audience := *adminState.Audience
+	h.logger.Info("Starting broadcast", zap.Any("audience", audience))
 
 	msgType, fileId, caption := h.parseMessage(update.Message)
 
-	var userIds []int64
-	var err error
-
-	switch broadcastType {
-	case "all":
-		userIds, err = h.repo.GetAllJustUserIDs(ctx)
-	case "clients":
-		// Assuming you have this method in repository
-		userIds, err = h.repo.GetAllJustUserIDs(ctx) // For now, using same as all
-	case "loto":
-		userIds, err = h.repo.GetAllJustUserIDs(ctx) // For now, using same as all
-	case "just":
-		userIds, err = h.repo.GetAllJustUserIDs(ctx)
-	default:
-		err = fmt.Errorf("unknown broadcast type: %s", broadcastType)
+	// "#name text" sends the media library asset with the text as its caption
+	if msgType == "text" && strings.HasPrefix(caption, "#") {
+		name, text, _ := strings.Cut(caption, " ")
+		name = strings.ToLower(strings.TrimPrefix(name, "#"))
+		asset, err := h.repo.GetMediaAsset(ctx, name)
+		if err != nil {
+			h.logger.Error("Failed to get media asset", zap.String("name", name), zap.Error(err))
+		}
+		if asset == nil {
+			h.sendHint(ctx, b, adminId, i18n.T(lang, "admin.media.not_found", i18n.Args{"name": name}))
+			return
+		}
+		msgType, fileId, caption = asset.Kind, asset.FileID, strings.TrimSpace(text)
+	}
+	if !broadcastKinds[msgType] {
+		h.sendHint(ctx, b, adminId, i18n.T(lang, "admin.broadcast.unsupported"))
+		return
 	}
 
+	userIds, err := h.repo.GetAudienceUserIDs(ctx, audience)
 	if err != nil {
 		h.logger.Error("Failed to load user ids", zap.Error(err))
 		_, sendErr := b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: adminId,
-			Text:   fmt.Sprintf("❌ Қате: Пайдаланушы тізімін алу мүмкін болмады\n%s", err.Error()),
+			Text:   i18n.T(lang, "admin.broadcast.load_failed", i18n.Args{"error": err.Error()}),
 		})
 		if sendErr != nil {
 			h.logger.Error("Failed to send error message", zap.Error(sendErr))
@@ -203,7 +262,7 @@ func (h *Handler) SendMessage(ctx context.Context, b *bot.Bot, update *models.Up
 	if len(userIds) == 0 {
 		_, sendErr := b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: adminId,
-			Text:   "📭 Хабарлама жіберуге пайдаланушылар табылмады",
+			Text:   i18n.T(lang, "admin.broadcast.no_users"),
 		})
 		if sendErr != nil {
 			h.logger.Error("Failed to send no users message", zap.Error(sendErr))
@@ -211,78 +270,32 @@ func (h *Handler) SendMessage(ctx context.Context, b *bot.Bot, update *models.Up
 		return
 	}
 
-	statusMsg, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: adminId,
-		Text:   fmt.Sprintf("📤 Хабарлама жіберіліп жатыр...\n👥 Жалпы: %d пайдаланушы", len(userIds)),
-	})
+	// The worker sends the job in the background; the admin gets the panel back at once
+	jobID, err := h.repo.CreateBroadcastJob(ctx, domain.BroadcastJob{
+		AdminID:  adminId,
+		Audience: audience,
+		Kind:     msgType,
+		FileID:   fileId,
+		Text:     caption,
+	}, userIds)
 	if err != nil {
-		h.logger.Error("Failed to send status message", zap.Error(err))
+		h.logger.Error("Failed to save broadcast job", zap.Error(err))
+		h.sendHint(ctx, b, adminId, i18n.T(lang, "admin.broadcast.save_failed"))
 		return
 	}
+	h.logger.Info("Broadcast job created",
+		zap.Int64("job_id", jobID), zap.Any("audience", audience), zap.Int("total", len(userIds)))
 
-	limiter := rate.NewLimiter(rate.Every(time.Second/30), 1)
-
-	var wg sync.WaitGroup
-	var successCount, failedCount int64
-	for i := 0; i < len(userIds); i++ {
-		if err := limiter.Wait(ctx); err != nil {
-			h.logger.Error("Rate limiter wait error", zap.Error(err))
-			break
-		}
-		wg.Add(1)
-		go func(userId int64) {
-			defer wg.Done()
-			if err := h.sendToUser(ctx, b, userId, msgType, fileId, caption); err != nil {
-				atomic.AddInt64(&failedCount, 1)
-				h.logger.Warn("Failed to send message to user", zap.Int64("user", userId), zap.Error(err))
-			} else {
-				atomic.AddInt64(&successCount, 1)
-			}
-		}(userIds[i])
-	}
-
-	wg.Wait()
-	// Send final results
-	finalSuccess := atomic.LoadInt64(&successCount)
-	finalFailed := atomic.LoadInt64(&failedCount)
-	successRate := float64(finalSuccess) / float64(len(userIds)) * 100
-
-	finalText := fmt.Sprintf(`✅ ХАБАРЛАМА ЖІБЕРУ АЯҚТАЛДЫ!
-
-👥 Жалпы: %d пайдаланушы
-✅ Сәтті: %d
-❌ Қате: %d
-📊 Сәттілік: %.1f%%
-
-📋 Хабарлама түрі: %s
-⏰ Уақыт: %s`,
-		len(userIds),
-		finalSuccess,
-		finalFailed,
-		successRate,
-		h.getBroadcastTypeName(broadcastType),
-		time.Now().Format("2006-01-02 15:04:05"))
-
-	if statusMsg != nil {
-		b.EditMessageText(ctx, &bot.EditMessageTextParams{
-			ChatID:    adminId,
-			MessageID: statusMsg.ID,
-			Text:      finalText,
-		})
+	if job, err := h.repo.GetBroadcastJob(ctx, jobID); err != nil || job == nil {
+		h.logger.Error("Failed to get broadcast job", zap.Int64("job_id", jobID), zap.Error(err))
+	} else {
+		h.sendBroadcastStatus(ctx, b, adminId, job)
 	}
+	h.wakeBroadcastWorker()
 
-	// Log broadcast results
-	h.logger.Info("Broadcast completed",
-		zap.String("type", broadcastType),
-		zap.Int("total", len(userIds)),
-		zap.Int64("success", finalSuccess),
-		zap.Int64("failed", finalFailed),
-		zap.Float64("success_rate", successRate))
-
-	if err := h.redisRepo.DeleteUserState(ctx, adminId); err != nil {
+	if err := h.adminFlow.Reset(ctx, adminId, &flowEvent{b: b, update: update, userID: adminId}); err != nil {
 		h.logger.Error("Failed to delete admin state from Redis", zap.Error(err))
 	}
-	time.Sleep(2 * time.Second)
 	h.AdminHandler(ctx, b, &models.Update{
 		Message: &models.Message{
 			From: &models.User{ID: adminId},
@@ -292,92 +305,84 @@ func (h *Handler) SendMessage(ctx context.Context, b *bot.Bot, update *models.Up
 }
 
 // Helper methods for admin panel
-func (h *Handler) handleBroadcastMenu(ctx context.Context, b *bot.Bot) {
-	adminId := h.cfg.AdminID
-
-	// Get counts for each category
-	allCount, _ := h.repo.GetAllJustUserIDs(ctx)
-
-	broadcastState := &domain.UserState{
-		State: stateBroadcast,
-	}
-	if err := h.redisRepo.SaveUserState(ctx, adminId, broadcastState); err != nil {
+func (h *Handler) handleBroadcastMenu(ctx context.Context, b *bot.Bot, adminId int64) {
+	err := h.adminFlow.Transition(ctx, adminId, &domain.UserState{State: stateBroadcast}, &flowEvent{b: b, userID: adminId})
+	if err != nil {
 		h.logger.Error("Failed to save broadcast state to Redis", zap.Error(err))
 	}
+}
+
+// sendBroadcastMenu offers the audience segments with their sizes when the admin enters the broadcast
+func (h *Handler) sendBroadcastMenu(ctx context.Context, e *flowEvent, _ *domain.UserState) {
+	lang := h.userLang(ctx, e.userID)
+	counts := i18n.Args{}
+	for _, segment := range domain.AudienceSegments {
+		count, err := h.repo.CountAudience(ctx, domain.AudienceFilter{Segment: segment})
+		if err != nil {
+			h.logger.Error("Failed to count broadcast audience", zap.String("segment", segment), zap.Error(err))
+		}
+		counts[segment] = count
+	}
 
+	var rows [][]models.KeyboardButton
+	for _, segmentRow := range broadcastSegmentButtons {
+		var row []models.KeyboardButton
+		for _, button := range segmentRow {
+			row = append(row, models.KeyboardButton{Text: button.text})
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, []models.KeyboardButton{{Text: i18n.T(lang, "button.back")}})
 	broadcastKeyboard := &models.ReplyKeyboardMarkup{
-		Keyboard: [][]models.KeyboardButton{
-			{
-				{Text: "📢 Барлығына жіберу"},
-				{Text: "🛍 Клиенттерге жіберу"},
-			},
-			{
-				{Text: "🎲 Лото қатысушыларына "},
-				{Text: "👥 Тіркелгендерге"},
-			},
-			{
-				{Text: "🔙 Артқа (Back)"},
-			},
-		},
+		Keyboard:        rows,
 		ResizeKeyboard:  true,
 		OneTimeKeyboard: false,
 	}
 
-	message := fmt.Sprintf(`📢 ХАБАРЛАМА ЖІБЕРУ
-
-📊 Қол жетімді аудитория:
-• 👥 Барлық пайдаланушылар: %d
-• 🛍 Клиенттер: %d  
-• 🎲 Лото қатысушылары: %d
-• 📅 Тіркелгендер: %d
-
-⚠️ Ескерту: Хабарлама барлық таңдалған пайдаланушыларға жіберіледі. Сақ болыңыз!
+	message := i18n.T(lang, "admin.broadcast.menu", counts)
 
-Қайсы топқа хабарлама жіберуді қалайсыз?`,
-		len(allCount), len(allCount), len(allCount), len(allCount))
-
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:      adminId,
+	_, err := e.b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      e.userID,
 		Text:        message,
 		ReplyMarkup: broadcastKeyboard,
 	})
 	if err != nil {
 		h.logger.Error("Failed to send broadcast menu", zap.Error(err))
 	}
+	h.sendUnfinishedBroadcasts(ctx, e.b, e.userID)
 }
 
-func (h *Handler) startBroadcast(ctx context.Context, b *bot.Bot, broadcastType string) {
-	adminId := h.cfg.AdminID
-
-	// Set admin to broadcast state
+// startBroadcast opens the filter builder for the chosen segment
+func (h *Handler) startBroadcast(ctx context.Context, b *bot.Bot, adminId int64, segment string) {
 	broadCastState := &domain.UserState{
-		State:         stateBroadcast,
-		BroadCastType: broadcastType,
+		State:    stateBroadcastFilter,
+		Audience: &domain.AudienceFilter{Segment: segment},
 	}
-	if err := h.redisRepo.SaveUserState(ctx, adminId, broadCastState); err != nil {
+	if err := h.adminFlow.Transition(ctx, adminId, broadCastState, &flowEvent{b: b, userID: adminId}); err != nil {
 		h.logger.Error("Failed to save broadcast state to Redis", zap.Error(err))
 	}
+}
 
-	targetDescription := h.getBroadcastTypeName(broadcastType)
-
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: adminId,
-		Text: fmt.Sprintf(`📝 ХАБАРЛАМА ЖАЗУ
-
-🎯 Мақсатты аудитория: %s
-
-💡 Қолдаулатын форматтар:
-• 📝 Мәтін хабарлама
-• 📷 Фото + мәтін
-• 🎥 Видео + мәтін  
-• 📎 Файл + мәтін
-• 🎵 Аудио
-• 🎬 GIF анимация
+// sendBroadcastPrompt asks for the message once the audience is chosen
+func (h *Handler) sendBroadcastPrompt(ctx context.Context, e *flowEvent, s *domain.UserState) {
+	lang := h.userLang(ctx, e.userID)
+	if s.Audience == nil {
+		return
+	}
+	count, err := h.repo.CountAudience(ctx, *s.Audience)
+	if err != nil {
+		h.logger.Error("Failed to count broadcast audience", zap.Any("audience", s.Audience), zap.Error(err))
+	}
 
-Хабарламаңызды жіберіңіз:`, targetDescription),
+	_, err = e.b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: e.userID,
+		Text: i18n.T(lang, "admin.broadcast.compose", i18n.Args{
+			"audience": audienceName(lang, *s.Audience),
+			"count":    count,
+		}),
 		ReplyMarkup: &models.ReplyKeyboardMarkup{
 			Keyboard: [][]models.KeyboardButton{
-				{{Text: "🔙 Артқа (Back)"}},
+				{{Text: i18n.T(lang, "button.back")}},
 			},
 			ResizeKeyboard:  true,
 			OneTimeKeyboard: false,
@@ -388,42 +393,33 @@ func (h *Handler) startBroadcast(ctx context.Context, b *bot.Bot, broadcastType
 	}
 }
 
-func (h *Handler) getBroadcastTypeName(broadcastType string) string {
-	switch broadcastType {
-	case "all":
-		return "Барлық пайдаланушылар"
-	case "clients":
-		return "Барлық клиенттер"
-	case "loto":
-		return "Лото қатысушылары"
-	case "just":
-		return "Тіркелген пайдаланушылар"
-	default:
-		return "Белгісіз"
-	}
+// adminPlaceholder is the text of admin sections that are not implemented yet
+func (h *Handler) adminPlaceholder(ctx context.Context, adminID int64, titleKey string) string {
+	lang := h.userLang(ctx, adminID)
+	return i18n.T(lang, titleKey) + "\n\n" + i18n.T(lang, "admin.in_progress")
 }
 
 // Placeholder methods - implement these with actual database logic
-func (h *Handler) handleMoneyStats(ctx context.Context, b *bot.Bot) {
+func (h *Handler) handleMoneyStats(ctx context.Context, b *bot.Bot, adminID int64) {
 	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: h.cfg.AdminID,
-		Text:   "💰 АҚША СТАТИСТИКАСЫ\n\n🔧 Дамуда...",
+		ChatID: adminID,
+		Text:   h.adminPlaceholder(ctx, adminID, "admin.title.money"),
 	})
 	if err != nil {
 		h.logger.Error("Failed to send money stats", zap.Error(err))
 	}
 }
 
-func (h *Handler) handleJustUsers(ctx context.Context, b *bot.Bot) {
+func (h *Handler) handleJustUsers(ctx context.Context, b *bot.Bot, adminID int64) {
 	userIds, err := h.repo.GetAllJustUserIDs(ctx)
 	if err != nil {
 		h.logger.Error("Failed to get just users", zap.Error(err))
 		return
 	}
 
-	message := fmt.Sprintf("👥 ТІРКЕЛГЕН ПАЙДАЛАНУШЫЛАР\n\nЖалпы: %d пайдаланушы", len(userIds))
+	message := i18n.T(h.userLang(ctx, adminID), "admin.just_users", i18n.Args{"count": len(userIds)})
 	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: h.cfg.AdminID,
+		ChatID: adminID,
 		Text:   message,
 	})
 	if err != nil {
@@ -431,51 +427,57 @@ func (h *Handler) handleJustUsers(ctx context.Context, b *bot.Bot) {
 	}
 }
 
-func (h *Handler) handleClients(ctx context.Context, b *bot.Bot) {
+func (h *Handler) handleClients(ctx context.Context, b *bot.Bot, adminID int64) {
 	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: h.cfg.AdminID,
-		Text:   "🛍 КЛИЕНТТЕР\n\n🔧 Дамуда...",
+		ChatID: adminID,
+		Text:   h.adminPlaceholder(ctx, adminID, "admin.title.clients"),
 	})
 	if err != nil {
 		h.logger.Error("Failed to send clients", zap.Error(err))
 	}
 }
 
-func (h *Handler) handleLoto(ctx context.Context, b *bot.Bot) {
+func (h *Handler) handleLoto(ctx context.Context, b *bot.Bot, adminID int64) {
 	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: h.cfg.AdminID,
-		Text:   "🎲 ЛОТО\n\n🔧 Дамуда...",
+		ChatID: adminID,
+		Text:   h.adminPlaceholder(ctx, adminID, "admin.title.loto"),
 	})
 	if err != nil {
 		h.logger.Error("Failed to send loto", zap.Error(err))
 	}
 }
 
-func (h *Handler) handleGift(ctx context.Context, b *bot.Bot) {
+func (h *Handler) handleGift(ctx context.Context, b *bot.Bot, adminID int64) {
 	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: h.cfg.AdminID,
-		Text:   "🎁 СЫЙЛЫҚ\n\n🔧 Дамуда...",
+		ChatID: adminID,
+		Text:   h.adminPlaceholder(ctx, adminID, "admin.title.gift"),
 	})
 	if err != nil {
 		h.logger.Error("Failed to send gift", zap.Error(err))
 	}
 }
 
-func (h *Handler) handleStatistics(ctx context.Context, b *bot.Bot) {
+func (h *Handler) handleStatistics(ctx context.Context, b *bot.Bot, adminID int64) {
 	userIds, _ := h.repo.GetAllJustUserIDs(ctx)
 
-	message := fmt.Sprintf(`📊 ЖАЛПЫ СТАТИСТИКА
-
-👥 Жалпы пайдаланушылар: %d
-🛍 Клиенттер: 0
-🎲 Лото қатысушылары: 0
-
-📅 Соңғы жаңарту: %s`,
-		len(userIds),
-		time.Now().Format("2006-01-02 15:04:05"))
+	reminderStats, err := h.repo.GetReminderStats(ctx)
+	if err != nil {
+		h.logger.Error("Failed to get reminder stats", zap.Error(err))
+		reminderStats = &domain.ReminderStats{}
+	}
+
+	message := i18n.T(h.userLang(ctx, adminID), "admin.statistics", i18n.Args{
+		"users":        len(userIds),
+		"pending":      reminderStats.Pending,
+		"reminded":     reminderStats.Reminded,
+		"converted":    reminderStats.Converted,
+		"after_ping":   reminderStats.ConvertedAfterPing,
+		"opted_out":    reminderStats.OptedOut,
+		"updated_time": time.Now().Format("2006-01-02 15:04:05"),
+	})
 
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: h.cfg.AdminID,
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: adminID,
 		Text:   message,
 	})
 	if err != nil {
@@ -483,15 +485,15 @@ func (h *Handler) handleStatistics(ctx context.Context, b *bot.Bot) {
 	}
 }
 
-func (h *Handler) handleCloseAdmin(ctx context.Context, b *bot.Bot) {
-	if err := h.redisRepo.DeleteUserState(ctx, h.cfg.AdminID); err != nil {
+func (h *Handler) handleCloseAdmin(ctx context.Context, b *bot.Bot, adminID int64) {
+	if err := h.adminFlow.Reset(ctx, adminID, &flowEvent{b: b, userID: adminID}); err != nil {
 		h.logger.Error("Failed to delete admin state from Redis", zap.Error(err))
 	}
 
 	// Remove keyboard
 	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: h.cfg.AdminID,
-		Text:   "✅ Админ панелі жабылды",
+		ChatID: adminID,
+		Text:   i18n.T(h.userLang(ctx, adminID), "admin.panel.closed"),
 		ReplyMarkup: &models.ReplyKeyboardRemove{
 			RemoveKeyboard: true,
 		},
@@ -501,6 +503,14 @@ func (h *Handler) handleCloseAdmin(ctx context.Context, b *bot.Bot) {
 	}
 }
 
+// errUnsupportedBroadcast — sendToUser не умеет отправлять такой тип сообщения
+var errUnsupportedBroadcast = errors.New("unsupported broadcast message type")
+
+// broadcastKinds — типы сообщений, которые sendToUser умеет разослать
+var broadcastKinds = map[string]bool{
+	"text": true, "photo": true, "video": true, "document": true, "video_note": true, "audio": true,
+}
+
 // sendToUser отправляет одному пользователю указанное сообщение
 func (h *Handler) sendToUser(ctx context.Context, b *bot.Bot, chatID int64, msgType, fileID, caption string) error {
 	switch msgType {
@@ -523,7 +533,7 @@ func (h *Handler) sendToUser(ctx context.Context, b *bot.Bot, chatID int64, msgT
 		_, err := b.SendAudio(ctx, &bot.SendAudioParams{ChatID: chatID, Audio: &models.InputFileString{Data: fileID}, ProtectContent: true})
 		return err
 	default:
-		return nil
+		return fmt.Errorf("%w: %q", errUnsupportedBroadcast, msgType)
 	}
 }
 
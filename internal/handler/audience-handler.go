@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// audienceValueButtons is how many known campaigns or cities the filter offers as buttons
+	audienceValueButtons = 8
+
+	// audienceMaxPaidDays bounds the "paid within the last N days" filter
+	audienceMaxPaidDays = 365
+)
+
+// audiencePaidDays are the suggested values of the "paid within the last N days" filter
+var audiencePaidDays = []string{"7", "30", "90"}
+
+// audienceName describes the audience in one line for the admin, e.g. in the broadcast status
+func audienceName(lang string, f domain.AudienceFilter) string {
+	var parts []string
+	switch f.Segment {
+	case domain.AudienceAll, domain.AudienceBuyers, domain.AudienceTicketHolders, domain.AudienceNeverPaid, domain.AudienceUndelivered:
+		parts = append(parts, i18n.T(lang, "admin.audience."+f.Segment))
+	default:
+		parts = append(parts, i18n.T(lang, "admin.audience.unknown"))
+	}
+	if f.Source != "" {
+		parts = append(parts, i18n.T(lang, "admin.audience.source", i18n.Args{"source": f.Source}))
+	}
+	if f.City != "" {
+		parts = append(parts, i18n.T(lang, "admin.audience.city", i18n.Args{"city": f.City}))
+	}
+	if f.PaidDays > 0 {
+		parts = append(parts, i18n.T(lang, "admin.audience.paid_days", i18n.Args{"days": f.PaidDays}))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// audienceFilterKeyboard returns the buttons of the audience filter builder
+func audienceFilterKeyboard(lang string) *models.ReplyKeyboardMarkup {
+	return &models.ReplyKeyboardMarkup{
+		Keyboard: [][]models.KeyboardButton{
+			{
+				{Text: i18n.T(lang, "button.audience_source")},
+				{Text: i18n.T(lang, "button.audience_city")},
+			},
+			{
+				{Text: i18n.T(lang, "button.audience_paid_days")},
+				{Text: i18n.T(lang, "button.audience_reset")},
+			},
+			{
+				{Text: i18n.T(lang, "button.audience_continue")},
+				{Text: i18n.T(lang, "button.back")},
+			},
+		},
+		ResizeKeyboard: true,
+	}
+}
+
+// sendAudienceFilter shows the audience with its filters and the number of recipients,
+// so the admin sees who gets the message before writing it
+func (h *Handler) sendAudienceFilter(ctx context.Context, e *flowEvent, s *domain.UserState) {
+	lang := h.userLang(ctx, e.userID)
+	if s.Audience == nil {
+		return
+	}
+	count, err := h.repo.CountAudience(ctx, *s.Audience)
+	if err != nil {
+		h.logger.Error("Failed to count broadcast audience", zap.Any("audience", s.Audience), zap.Error(err))
+	}
+
+	_, err = e.b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: e.userID,
+		Text: i18n.T(lang, "admin.broadcast.filter", i18n.Args{
+			"audience": audienceName(lang, *s.Audience),
+			"count":    count,
+		}),
+		ReplyMarkup: audienceFilterKeyboard(lang),
+	})
+	if err != nil {
+		h.logger.Error("Failed to send audience filter", zap.Error(err))
+	}
+}
+
+// AudienceFilterHandler handles the filter builder: a filter button asks for its value,
+// the next message sets it, and Continue moves on to writing the message
+func (h *Handler) AudienceFilterHandler(ctx context.Context, b *bot.Bot, update *models.Update, state *domain.UserState) {
+	if update.Message == nil || !h.can(ctx, update.Message.From.ID, domain.PermissionBroadcast) {
+		return
+	}
+	adminId := update.Message.From.ID
+	lang := h.userLang(ctx, adminId)
+	e := &flowEvent{b: b, update: update, userID: adminId}
+	text := strings.TrimSpace(update.Message.Text)
+
+	if state.Audience == nil {
+		state.Audience = &domain.AudienceFilter{Segment: domain.AudienceAll}
+	}
+
+	if state.AudienceField != "" {
+		if !i18n.Matches("button.back", text) && !h.setAudienceField(ctx, b, adminId, lang, state, text) {
+			return
+		}
+		state.AudienceField = ""
+		h.saveAudience(ctx, e, state)
+		return
+	}
+
+	switch {
+	case i18n.Matches("button.audience_source", text):
+		sources, err := h.repo.GetAudienceSources(ctx, audienceValueButtons)
+		if err != nil {
+			h.logger.Error("Failed to get audience sources", zap.Error(err))
+		}
+		h.askAudienceField(ctx, e, state, domain.AudienceFieldSource, "admin.broadcast.filter.source", sources)
+	case i18n.Matches("button.audience_city", text):
+		cities, err := h.repo.GetAudienceCities(ctx, audienceValueButtons)
+		if err != nil {
+			h.logger.Error("Failed to get audience cities", zap.Error(err))
+		}
+		h.askAudienceField(ctx, e, state, domain.AudienceFieldCity, "admin.broadcast.filter.city", cities)
+	case i18n.Matches("button.audience_paid_days", text):
+		h.askAudienceField(ctx, e, state, domain.AudienceFieldPaidDays, "admin.broadcast.filter.paid_days", audiencePaidDays)
+	case i18n.Matches("button.audience_reset", text):
+		state.Audience = &domain.AudienceFilter{Segment: state.Audience.Segment}
+		h.saveAudience(ctx, e, state)
+	case i18n.Matches("button.audience_continue", text):
+		count, err := h.repo.CountAudience(ctx, *state.Audience)
+		if err != nil {
+			h.logger.Error("Failed to count broadcast audience", zap.Any("audience", state.Audience), zap.Error(err))
+			return
+		}
+		if count == 0 {
+			h.sendHint(ctx, b, adminId, i18n.T(lang, "admin.broadcast.filter.empty"))
+			return
+		}
+		next := *state
+		next.State = stateBroadcastCompose
+		if err := h.adminFlow.Transition(ctx, adminId, &next, e); err != nil {
+			h.logger.Error("Failed to save broadcast state to Redis", zap.Error(err))
+		}
+	case i18n.Matches("button.back", text):
+		if err := h.adminFlow.Transition(ctx, adminId, &domain.UserState{State: stateBroadcast}, e); err != nil {
+			h.logger.Error("Failed to save broadcast state to Redis", zap.Error(err))
+		}
+	default:
+		h.unexpectedInput(ctx, e, state, h.adminFlow.Hint(state.State))
+	}
+}
+
+// askAudienceField waits for the value of one filter, offering the known values as buttons
+func (h *Handler) askAudienceField(ctx context.Context, e *flowEvent, state *domain.UserState, field, promptKey string, values []string) {
+	lang := h.userLang(ctx, e.userID)
+	state.AudienceField = field
+	if err := h.adminFlow.Transition(ctx, e.userID, state, e); err != nil {
+		h.logger.Error("Failed to save broadcast state to Redis", zap.Error(err))
+		return
+	}
+
+	var rows [][]models.KeyboardButton
+	for i := 0; i < len(values); i += 2 {
+		row := []models.KeyboardButton{{Text: values[i]}}
+		if i+1 < len(values) {
+			row = append(row, models.KeyboardButton{Text: values[i+1]})
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, []models.KeyboardButton{
+		{Text: i18n.T(lang, "button.audience_any")},
+		{Text: i18n.T(lang, "button.back")},
+	})
+
+	_, err := e.b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      e.userID,
+		Text:        i18n.T(lang, promptKey),
+		ReplyMarkup: &models.ReplyKeyboardMarkup{Keyboard: rows, ResizeKeyboard: true},
+	})
+	if err != nil {
+		h.logger.Error("Failed to send audience filter prompt", zap.Error(err))
+	}
+}
+
+// setAudienceField sets the filter the admin was asked for; "Any" clears it.
+// It returns false when the value is invalid and the admin has to enter it again.
+func (h *Handler) setAudienceField(ctx context.Context, b *bot.Bot, adminId int64, lang string, state *domain.UserState, value string) bool {
+	if i18n.Matches("button.audience_any", value) {
+		value = ""
+	}
+	switch state.AudienceField {
+	case domain.AudienceFieldSource:
+		state.Audience.Source = value
+	case domain.AudienceFieldCity:
+		state.Audience.City = value
+	case domain.AudienceFieldPaidDays:
+		days := 0
+		if value != "" {
+			var err error
+			days, err = strconv.Atoi(value)
+			if err != nil || days <= 0 || days > audienceMaxPaidDays {
+				h.sendHint(ctx, b, adminId, i18n.T(lang, "admin.broadcast.filter.invalid_days", i18n.Args{"max": audienceMaxPaidDays}))
+				return false
+			}
+		}
+		state.Audience.PaidDays = days
+	}
+	return true
+}
+
+// saveAudience stores the changed filter, restarting the timeout, and shows the audience again
+func (h *Handler) saveAudience(ctx context.Context, e *flowEvent, state *domain.UserState) {
+	if err := h.adminFlow.Transition(ctx, e.userID, state, e); err != nil {
+		h.logger.Error("Failed to save broadcast state to Redis", zap.Error(err))
+		return
+	}
+	h.sendAudienceFilter(ctx, e, state)
+}
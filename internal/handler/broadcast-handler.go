@@ -0,0 +1,397 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	broadcastCallbackPrefix = "bcast_"
+
+	// broadcastRate is Telegram's limit of messages per second for a bot
+	broadcastRate = 30
+
+	// broadcastBatchSize recipients are sent between progress updates, so a pause or
+	// cancel takes effect within a few seconds
+	broadcastBatchSize = 100
+
+	// broadcastPollInterval picks up jobs resumed after a restart or missed wake-ups
+	broadcastPollInterval = 10 * time.Second
+
+	// broadcastRetryDelay is the pause after Telegram could not be reached
+	broadcastRetryDelay = 5 * time.Second
+
+	// broadcastMaxAttempts is how many times a recipient is tried while Telegram cannot
+	// be reached before they are counted as failed
+	broadcastMaxAttempts = 10
+)
+
+// wakeBroadcastWorker tells the worker a job was created or resumed
+func (h *Handler) wakeBroadcastWorker() {
+	select {
+	case h.broadcastWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartBroadcastWorker sends running broadcast jobs one after another. The result of
+// every recipient is saved, so a job interrupted by a restart goes on with the users who
+// have not got it yet. A message in flight at the moment of the crash may arrive twice.
+func (h *Handler) StartBroadcastWorker(ctx context.Context, b *bot.Bot) {
+	ticker := time.NewTicker(broadcastPollInterval)
+	defer ticker.Stop()
+	limiter := rate.NewLimiter(rate.Limit(broadcastRate), 1)
+
+	h.logger.Info("Broadcast worker started", zap.Int("rate", broadcastRate))
+	for {
+		h.runBroadcastJobs(ctx, b, limiter)
+		select {
+		case <-ctx.Done():
+			h.logger.Info("Broadcast worker stopped")
+			return
+		case <-ticker.C:
+		case <-h.broadcastWake:
+		}
+	}
+}
+
+func (h *Handler) runBroadcastJobs(ctx context.Context, b *bot.Bot, limiter *rate.Limiter) {
+	jobs, err := h.repo.GetUnfinishedBroadcastJobs(ctx)
+	if err != nil {
+		h.logger.Error("Failed to load broadcast jobs", zap.Error(err))
+		return
+	}
+	for _, job := range jobs {
+		if job.Status == domain.BroadcastRunning {
+			h.runBroadcastJob(ctx, b, limiter, job.ID)
+		}
+	}
+}
+
+// runBroadcastJob sends the job batch by batch until nobody is pending or the admin
+// pauses or cancels it
+func (h *Handler) runBroadcastJob(ctx context.Context, b *bot.Bot, limiter *rate.Limiter, id int64) {
+	for ctx.Err() == nil {
+		job, err := h.repo.GetBroadcastJob(ctx, id)
+		if err != nil {
+			h.logger.Error("Failed to get broadcast job", zap.Int64("job_id", id), zap.Error(err))
+			return
+		}
+		if job == nil || job.Status != domain.BroadcastRunning {
+			return
+		}
+		if job.Pending == 0 {
+			h.finishBroadcastJob(ctx, b, job)
+			return
+		}
+
+		userIDs, err := h.repo.GetPendingBroadcastRecipients(ctx, id, broadcastBatchSize)
+		if err != nil {
+			h.logger.Error("Failed to get broadcast recipients", zap.Int64("job_id", id), zap.Error(err))
+			return
+		}
+		results, wait := h.sendBroadcastBatch(ctx, b, limiter, job, userIDs)
+		if err := h.repo.SaveBroadcastResults(ctx, id, results, broadcastMaxAttempts); err != nil {
+			h.logger.Error("Failed to save broadcast results", zap.Int64("job_id", id), zap.Error(err))
+			return
+		}
+
+		if job, err = h.repo.GetBroadcastJob(ctx, id); err == nil && job != nil {
+			h.updateBroadcastStatus(ctx, b, job)
+		}
+		if wait > 0 {
+			h.logger.Warn("Broadcast slowed down", zap.Int64("job_id", id), zap.Duration("wait", wait))
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// sendBroadcastBatch sends the job to the users at the broadcast rate. Users Telegram
+// asked to retry later are left out of the results and stay pending, users it could not
+// be reached for get a retry result; wait is how long to hold off before the next batch.
+func (h *Handler) sendBroadcastBatch(ctx context.Context, b *bot.Bot, limiter *rate.Limiter, job *domain.BroadcastJob, userIDs []int64) ([]domain.BroadcastResult, time.Duration) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []domain.BroadcastResult
+		wait    time.Duration
+	)
+	for _, userID := range userIDs {
+		if err := limiter.Wait(ctx); err != nil {
+			break
+		}
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			err := h.sendToUser(ctx, b, userID, job.Kind, job.FileID, job.Text)
+			res, retryAfter := broadcastResult(userID, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if retryAfter > 0 {
+				wait = max(wait, retryAfter)
+			}
+			if retryAfter > 0 && !res.Retry {
+				return
+			}
+			if res.Retry {
+				h.logger.Warn("Failed to reach Telegram for broadcast, will retry",
+					zap.Int64("job_id", job.ID), zap.Int64("user", userID), zap.Error(err))
+			} else if res.Status != domain.BroadcastRecipientSent {
+				h.logger.Warn("Failed to send broadcast to user",
+					zap.Int64("job_id", job.ID), zap.Int64("user", userID), zap.Int("code", res.ErrorCode), zap.Error(err))
+			}
+			results = append(results, res)
+		}(userID)
+	}
+	wg.Wait()
+	return results, wait
+}
+
+// broadcastResult classifies the outcome of sending to one user. A positive retryAfter
+// means the user should get the message later: Telegram asked to slow down or could not
+// be reached. Only the latter counts as a failed attempt, marked with res.Retry.
+func broadcastResult(userID int64, err error) (domain.BroadcastResult, time.Duration) {
+	res := domain.BroadcastResult{UserID: userID, Status: domain.BroadcastRecipientFailed}
+	var tooMany *bot.TooManyRequestsError
+	switch {
+	case err == nil:
+		res.Status = domain.BroadcastRecipientSent
+		return res, 0
+	case errors.As(err, &tooMany):
+		return res, time.Duration(tooMany.RetryAfter+1) * time.Second
+	case errors.Is(err, bot.ErrorForbidden):
+		res.Status = domain.BroadcastRecipientBlocked
+		res.ErrorCode = 403
+	case errors.Is(err, bot.ErrorBadRequest):
+		res.ErrorCode = 400
+	case errors.Is(err, bot.ErrorUnauthorized):
+		res.ErrorCode = 401
+	case errors.Is(err, bot.ErrorNotFound):
+		res.ErrorCode = 404
+	case errors.Is(err, bot.ErrorConflict):
+		res.ErrorCode = 409
+	case errors.Is(err, errUnsupportedBroadcast):
+		// Retrying will not help: nobody can get this message
+	default:
+		res.Retry = true
+		res.Error = err.Error()
+		return res, broadcastRetryDelay
+	}
+	res.Error = err.Error()
+	return res, 0
+}
+
+func (h *Handler) finishBroadcastJob(ctx context.Context, b *bot.Bot, job *domain.BroadcastJob) {
+	ok, err := h.repo.SetBroadcastJobStatus(ctx, job.ID, domain.BroadcastDone, domain.BroadcastRunning)
+	if err != nil {
+		h.logger.Error("Failed to finish broadcast job", zap.Int64("job_id", job.ID), zap.Error(err))
+		return
+	}
+	if !ok {
+		return
+	}
+	job.Status = domain.BroadcastDone
+	h.updateBroadcastStatus(ctx, b, job)
+
+	h.logger.Info("Broadcast completed",
+		zap.Int64("job_id", job.ID),
+		zap.Any("audience", job.Audience),
+		zap.Int("total", job.Total),
+		zap.Int("success", job.Sent),
+		zap.Int("failed", job.Failed),
+		zap.Int("blocked", job.Blocked))
+}
+
+// broadcastStatusText shows the progress of the job, or the summary once it is done
+func (h *Handler) broadcastStatusText(lang string, job *domain.BroadcastJob) string {
+	if job.Status == domain.BroadcastDone {
+		successRate := 0.0
+		if job.Total > 0 {
+			successRate = float64(job.Sent) / float64(job.Total) * 100
+		}
+		finishedAt := time.Now()
+		if job.FinishedAt != nil {
+			finishedAt = *job.FinishedAt
+		}
+		return i18n.T(lang, "admin.broadcast.done", i18n.Args{
+			"id":      job.ID,
+			"count":   job.Total,
+			"success": job.Sent,
+			"failed":  job.Failed,
+			"blocked": job.Blocked,
+			"rate":    fmt.Sprintf("%.1f", successRate),
+			"type":    audienceName(lang, job.Audience),
+			"time":    finishedAt.In(h.cfg.Location).Format("2006-01-02 15:04:05"),
+		})
+	}
+	return i18n.T(lang, "admin.broadcast.progress", i18n.Args{
+		"id":      job.ID,
+		"status":  i18n.T(lang, "admin.broadcast.status."+job.Status),
+		"type":    audienceName(lang, job.Audience),
+		"count":   job.Total,
+		"success": job.Sent,
+		"failed":  job.Failed,
+		"blocked": job.Blocked,
+		"pending": job.Pending,
+	})
+}
+
+// broadcastControls returns the pause or resume and cancel buttons of an unfinished job
+func broadcastControls(lang string, job *domain.BroadcastJob) *models.InlineKeyboardMarkup {
+	var toggle models.InlineKeyboardButton
+	switch job.Status {
+	case domain.BroadcastRunning:
+		toggle = models.InlineKeyboardButton{
+			Text:         i18n.T(lang, "button.broadcast_pause"),
+			CallbackData: fmt.Sprintf("%spause_%d", broadcastCallbackPrefix, job.ID),
+		}
+	case domain.BroadcastPaused:
+		toggle = models.InlineKeyboardButton{
+			Text:         i18n.T(lang, "button.broadcast_resume"),
+			CallbackData: fmt.Sprintf("%sresume_%d", broadcastCallbackPrefix, job.ID),
+		}
+	default:
+		return nil
+	}
+	return &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{
+		toggle,
+		{
+			Text:         i18n.T(lang, "button.broadcast_cancel"),
+			CallbackData: fmt.Sprintf("%scancel_%d", broadcastCallbackPrefix, job.ID),
+		},
+	}}}
+}
+
+// sendBroadcastStatus sends a new status message of the job to the admin; the progress
+// is shown there from now on
+func (h *Handler) sendBroadcastStatus(ctx context.Context, b *bot.Bot, adminID int64, job *domain.BroadcastJob) {
+	lang := h.userLang(ctx, adminID)
+	params := &bot.SendMessageParams{
+		ChatID: adminID,
+		Text:   h.broadcastStatusText(lang, job),
+	}
+	if controls := broadcastControls(lang, job); controls != nil {
+		params.ReplyMarkup = controls
+	}
+	msg, err := b.SendMessage(ctx, params)
+	if err != nil {
+		h.logger.Error("Failed to send broadcast status", zap.Int64("job_id", job.ID), zap.Error(err))
+		return
+	}
+	if err := h.repo.SetBroadcastStatusMessage(ctx, job.ID, adminID, msg.ID); err != nil {
+		h.logger.Error("Failed to save broadcast status message", zap.Int64("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// updateBroadcastStatus edits the status message of the job
+func (h *Handler) updateBroadcastStatus(ctx context.Context, b *bot.Bot, job *domain.BroadcastJob) {
+	if job.StatusMessageID == 0 {
+		return
+	}
+	lang := h.userLang(ctx, job.StatusChatID)
+	params := &bot.EditMessageTextParams{
+		ChatID:    job.StatusChatID,
+		MessageID: job.StatusMessageID,
+		Text:      h.broadcastStatusText(lang, job),
+	}
+	if controls := broadcastControls(lang, job); controls != nil {
+		params.ReplyMarkup = controls
+	}
+	if _, err := b.EditMessageText(ctx, params); err != nil {
+		h.logger.Warn("Failed to update broadcast status", zap.Int64("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// sendUnfinishedBroadcasts shows the running and paused jobs when the admin opens the
+// broadcast menu, so they can be paused or cancelled after the status message scrolled away
+func (h *Handler) sendUnfinishedBroadcasts(ctx context.Context, b *bot.Bot, adminID int64) {
+	jobs, err := h.repo.GetUnfinishedBroadcastJobs(ctx)
+	if err != nil {
+		h.logger.Error("Failed to load broadcast jobs", zap.Error(err))
+		return
+	}
+	for i := range jobs {
+		h.sendBroadcastStatus(ctx, b, adminID, &jobs[i])
+	}
+}
+
+// BroadcastCallbackHandler handles the pause, resume and cancel buttons of a broadcast
+func (h *Handler) BroadcastCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	query := update.CallbackQuery
+	adminID := query.From.ID
+	lang := h.userLang(ctx, adminID)
+	answer := func(text string) {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: query.ID, Text: text})
+	}
+	if !h.can(ctx, adminID, domain.PermissionBroadcast) {
+		answer(i18n.T(lang, "admin.access_denied"))
+		return
+	}
+
+	action, rawID, _ := strings.Cut(strings.TrimPrefix(query.Data, broadcastCallbackPrefix), "_")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		answer("")
+		return
+	}
+
+	var ok bool
+	switch action {
+	case "pause":
+		ok, err = h.repo.SetBroadcastJobStatus(ctx, id, domain.BroadcastPaused, domain.BroadcastRunning)
+	case "resume":
+		ok, err = h.repo.SetBroadcastJobStatus(ctx, id, domain.BroadcastRunning, domain.BroadcastPaused)
+	case "cancel":
+		ok, err = h.repo.SetBroadcastJobStatus(ctx, id, domain.BroadcastCancelled, domain.BroadcastRunning, domain.BroadcastPaused)
+	default:
+		answer("")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to change broadcast status", zap.Int64("job_id", id), zap.String("action", action), zap.Error(err))
+		answer(i18n.T(lang, "common.error"))
+		return
+	}
+	if !ok {
+		answer(i18n.T(lang, "admin.broadcast.finished"))
+	} else {
+		answer("")
+		h.logger.Info("Broadcast status changed", zap.Int64("job_id", id), zap.String("action", action), zap.Int64("by", adminID))
+	}
+	if action == "resume" && ok {
+		h.wakeBroadcastWorker()
+	}
+
+	job, err := h.repo.GetBroadcastJob(ctx, id)
+	if err != nil || job == nil {
+		return
+	}
+	if job.StatusChatID != query.From.ID || query.Message.Message == nil || job.StatusMessageID != query.Message.Message.ID {
+		// The button is on an older status message: move the progress here
+		if msg := query.Message.Message; msg != nil {
+			if err := h.repo.SetBroadcastStatusMessage(ctx, id, msg.Chat.ID, msg.ID); err == nil {
+				job.StatusChatID, job.StatusMessageID = msg.Chat.ID, msg.ID
+			}
+		}
+	}
+	h.updateBroadcastStatus(ctx, b, job)
+}
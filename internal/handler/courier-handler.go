@@ -0,0 +1,558 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// AssignOrdersRequest is the body of /api/admin/orders/assign.
+// Either OrderIDs or Bounds (the visible map area) selects the orders.
+type AssignOrdersRequest struct {
+	CourierID int64        `json:"courierId"`
+	OrderIDs  []int64      `json:"orderIds,omitempty"`
+	City      string       `json:"city,omitempty"`
+	Bounds    *AssignBound `json:"bounds,omitempty"`
+}
+
+type AssignBound struct {
+	South float64 `json:"south"`
+	West  float64 `json:"west"`
+	North float64 `json:"north"`
+	East  float64 `json:"east"`
+}
+
+// orderStatusLabel returns a human readable order status
+func orderStatusLabel(lang, status string) string {
+	key := "status." + status
+	if label := i18n.T(lang, key); label != key {
+		return label
+	}
+	return status
+}
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            ADMIN: COURIERS AND ASSIGNMENT
+// ═══════════════════════════════════════════════════════════════════════════════
+
+func (h *Handler) handleCouriers(ctx context.Context, b *bot.Bot, adminID int64) {
+	couriers, err := h.repo.GetActiveCouriers(ctx)
+	if err != nil {
+		h.logger.Error("Failed to get couriers", zap.Error(err))
+		return
+	}
+	unassigned, err := h.repo.GetUnassignedOrders(ctx)
+	if err != nil {
+		h.logger.Error("Failed to get unassigned orders", zap.Error(err))
+		return
+	}
+
+	lang := h.userLang(ctx, adminID)
+	sb := strings.Builder{}
+	sb.WriteString(i18n.T(lang, "admin.couriers.title"))
+	if len(couriers) == 0 {
+		sb.WriteString(i18n.T(lang, "admin.couriers.none"))
+	}
+	for _, c := range couriers {
+		active, err := h.repo.GetCourierActiveOrders(ctx, c.ID)
+		if err != nil {
+			h.logger.Warn("Failed to count courier orders", zap.Int64("courier_id", c.ID), zap.Error(err))
+		}
+		sb.WriteString(i18n.T(lang, "admin.couriers.item", i18n.Args{
+			"id":    c.ID,
+			"name":  c.Name,
+			"city":  c.City,
+			"count": len(active),
+		}))
+	}
+
+	byCity := make(map[string]int)
+	for _, o := range unassigned {
+		city := o.City
+		if city == "" {
+			city = i18n.T(lang, "common.unknown_city")
+		}
+		byCity[city]++
+	}
+	cities := make([]string, 0, len(byCity))
+	for city := range byCity {
+		cities = append(cities, city)
+	}
+	sort.Strings(cities)
+
+	sb.WriteString(i18n.T(lang, "admin.couriers.unassigned", i18n.Args{"count": len(unassigned)}))
+	for _, city := range cities {
+		sb.WriteString(fmt.Sprintf("• %s: %d\n", city, byCity[city]))
+	}
+
+	sb.WriteString(i18n.T(lang, "admin.couriers.help"))
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: adminID,
+		Text:   sb.String(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to send couriers", zap.Error(err))
+	}
+}
+
+// AdminCourierCommandHandler handles /addcourier, /delcourier and /assign
+func (h *Handler) AdminCourierCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || !h.allowed(ctx, b, update.Message.From.ID, domain.PermissionDelivery) {
+		return
+	}
+
+	fields := strings.Fields(update.Message.Text)
+	if len(fields) == 0 {
+		return
+	}
+
+	lang := h.fromLang(ctx, update.Message.From)
+	var reply string
+	switch fields[0] {
+	case "/addcourier":
+		reply = h.addCourier(ctx, b, lang, fields[1:])
+	case "/delcourier":
+		reply = h.deleteCourier(ctx, lang, fields[1:])
+	case "/assign":
+		reply = h.assignOrdersCommand(ctx, b, lang, fields[1:])
+	default:
+		return
+	}
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   reply,
+	})
+	if err != nil {
+		h.logger.Error("Failed to send courier command reply", zap.Error(err))
+	}
+}
+
+func (h *Handler) addCourier(ctx context.Context, b *bot.Bot, lang string, args []string) string {
+	if len(args) < 3 {
+		return i18n.T(lang, "admin.courier.add_usage")
+	}
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return i18n.T(lang, "admin.courier.bad_telegram_id")
+	}
+
+	courierID, err := h.repo.UpsertCourier(ctx, domain.Courier{
+		UserID: userID,
+		City:   args[1],
+		Name:   strings.Join(args[2:], " "),
+	})
+	if err != nil {
+		h.logger.Error("Failed to add courier", zap.Error(err))
+		return i18n.T(lang, "admin.courier.add_failed")
+	}
+
+	courierLang := h.userLang(ctx, userID)
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        i18n.T(courierLang, "courier.registered"),
+		ReplyMarkup: courierKeyboard(courierLang),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to notify new courier", zap.Int64("user_id", userID), zap.Error(err))
+	}
+
+	return i18n.T(lang, "admin.courier.added", i18n.Args{"id": courierID})
+}
+
+func (h *Handler) deleteCourier(ctx context.Context, lang string, args []string) string {
+	if len(args) != 1 {
+		return i18n.T(lang, "admin.courier.delete_usage")
+	}
+	courierID, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		return i18n.T(lang, "admin.courier.bad_courier_id")
+	}
+	if err := h.repo.DeactivateCourier(ctx, courierID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return i18n.T(lang, "admin.courier.not_found")
+		}
+		h.logger.Error("Failed to deactivate courier", zap.Error(err))
+		return i18n.T(lang, "admin.courier.delete_failed")
+	}
+	return i18n.T(lang, "admin.courier.deleted", i18n.Args{"id": courierID})
+}
+
+func (h *Handler) assignOrdersCommand(ctx context.Context, b *bot.Bot, lang string, args []string) string {
+	if len(args) < 2 {
+		return i18n.T(lang, "admin.courier.assign_usage")
+	}
+	courierID, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		return i18n.T(lang, "admin.courier.bad_courier_id")
+	}
+	courier, err := h.repo.GetCourierByID(ctx, courierID)
+	if err != nil || !courier.Active {
+		return i18n.T(lang, "admin.courier.no_active")
+	}
+
+	var assigned int
+	if strings.HasPrefix(args[1], "#") {
+		orderIDs := make([]int64, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+			if err != nil {
+				return i18n.T(lang, "admin.courier.bad_order_id", i18n.Args{"value": arg})
+			}
+			orderIDs = append(orderIDs, id)
+		}
+		assigned, err = h.repo.AssignOrders(ctx, courierID, orderIDs)
+	} else {
+		assigned, err = h.repo.AssignOrdersByCity(ctx, courierID, strings.Join(args[1:], " "))
+	}
+	if err != nil {
+		h.logger.Error("Failed to assign orders", zap.Error(err))
+		return i18n.T(lang, "admin.courier.assign_failed")
+	}
+
+	if assigned > 0 {
+		h.notifyCourierAssigned(ctx, b, courier, assigned)
+	}
+	return i18n.T(lang, "admin.courier.assigned", i18n.Args{"name": courier.Name, "count": assigned})
+}
+
+func (h *Handler) notifyCourierAssigned(ctx context.Context, b *bot.Bot, courier *domain.Courier, count int) {
+	lang := h.userLang(ctx, courier.UserID)
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      courier.UserID,
+		Text:        i18n.T(lang, "courier.orders_assigned", i18n.Args{"count": count}),
+		ReplyMarkup: courierKeyboard(lang),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to notify courier", zap.Int64("courier_id", courier.ID), zap.Error(err))
+	}
+}
+
+// AdminCouriersHandler handles /api/admin/couriers - list of active couriers for the admin page
+func (h *Handler) AdminCouriersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	couriers, err := h.repo.GetActiveCouriers(h.ctx)
+	if err != nil {
+		h.logger.Error("Failed to get couriers", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    couriers,
+	})
+}
+
+// AssignOrdersHandler handles /api/admin/orders/assign - assign orders by ids, city or map area
+func (h *Handler) AssignOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AssignOrdersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	courier, err := h.repo.GetCourierByID(h.ctx, req.CourierID)
+	if err != nil || !courier.Active {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Active courier not found",
+		})
+		return
+	}
+
+	var assigned int
+	switch {
+	case len(req.OrderIDs) > 0:
+		assigned, err = h.repo.AssignOrders(h.ctx, courier.ID, req.OrderIDs)
+	case req.City != "":
+		assigned, err = h.repo.AssignOrdersByCity(h.ctx, courier.ID, req.City)
+	case req.Bounds != nil:
+		assigned, err = h.repo.AssignOrdersInBounds(h.ctx, courier.ID,
+			req.Bounds.South, req.Bounds.West, req.Bounds.North, req.Bounds.East)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "orderIds, city or bounds is required",
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to assign orders", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	if assigned > 0 && h.bot != nil {
+		h.notifyCourierAssigned(h.ctx, h.bot, courier, assigned)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d orders assigned", assigned),
+		Data:    map[string]int{"assigned": assigned},
+	})
+}
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            COURIER BOT MENU
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// courierKeyboard is the courier menu; its buttons are registered in every language in main
+func courierKeyboard(lang string) *models.ReplyKeyboardMarkup {
+	return &models.ReplyKeyboardMarkup{
+		Keyboard: [][]models.KeyboardButton{
+			{{Text: i18n.T(lang, "button.courier_orders")}, {Text: i18n.T(lang, "button.courier_route")}},
+		},
+		ResizeKeyboard: true,
+	}
+}
+
+func courierOrderKeyboard(lang string, order domain.CourierOrder) *models.InlineKeyboardMarkup {
+	var row []models.InlineKeyboardButton
+	if order.Status == domain.OrderStatusAssigned {
+		row = append(row, models.InlineKeyboardButton{
+			Text:         i18n.T(lang, "button.courier_pickup"),
+			CallbackData: fmt.Sprintf("courier_pickup_%d", order.OrderID),
+		})
+	}
+	row = append(row,
+		models.InlineKeyboardButton{
+			Text:         i18n.T(lang, "button.courier_delivered"),
+			CallbackData: fmt.Sprintf("courier_delivered_%d", order.OrderID),
+		},
+		models.InlineKeyboardButton{
+			Text:         i18n.T(lang, "button.courier_failed"),
+			CallbackData: fmt.Sprintf("courier_failed_%d", order.OrderID),
+		},
+	)
+	return &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{row}}
+}
+
+func formatCourierOrder(lang string, order domain.CourierOrder) string {
+	return i18n.T(lang, "courier.order", i18n.Args{
+		"id":      order.OrderID,
+		"fio":     order.Fio,
+		"phone":   order.Contact,
+		"address": order.Address,
+		"status":  orderStatusLabel(lang, order.Status),
+	})
+}
+
+// CourierMenuHandler handles /courier and the "my orders" button: lists the courier's active orders
+func (h *Handler) CourierMenuHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+
+	userID := update.Message.From.ID
+	lang := h.fromLang(ctx, update.Message.From)
+	courier, err := h.repo.GetActiveCourierByUserID(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get courier", zap.Error(err))
+		return
+	}
+	if courier == nil {
+		h.StartHandler(ctx, b, update)
+		return
+	}
+
+	orders, err := h.repo.GetCourierActiveOrders(ctx, courier.ID)
+	if err != nil {
+		h.logger.Error("Failed to get courier orders", zap.Error(err))
+		return
+	}
+
+	if len(orders) == 0 {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      userID,
+			Text:        i18n.T(lang, "courier.no_orders"),
+			ReplyMarkup: courierKeyboard(lang),
+		})
+		if err != nil {
+			h.logger.Warn("Failed to send courier orders", zap.Error(err))
+		}
+		return
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        i18n.T(lang, "courier.orders_header", i18n.Args{"name": courier.Name, "count": len(orders)}),
+		ReplyMarkup: courierKeyboard(lang),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send courier orders header", zap.Error(err))
+	}
+
+	for _, order := range orders {
+		if order.Latitude != nil && order.Longitude != nil {
+			_, err := b.SendLocation(ctx, &bot.SendLocationParams{
+				ChatID:    userID,
+				Latitude:  *order.Latitude,
+				Longitude: *order.Longitude,
+			})
+			if err != nil {
+				h.logger.Warn("Failed to send order location", zap.Int64("order_id", order.OrderID), zap.Error(err))
+			}
+		}
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      userID,
+			Text:        formatCourierOrder(lang, order),
+			ReplyMarkup: courierOrderKeyboard(lang, order),
+		})
+		if err != nil {
+			h.logger.Warn("Failed to send courier order", zap.Int64("order_id", order.OrderID), zap.Error(err))
+		}
+	}
+}
+
+// CourierCallbackHandler handles courier_pickup_<id>, courier_delivered_<id> and courier_failed_<id>
+func (h *Handler) CourierCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.CallbackQuery == nil {
+		return
+	}
+
+	lang := h.fromLang(ctx, &update.CallbackQuery.From)
+	answer := func(key string, args ...i18n.Args) {
+		_, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            i18n.T(lang, key, args...),
+		})
+		if err != nil {
+			h.logger.Warn("Failed to answer callback query", zap.Error(err))
+		}
+	}
+
+	parts := strings.Split(update.CallbackQuery.Data, "_")
+	if len(parts) != 3 {
+		answer("common.invalid_data")
+		return
+	}
+	orderID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		answer("common.invalid_data")
+		return
+	}
+
+	var status string
+	switch parts[1] {
+	case "pickup":
+		status = domain.OrderStatusPickedUp
+	case "delivered":
+		status = domain.OrderStatusDelivered
+	case "failed":
+		status = domain.OrderStatusFailed
+	default:
+		answer("common.invalid_data")
+		return
+	}
+
+	courier, err := h.repo.GetActiveCourierByUserID(ctx, update.CallbackQuery.From.ID)
+	if err != nil || courier == nil {
+		answer("courier.not_courier")
+		return
+	}
+	order, err := h.repo.GetOrderByID(ctx, orderID)
+	if err != nil || order.CourierID == nil || *order.CourierID != courier.ID {
+		answer("courier.order_not_yours")
+		return
+	}
+	if order.Status != domain.OrderStatusAssigned && order.Status != domain.OrderStatusPickedUp {
+		answer("courier.order_closed")
+		return
+	}
+
+	// Delivery is confirmed by the customer code, a doorstep photo and the courier location
+	if status == domain.OrderStatusDelivered {
+		answer("courier.enter_code")
+		h.startDeliveryProof(ctx, b, courier, order)
+		return
+	}
+
+	if err := h.repo.UpdateOrderStatus(ctx, orderID, status); err != nil {
+		h.logger.Error("Failed to update order status", zap.Int64("order_id", orderID), zap.Error(err))
+		answer("common.error")
+		return
+	}
+	order.Status = status
+	answer("status." + status)
+
+	var markup models.ReplyMarkup
+	if status == domain.OrderStatusPickedUp {
+		markup = courierOrderKeyboard(lang, *order)
+		if err := h.issueDeliveryCode(ctx, b, order, courier.ID); err != nil {
+			h.logger.Error("Failed to issue delivery code", zap.Int64("order_id", orderID), zap.Error(err))
+		}
+	}
+	if msg := update.CallbackQuery.Message.Message; msg != nil {
+		_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:      msg.Chat.ID,
+			MessageID:   msg.ID,
+			Text:        formatCourierOrder(lang, *order),
+			ReplyMarkup: markup,
+		})
+		if err != nil {
+			h.logger.Warn("Failed to edit courier order message", zap.Error(err))
+		}
+	}
+
+	if status == domain.OrderStatusFailed {
+		h.notifyOrderStatus(ctx, b, *order)
+	}
+
+	if status != domain.OrderStatusPickedUp {
+		h.notifyAdmins(ctx, domain.PermissionDelivery, func(chatID int64, lang string) error {
+			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: chatID,
+				Text: i18n.T(lang, "admin.courier_status", i18n.Args{
+					"name":   courier.Name,
+					"id":     orderID,
+					"status": orderStatusLabel(lang, status),
+				}),
+			})
+			return err
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"meily/internal/domain"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// AdminCustomersHandler handles GET /api/admin/customers.
+// Without parameters it lists phones shared by several Telegram accounts;
+// with id, phone or user_id it returns that customer's full history.
+func (h *Handler) AdminCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("id") == "" && query.Get("phone") == "" && query.Get("user_id") == "" {
+		customers, err := h.repo.GetSharedCustomers(h.ctx)
+		if err != nil {
+			h.logger.Error("Failed to get shared customers", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Database error",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: true,
+			Data:    customers,
+		})
+		return
+	}
+
+	var customerID int64
+	var err error
+	switch {
+	case query.Get("id") != "":
+		customerID, err = strconv.ParseInt(query.Get("id"), 10, 64)
+	case query.Get("phone") != "":
+		customerID, err = h.repo.GetCustomerIDByPhone(h.ctx, query.Get("phone"))
+	default:
+		var userID int64
+		if userID, err = strconv.ParseInt(query.Get("user_id"), 10, 64); err == nil {
+			customerID, err = h.repo.GetCustomerIDByUserID(h.ctx, userID)
+		}
+	}
+
+	var customer *domain.Customer
+	if err == nil {
+		customer, err = h.repo.GetCustomer(h.ctx, customerID)
+	}
+	switch {
+	case err == sql.ErrNoRows:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Customer not found",
+		})
+		return
+	case err != nil:
+		if _, ok := err.(*strconv.NumError); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Invalid customer ID",
+			})
+			return
+		}
+		h.logger.Error("Failed to get customer", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    customer,
+	})
+}
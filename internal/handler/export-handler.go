@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/internal/service"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// orderExport is a generated export file
+type orderExport struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	Rows        int
+}
+
+// parseExportQuery reads preset, format and the order filter from query parameters
+func parseExportQuery(values url.Values) (domain.OrderExportFilter, string, string, error) {
+	filter := domain.OrderExportFilter{
+		Status: values.Get("status"),
+		From:   values.Get("from"),
+		To:     values.Get("to"),
+		City:   values.Get("city"),
+	}
+	for _, date := range []string{filter.From, filter.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return filter, "", "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
+
+	preset := values.Get("preset")
+	if preset == "" {
+		preset = "default"
+	}
+	format := strings.ToLower(values.Get("format"))
+	if format == "" {
+		format = "xlsx"
+	}
+	if format != "csv" && format != "xlsx" {
+		return filter, "", "", fmt.Errorf("format must be csv or xlsx")
+	}
+	return filter, preset, format, nil
+}
+
+// buildOrderExport selects orders and renders them with the chosen column preset
+func (h *Handler) buildOrderExport(ctx context.Context, filter domain.OrderExportFilter, presetName, format string) (*orderExport, error) {
+	presets, err := service.LoadExportPresets(h.cfg.ExportPresetsFile)
+	if err != nil {
+		return nil, err
+	}
+	preset, ok := presets[presetName]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q", presetName)
+	}
+
+	orders, err := h.repo.GetOrdersForExport(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	rows := service.ExportRows(orders, preset)
+
+	export := &orderExport{
+		Filename: fmt.Sprintf("orders_%s_%s.%s", presetName, time.Now().In(h.cfg.Location).Format("20060102_1504"), format),
+		Rows:     len(orders),
+	}
+	var buf bytes.Buffer
+	if format == "csv" {
+		export.ContentType = "text/csv; charset=utf-8"
+		err = service.WriteCSV(&buf, rows, preset.Delimiter)
+	} else {
+		export.ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = service.WriteXLSX(&buf, "Orders", rows)
+	}
+	if err != nil {
+		return nil, err
+	}
+	export.Data = buf.Bytes()
+	return export, nil
+}
+
+// AdminExportOrdersHandler handles /api/admin/export/orders?format=csv|xlsx&preset=..&status=..&from=..&to=..&city=..
+func (h *Handler) AdminExportOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, preset, format, err := parseExportQuery(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	export, err := h.buildOrderExport(h.ctx, filter, preset, format)
+	if err != nil {
+		h.logger.Error("Failed to export orders", zap.String("preset", preset), zap.Error(err))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(export.Data); err != nil {
+		h.logger.Warn("Failed to write export", zap.Error(err))
+	}
+}
+
+// handleExport shows quick export buttons and the /export filter syntax to the admin
+func (h *Handler) handleExport(ctx context.Context, b *bot.Bot, adminID int64) {
+	lang := h.userLang(ctx, adminID)
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: adminID,
+		Text:   i18n.T(lang, "admin.export.menu"),
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: i18n.T(lang, "button.export_default"), CallbackData: "export_default_xlsx"}},
+				{{Text: i18n.T(lang, "button.export_courier"), CallbackData: "export_courier_xlsx"}},
+				{{Text: "📮 Kazpost (CSV)", CallbackData: "export_kazpost_csv"}},
+			},
+		},
+	})
+	if err != nil {
+		h.logger.Error("Failed to send export menu", zap.Error(err))
+	}
+}
+
+// sendOrderExport generates an export and sends it to the admin as a document
+func (h *Handler) sendOrderExport(ctx context.Context, b *bot.Bot, adminID int64, values url.Values) {
+	lang := h.userLang(ctx, adminID)
+	reply := func(text string) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: adminID,
+			Text:   text,
+		})
+		if err != nil {
+			h.logger.Error("Failed to send export reply", zap.Error(err))
+		}
+	}
+
+	filter, preset, format, err := parseExportQuery(values)
+	if err != nil {
+		reply("❌ " + err.Error())
+		return
+	}
+	export, err := h.buildOrderExport(ctx, filter, preset, format)
+	if err != nil {
+		h.logger.Error("Failed to export orders", zap.String("preset", preset), zap.Error(err))
+		reply(i18n.T(lang, "admin.export.failed", i18n.Args{"error": err.Error()}))
+		return
+	}
+
+	_, err = b.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID: adminID,
+		Document: &models.InputFileUpload{
+			Filename: export.Filename,
+			Data:     bytes.NewReader(export.Data),
+		},
+		Caption: i18n.T(lang, "admin.export.caption", i18n.Args{"count": export.Rows, "preset": preset, "format": format}),
+	})
+	if err != nil {
+		h.logger.Error("Failed to send export document", zap.Error(err))
+	}
+}
+
+// AdminExportCommandHandler handles /export key=value ... with preset, format, status, from, to and city
+func (h *Handler) AdminExportCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || !h.allowed(ctx, b, update.Message.From.ID, domain.PermissionOrders) {
+		return
+	}
+
+	values := url.Values{}
+	for _, arg := range strings.Fields(update.Message.Text)[1:] {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			continue
+		}
+		values.Set(strings.ToLower(key), value)
+	}
+	h.sendOrderExport(ctx, b, update.Message.From.ID, values)
+}
+
+// ExportCallbackHandler handles export_<preset>_<format> buttons of the export menu
+func (h *Handler) ExportCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.CallbackQuery == nil || !h.allowed(ctx, b, update.CallbackQuery.From.ID, domain.PermissionOrders) {
+		return
+	}
+
+	_, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            i18n.T(h.fromLang(ctx, &update.CallbackQuery.From), "admin.export.preparing"),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to answer callback query", zap.Error(err))
+	}
+
+	parts := strings.Split(update.CallbackQuery.Data, "_")
+	if len(parts) != 3 {
+		return
+	}
+	h.sendOrderExport(ctx, b, update.CallbackQuery.From.ID, url.Values{
+		"preset": {parts[1]},
+		"format": {parts[2]},
+	})
+}
@@ -0,0 +1,421 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/fsm"
+	"meily/internal/i18n"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// A buyer who comes back to an unfinished checkout starts over. A receipt sent
+	// after the timeout is still accepted in the start state.
+	countTimeout = time.Hour
+	paidTimeout  = 12 * time.Hour
+
+	// phoneNumberTimeout returns an idle buyer to sharing the contact
+	phoneNumberTimeout = 30 * time.Minute
+
+	// courierProofTimeout cancels a delivery confirmation the courier walked away from
+	courierProofTimeout = 2 * time.Hour
+
+	// broadcastTimeout cancels a broadcast the admin walked away from
+	broadcastTimeout = 30 * time.Minute
+)
+
+// flowEvent is what the conversation flows pass to handlers and actions
+type flowEvent struct {
+	b      *bot.Bot
+	update *models.Update // nil when the transition is not caused by an update
+	userID int64
+}
+
+type (
+	flowState  = fsm.State[*flowEvent]
+	flowInputs = map[fsm.Input]fsm.Func[*flowEvent]
+)
+
+// withUpdate adapts a bot handler to a flow handler
+func withUpdate(f bot.HandlerFunc) fsm.Func[*flowEvent] {
+	return func(ctx context.Context, e *flowEvent, _ *domain.UserState) {
+		f(ctx, e.b, e.update)
+	}
+}
+
+// withState adapts a bot handler that needs the chat state
+func withState(f func(context.Context, *bot.Bot, *models.Update, *domain.UserState)) fsm.Func[*flowEvent] {
+	return func(ctx context.Context, e *flowEvent, s *domain.UserState) {
+		f(ctx, e.b, e.update, s)
+	}
+}
+
+// initFlows declares the buyer/courier conversation and the admin conversation. The
+// admin's state is kept under its own Redis key so a broadcast never mixes with the
+// admin's own purchase.
+func (h *Handler) initFlows() {
+	userStore := fsm.Store{
+		Load:   h.redisRepo.GetUserState,
+		Save:   h.redisRepo.SaveUserState,
+		Delete: h.redisRepo.DeleteUserState,
+	}
+	h.userFlow = fsm.MustNew("user", userStore, h.unexpectedInput,
+		flowState{
+			Name: stateStart,
+			On: flowInputs{
+				fsm.Text:     withUpdate(h.StartHandler),
+				fsm.Document: withUpdate(h.JustPaid),
+			},
+			Next: []string{stateCount, statePaid, stateContact, stateCourierCode, stateCourierPhoto, stateSupport},
+			Hint: "flow.hint.start",
+		},
+		flowState{
+			Name: stateCount,
+			On: flowInputs{
+				fsm.Document: withUpdate(h.JustPaid),
+			},
+			Next:    []string{statePaid, stateContact, stateStart, stateSupport},
+			Timeout: countTimeout,
+			Hint:    "flow.hint.count",
+		},
+		flowState{
+			Name: statePaid,
+			On: flowInputs{
+				fsm.Document: withState(h.PaidHandler),
+			},
+			Next:    []string{stateCount, stateContact, stateStart, stateSupport},
+			Timeout: paidTimeout,
+			Hint:    "flow.hint.paid",
+		},
+		flowState{
+			Name: stateContact,
+			On: flowInputs{
+				fsm.Contact:  withUpdate(h.ShareContactCallbackHandler),
+				fsm.Text:     withUpdate(h.ShareContactCallbackHandler),
+				fsm.Document: withUpdate(h.ShareContactCallbackHandler),
+			},
+			Next: []string{statePhoneNumber, stateStart, stateSupport},
+			Hint: "flow.hint.contact",
+		},
+		flowState{
+			Name: statePhoneNumber,
+			On: flowInputs{
+				fsm.Text:    withState(h.PhoneVerificationHandler),
+				fsm.Contact: withState(h.PhoneVerificationHandler),
+			},
+			Next:      []string{statePhoneCode, stateContact},
+			Timeout:   phoneNumberTimeout,
+			TimeoutTo: stateContact,
+			Hint:      "flow.hint.phone_number",
+		},
+		flowState{
+			Name: statePhoneCode,
+			On: flowInputs{
+				fsm.Text:    withState(h.PhoneVerificationHandler),
+				fsm.Contact: withState(h.PhoneVerificationHandler),
+			},
+			Next:      []string{statePhoneNumber, stateContact, stateStart},
+			Timeout:   phoneCodeTTL,
+			TimeoutTo: statePhoneNumber,
+			OnTimeout: h.phoneCodeExpired,
+			Hint:      "flow.hint.phone_code",
+		},
+		flowState{
+			Name: stateCourierCode,
+			On: flowInputs{
+				fsm.Text: withState(h.CourierProofHandler),
+			},
+			Next:      []string{stateCourierPhoto, stateStart},
+			Timeout:   courierProofTimeout,
+			OnTimeout: h.courierProofExpired,
+			Hint:      "flow.hint.courier_code",
+		},
+		flowState{
+			Name: stateCourierPhoto,
+			On: flowInputs{
+				fsm.Text:     withState(h.CourierProofHandler),
+				fsm.Photo:    withState(h.CourierProofHandler),
+				fsm.Document: withState(h.CourierProofHandler),
+			},
+			Next:      []string{stateCourierLocation, stateCourierCode, stateStart},
+			Timeout:   courierProofTimeout,
+			OnTimeout: h.courierProofExpired,
+			Hint:      "flow.hint.courier_photo",
+		},
+		flowState{
+			Name: stateCourierLocation,
+			On: flowInputs{
+				fsm.Text:     withState(h.CourierProofHandler),
+				fsm.Location: withState(h.CourierProofHandler),
+			},
+			Next:      []string{stateCourierCode, stateCourierPhoto, stateStart},
+			Timeout:   courierProofTimeout,
+			OnTimeout: h.courierProofExpired,
+			Hint:      "flow.hint.courier_location",
+		},
+		flowState{
+			Name: stateSupport,
+			On: flowInputs{
+				fsm.Text:     withState(h.SupportMessageHandler),
+				fsm.Photo:    withState(h.SupportMessageHandler),
+				fsm.Video:    withState(h.SupportMessageHandler),
+				fsm.Document: withState(h.SupportMessageHandler),
+				fsm.Contact:  withState(h.SupportMessageHandler),
+				fsm.Location: withState(h.SupportMessageHandler),
+				fsm.Other:    withState(h.SupportMessageHandler),
+			},
+			Next:    []string{stateStart, stateCount},
+			Timeout: supportTimeout,
+			Hint:    "flow.hint.support",
+		},
+	)
+
+	adminStore := fsm.Store{
+		Load:   h.redisRepo.GetAdminState,
+		Save:   h.redisRepo.SaveAdminState,
+		Delete: h.redisRepo.DeleteAdminState,
+	}
+	broadcastMessage := withState(h.SendMessage)
+	templateDraft := withState(h.TemplateDraftHandler)
+	h.adminFlow = fsm.MustNew("admin", adminStore, h.unexpectedInput,
+		flowState{
+			Name: stateAdminPanel,
+			Next: []string{stateBroadcast, stateTemplateEdit},
+		},
+		flowState{
+			Name: stateBroadcast,
+			On: flowInputs{
+				fsm.Text: withState(h.BroadcastAudienceHandler),
+			},
+			Next:      []string{stateBroadcastFilter, stateAdminPanel},
+			Enter:     h.sendBroadcastMenu,
+			Timeout:   broadcastTimeout,
+			OnTimeout: h.broadcastExpired,
+			Hint:      "flow.hint.broadcast",
+		},
+		flowState{
+			Name: stateBroadcastFilter,
+			On: flowInputs{
+				fsm.Text: withState(h.AudienceFilterHandler),
+			},
+			Next:      []string{stateBroadcastCompose, stateBroadcast, stateAdminPanel},
+			Enter:     h.sendAudienceFilter,
+			Timeout:   broadcastTimeout,
+			OnTimeout: h.broadcastExpired,
+			Hint:      "flow.hint.broadcast_filter",
+		},
+		flowState{
+			Name: stateBroadcastCompose,
+			On: flowInputs{
+				fsm.Text:     broadcastMessage,
+				fsm.Photo:    broadcastMessage,
+				fsm.Video:    broadcastMessage,
+				fsm.Document: broadcastMessage,
+				fsm.Location: broadcastMessage,
+				fsm.Contact:  broadcastMessage,
+				fsm.Other:    broadcastMessage,
+			},
+			Next:      []string{stateBroadcastFilter, stateAdminPanel},
+			Enter:     h.sendBroadcastPrompt,
+			Timeout:   broadcastTimeout,
+			OnTimeout: h.broadcastExpired,
+			Hint:      "flow.hint.broadcast_compose",
+		},
+		flowState{
+			Name: stateTemplateEdit,
+			On: flowInputs{
+				fsm.Text: templateDraft,
+			},
+			Next:      []string{stateTemplateConfirm, stateAdminPanel},
+			Enter:     h.sendTemplateEditor,
+			Timeout:   templateTimeout,
+			OnTimeout: h.templateEditExpired,
+			Hint:      "flow.hint.template_edit",
+		},
+		flowState{
+			Name: stateTemplateConfirm,
+			On: flowInputs{
+				fsm.Text: templateDraft,
+			},
+			Next:      []string{stateTemplateEdit, stateAdminPanel},
+			Timeout:   templateTimeout,
+			OnTimeout: h.templateEditExpired,
+			Hint:      "flow.hint.template_confirm",
+		},
+	)
+}
+
+// FlowDiagram renders the conversation flows for the docs: "markdown" gives Mermaid
+// blocks, "dot" gives Graphviz graphs
+func FlowDiagram(format string) (string, error) {
+	h := &Handler{}
+	h.initFlows()
+
+	switch format {
+	case "markdown":
+		var sb strings.Builder
+		sb.WriteString("# Bot conversation flows\n\n")
+		sb.WriteString("Generated by `go run ./cmd -flow-diagram markdown`, do not edit.\n")
+		sb.WriteString("A state lists the input types it accepts; any other input gets a hint. Inline buttons\n")
+		sb.WriteString("and commands have their own handlers in cmd/main.go, which move the chat between states.\n")
+		for _, f := range []struct {
+			title   string
+			mermaid string
+		}{
+			{"Buyer and courier", h.userFlow.Mermaid()},
+			{"Admin", h.adminFlow.Mermaid()},
+		} {
+			fmt.Fprintf(&sb, "\n## %s\n\n```mermaid\n%s```\n", f.title, f.mermaid)
+		}
+		return sb.String(), nil
+	case "dot":
+		return h.userFlow.DOT() + "\n" + h.adminFlow.DOT(), nil
+	default:
+		return "", fmt.Errorf("unknown diagram format %q, want markdown or dot", format)
+	}
+}
+
+// flowInput classifies the update for the flow tables
+func flowInput(update *models.Update) fsm.Input {
+	if update.CallbackQuery != nil {
+		return fsm.Callback
+	}
+	msg := update.Message
+	switch {
+	case msg.Contact != nil:
+		return fsm.Contact
+	case msg.Location != nil:
+		return fsm.Location
+	case msg.Document != nil:
+		return fsm.Document
+	case len(msg.Photo) > 0:
+		return fsm.Photo
+	case msg.Video != nil:
+		return fsm.Video
+	case msg.Text != "":
+		return fsm.Text
+	default:
+		return fsm.Other
+	}
+}
+
+// dispatchFlow hands the update to the admin flow while the admin is in it, otherwise
+// to the buyer/courier flow
+func (h *Handler) dispatchFlow(ctx context.Context, b *bot.Bot, update *models.Update, userID int64) {
+	e := &flowEvent{b: b, update: update, userID: userID}
+	in := flowInput(update)
+
+	if h.adminRole(ctx, userID) != "" {
+		active, err := h.adminFlow.Active(ctx, userID)
+		if err != nil {
+			h.logger.Error("Failed to get admin state", zap.Error(err))
+		}
+		if active {
+			if err := h.adminFlow.Dispatch(ctx, userID, in, e); err != nil {
+				h.logger.Error("Failed to save admin state", zap.Error(err))
+			}
+			return
+		}
+	}
+
+	if err := h.userFlow.Dispatch(ctx, userID, in, e); err != nil {
+		h.logger.Error("Redis error, using fallback state", zap.Int64("user_id", userID), zap.Error(err))
+	}
+}
+
+// moveUser moves the user's chat to next.State. A transition the flow does not allow
+// is answered with the hint of the current state and reported as false. Redis errors
+// are only logged: the conversation goes on without a saved state.
+func (h *Handler) moveUser(ctx context.Context, b *bot.Bot, update *models.Update, userID int64, next *domain.UserState) bool {
+	e := &flowEvent{b: b, update: update, userID: userID}
+	err := h.userFlow.Transition(ctx, userID, next, e)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, fsm.ErrNotAllowed) {
+		h.logger.Error("Failed to save user state", zap.Int64("user_id", userID), zap.Error(err))
+		return true
+	}
+
+	h.logger.Warn("Unexpected transition", zap.Int64("user_id", userID), zap.Error(err))
+	cur, _ := h.userFlow.Current(ctx, userID)
+	h.unexpectedInput(ctx, e, cur, h.userFlow.Hint(cur.State))
+	return false
+}
+
+// resetUser returns the user's chat to the start state
+func (h *Handler) resetUser(ctx context.Context, b *bot.Bot, userID int64) {
+	if err := h.userFlow.Reset(ctx, userID, &flowEvent{b: b, userID: userID}); err != nil {
+		h.logger.Error("Failed to reset user state", zap.Int64("user_id", userID), zap.Error(err))
+	}
+}
+
+// saveUserState stores changed data of the chat's current state
+func (h *Handler) saveUserState(ctx context.Context, userID int64, state *domain.UserState) {
+	if err := h.userFlow.Save(ctx, userID, state); err != nil {
+		h.logger.Error("Failed to save user state", zap.Int64("user_id", userID), zap.Error(err))
+	}
+}
+
+// unexpectedInput answers input the current state does not accept with what it waits for
+func (h *Handler) unexpectedInput(ctx context.Context, e *flowEvent, s *domain.UserState, hint string) {
+	if hint == "" {
+		return
+	}
+	if e.update == nil {
+		// A transition the bot tried on its own, e.g. a courier button pressed mid-checkout
+		h.sendHint(ctx, e.b, e.userID, i18n.T(h.userLang(ctx, e.userID), hint))
+		return
+	}
+	h.logger.Info("Unexpected input",
+		zap.Int64("user_id", e.userID),
+		zap.String("state", s.State),
+		zap.Stringer("input", flowInput(e.update)))
+
+	if cq := e.update.CallbackQuery; cq != nil {
+		_, err := e.b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: cq.ID,
+			Text:            i18n.T(h.fromLang(ctx, &cq.From), hint),
+			ShowAlert:       true,
+		})
+		if err != nil {
+			h.logger.Warn("Failed to answer callback query", zap.Error(err))
+		}
+		return
+	}
+	h.sendHint(ctx, e.b, e.userID, i18n.T(h.fromLang(ctx, e.update.Message.From), hint))
+}
+
+func (h *Handler) sendHint(ctx context.Context, b *bot.Bot, userID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{ChatID: userID, Text: text}); err != nil {
+		h.logger.Warn("Failed to send hint", zap.Int64("user_id", userID), zap.Error(err))
+	}
+}
+
+func (h *Handler) phoneCodeExpired(ctx context.Context, e *flowEvent, _ *domain.UserState) {
+	h.sendPhoneText(ctx, e.b, e.userID, i18n.T(h.userLang(ctx, e.userID), "phone.code_expired"), nil)
+}
+
+func (h *Handler) courierProofExpired(ctx context.Context, e *flowEvent, s *domain.UserState) {
+	lang := h.userLang(ctx, e.userID)
+	h.sendCourierText(ctx, e.b, e.userID,
+		i18n.T(lang, "flow.timeout.courier", i18n.Args{"id": s.OrderID}), courierKeyboard(lang))
+}
+
+func (h *Handler) broadcastExpired(ctx context.Context, e *flowEvent, _ *domain.UserState) {
+	_, err := e.b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      e.userID,
+		Text:        i18n.T(h.userLang(ctx, e.userID), "flow.timeout.broadcast"),
+		ReplyMarkup: &models.ReplyKeyboardRemove{RemoveKeyboard: true},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send broadcast timeout", zap.Error(err))
+	}
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/internal/service"
+	"net/http"
+
+	"github.com/go-telegram/bot"
+	"go.uber.org/zap"
+)
+
+// OrderPinRequest is the body of POST /api/admin/orders/pin
+type OrderPinRequest struct {
+	OrderID   int64   `json:"orderId"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// SetAddressGeocoder sets the gazetteer used when the Mini App sends no coordinates
+func (h *Handler) SetAddressGeocoder(g *service.AddressGeocoder) {
+	h.addresses = g
+}
+
+// geocodeAddress looks the typed address up in the offline gazetteer
+func (h *Handler) geocodeAddress(address string) domain.AddressGeocode {
+	if h.addresses == nil {
+		return domain.AddressGeocode{Confidence: domain.GeoConfidenceNone}
+	}
+	return h.addresses.Geocode(address)
+}
+
+// notifyAdminPinNeeded asks the admin to place the pin of an order whose address was not found precisely
+func (h *Handler) notifyAdminPinNeeded(telegramID int64, fio, address string, geocode domain.AddressGeocode) {
+	if h.bot == nil {
+		return
+	}
+	h.notifyAdmins(h.ctx, domain.PermissionDelivery, func(chatID int64, lang string) error {
+		text := i18n.T(lang, "admin.pin_needed", i18n.Args{
+			"fio":        fio,
+			"id":         telegramID,
+			"address":    address,
+			"confidence": geocode.Confidence,
+		})
+		if geocode.City != "" {
+			text += "\n" + i18n.T(lang, "admin.pin_needed.city", i18n.Args{"city": geocode.City})
+		}
+		text += "\n\n" + i18n.T(lang, "admin.pin_needed.hint")
+
+		_, err := h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   text,
+		})
+		return err
+	})
+}
+
+// AdminOrderPinsHandler handles /api/admin/orders/pin:
+// GET lists orders with low-confidence coordinates, POST places the pin of an order.
+func (h *Handler) AdminOrderPinsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		requests, err := h.repo.GetPinRequests(h.ctx)
+		if err != nil {
+			h.logger.Error("Failed to get pin requests", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Database error",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: true,
+			Data:    requests,
+		})
+
+	case http.MethodPost:
+		var req OrderPinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OrderID == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Invalid request format",
+			})
+			return
+		}
+
+		err := h.repo.SetOrderPin(h.ctx, req.OrderID, req.Latitude, req.Longitude)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Order not found",
+			})
+			return
+		}
+		if err != nil {
+			h.logger.Error("Failed to set order pin", zap.Int64("order_id", req.OrderID), zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		h.updatePinnedOrderZone(req.OrderID, req.Latitude, req.Longitude)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: true,
+			Message: "Pin saved",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updatePinnedOrderZone sets the delivery zone of an order from the pin the admin placed;
+// the zone stays unknown until then
+func (h *Handler) updatePinnedOrderZone(orderID int64, latitude, longitude float64) {
+	order, err := h.repo.GetOrderByID(h.ctx, orderID)
+	if err != nil {
+		h.logger.Error("Failed to get pinned order", zap.Int64("order_id", orderID), zap.Error(err))
+		return
+	}
+	quote, zone, err := h.quoteDelivery(h.ctx, latitude, longitude)
+	if err != nil {
+		h.logger.Error("Failed to determine delivery zone", zap.Int64("order_id", orderID), zap.Error(err))
+		return
+	}
+	if quote == nil {
+		return
+	}
+	if err := h.repo.SetClientZone(h.ctx, order.UserID, zone); err != nil {
+		h.logger.Error("Failed to save client delivery zone", zap.Int64("order_id", orderID), zap.Error(err))
+	}
+}
@@ -10,13 +10,18 @@ import (
 	"math/rand"
 	"meily/config"
 	"meily/internal/domain"
+	"meily/internal/fsm"
+	"meily/internal/i18n"
 	"meily/internal/repository"
 	"meily/internal/service"
+	addressmodel "meily/internal/service/address"
+	"meily/traits/helper"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-telegram/bot"
@@ -31,6 +36,26 @@ const (
 	stateContact    string = "contact"
 	stateAdminPanel string = "admin_panel"
 	stateBroadcast  string = "broadcast"
+
+	// Broadcast audience filters and message composition after the segment is chosen
+	stateBroadcastFilter  string = "broadcast_filter"
+	stateBroadcastCompose string = "broadcast_compose"
+
+	// Courier proof-of-delivery steps
+	stateCourierCode     string = "courier_code"
+	stateCourierPhoto    string = "courier_photo"
+	stateCourierLocation string = "courier_location"
+
+	// Phone verification by SMS code when the buyer cannot share their own contact
+	statePhoneNumber string = "phone_number"
+	statePhoneCode   string = "phone_code"
+
+	// Message template editing by the admin, see /templates
+	stateTemplateEdit    string = "template_edit"
+	stateTemplateConfirm string = "template_confirm"
+
+	// Chat with support after /help or the 💬 Support button
+	stateSupport string = "support"
 )
 
 type Handler struct {
@@ -40,6 +65,14 @@ type Handler struct {
 	repo      *repository.UserRepository
 	redisRepo *repository.RedisRepository
 	bot       *bot.Bot // Add bot instance to handler
+	addresses *service.AddressGeocoder
+	sms       service.SMSGateway
+	langs     sync.Map // id_user -> language, see userLang
+	userFlow  *fsm.Machine[*flowEvent]
+	adminFlow *fsm.Machine[*flowEvent]
+	templates *service.MessageTemplates
+
+	broadcastWake chan struct{} // see wakeBroadcastWorker
 }
 
 // API Response structures
@@ -111,11 +144,13 @@ type LottoStats struct {
 }
 
 type GeoStats struct {
-	Almaty    int `json:"almaty"`
-	Nursultan int `json:"nursultan"`
-	Shymkent  int `json:"shymkent"`
-	Karaganda int `json:"karaganda"`
-	Others    int `json:"others"`
+	Almaty    int            `json:"almaty"`
+	Nursultan int            `json:"nursultan"`
+	Shymkent  int            `json:"shymkent"`
+	Karaganda int            `json:"karaganda"`
+	Others    int            `json:"others"`
+	Cities    map[string]int `json:"cities,omitempty"`
+	Regions   map[string]int `json:"regions,omitempty"`
 }
 
 type ClientEntryWithGeo struct {
@@ -134,13 +169,18 @@ type ClientEntryWithGeo struct {
 
 func NewHandler(cfg *config.Config, zapLogger *zap.Logger, ctx context.Context, repo *repository.UserRepository, redisRepo *repository.RedisRepository) *Handler {
 	rand.Seed(time.Now().UnixNano())
-	return &Handler{
+	h := &Handler{
 		cfg:       cfg,
 		logger:    zapLogger,
 		ctx:       ctx,
 		repo:      repo,
 		redisRepo: redisRepo,
+		templates: service.NewMessageTemplates(messageTemplateDefs),
+
+		broadcastWake: make(chan struct{}, 1),
 	}
+	h.initFlows()
+	return h
 }
 
 // SetBot sets the bot instance for the handler
@@ -148,45 +188,13 @@ func (h *Handler) SetBot(b *bot.Bot) {
 	h.bot = b
 }
 
-// 7. ADD graceful degradation for Redis failures
-func (h *Handler) getOrCreateUserState(ctx context.Context, userID int64) *domain.UserState {
-	state, err := h.redisRepo.GetUserState(ctx, userID)
-	if err != nil {
-		h.logger.Error("Redis error, using fallback state",
-			zap.Error(err),
-			zap.Int64("user_id", userID))
-
-		// Return a safe default state
-		return &domain.UserState{
-			State:  stateStart,
-			Count:  0,
-			IsPaid: false,
-		}
-	}
-
-	if state == nil {
-		state = &domain.UserState{
-			State:  stateStart,
-			Count:  0,
-			IsPaid: false,
-		}
-
-		// Try to save, but don't fail if Redis is down
-		if err := h.redisRepo.SaveUserState(ctx, userID, state); err != nil {
-			h.logger.Warn("Failed to save state to Redis, continuing with in-memory state",
-				zap.Error(err))
-		}
-	}
-
-	return state
-}
-
 func (h *Handler) JustPaid(ctx context.Context, b *bot.Bot, update *models.Update) {
+	lang := h.fromLang(ctx, update.Message.From)
 	doc := update.Message.Document
 	if !strings.EqualFold(filepath.Ext(doc.FileName), ".pdf") {
 		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "❌ Қате! Тек қана PDF форматындағы файлдарды қабылдаймыз.",
+			Text:   h.text(lang, "payment.pdf_only"),
 		})
 		return
 	}
@@ -197,8 +205,7 @@ func (h *Handler) JustPaid(ctx context.Context, b *bot.Bot, update *models.Updat
 		h.logger.Error("Failed to get file info", zap.Error(err))
 		return
 	}
-	fileUrl := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", h.cfg.Token, fileInfo.FilePath)
-	resp, err := http.Get(fileUrl)
+	resp, err := http.Get(b.FileDownloadLink(fileInfo))
 	if err != nil {
 		h.logger.Error("Failed to download file via HTTP", zap.Error(err))
 		return
@@ -235,7 +242,7 @@ func (h *Handler) JustPaid(ctx context.Context, b *bot.Bot, update *models.Updat
 	if len(result) < 4 {
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "Дұрыс емес форматтағы чек!",
+			Text:   h.text(lang, "payment.bad_receipt"),
 		})
 		return
 	}
@@ -249,7 +256,7 @@ func (h *Handler) JustPaid(ctx context.Context, b *bot.Bot, update *models.Updat
 	if !ok {
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "Чек төленіп қойылған",
+			Text:   h.text(lang, "payment.receipt_used"),
 		})
 		return
 	}
@@ -260,8 +267,6 @@ func (h *Handler) JustPaid(ctx context.Context, b *bot.Bot, update *models.Updat
 	}
 	fmt.Println(actualPrice)
 	total := actualPrice / h.cfg.Cost
-	totalLoto := total * 3
-	tickets := make([]int, 0, totalLoto)
 
 	h.logger.Info("price", zap.Any("actualPrice", actualPrice))
 	pdfData := domain.PdfResult{
@@ -281,118 +286,71 @@ func (h *Handler) JustPaid(ctx context.Context, b *bot.Bot, update *models.Updat
 		Count:  total,
 		IsPaid: true,
 	}
-	if err := h.redisRepo.SaveUserState(ctx, userID, newState); err != nil {
-		h.logger.Error("error in save newState to redis", zap.Error(err))
-
-		var errorMessage string
-		if errors.Is(err, service.ErrWrongBin) {
-			// Specific message for wrong BIN in Kazakh with emojis
-			errorMessage = "❌ Қате банк картасы! 💳\n\n" +
-				"🏦 Тек біздің серіктес банк картасымен төлем жасауға болады.\n" +
-				"📋 Дұрыс банк картасын пайдаланып қайталап көріңіз!"
-		} else if errors.Is(err, service.ErrWrongPrice) {
-			// Message for wrong price
-			errorMessage = "❌ Дұрыс емес сумма! 💰\n\n" +
-				"🔍 Төлем сомасы сәйкес келмейді.\n" +
-				"📄 Чекті қайталап тексеріп көріңіз!"
-		} else {
-			// Generic error message
-			errorMessage = "❌ Дұрыс емес PDF файл! 📄\n\n" +
-				"🔄 Қайталап көріңіз немесе жаңа чек жүктеңіз."
-		}
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: userID,
-			Text:   errorMessage,
-		})
+	if !h.moveUser(ctx, b, update, userID, newState) {
 		return
 	}
 
-	f, errFile := os.Open(savePath)
-	if errFile != nil {
-		h.logger.Error("Failed to open file on disk", zap.Error(errFile))
-	}
-
-	// Enhanced message with emojis and better formatting
-	msgText := fmt.Sprintf(
-		"✅ Сәтті төлем жасалды! 🎉\n\n"+
-			"👤 UserId: %d\n"+
-			"🧴 Косметика саны: %d\n"+
-			"💰 Төлем суммасы: %d ₸\n"+
-			"📅 Уақыт: %s\n"+
-			"📄 Чек файлы жоғарыда 👆",
-		userID,
-		total,
-		actualPrice,
-		time.Now().Format("2006-01-02 15:04:05"))
+	h.notifyReceipt(ctx, b, savePath, fileName, userID, total, actualPrice)
 
-	_, errSendToAdmin := b.SendDocument(ctx, &bot.SendDocumentParams{
-		ChatID: h.cfg.AdminID,
-		Document: &models.InputFileUpload{
-			Filename: fileName,
-			Data:     f,
-		},
-		Caption: msgText,
-	})
-	if errSendToAdmin != nil {
-		h.logger.Error("Failed to send file to admin", zap.Error(errSendToAdmin))
+	tickets, err := h.completePayment(ctx, userID, total, actualPrice, result[3], savePath)
+	if errors.Is(err, repository.ErrChargeProcessed) {
+		h.resetUser(ctx, b, userID)
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   h.text(lang, "payment.receipt_used"),
+		})
+		return
 	}
-
-	for i := 0; i < totalLoto; i++ {
-		lotoId := rand.Intn(90000000) + 10000000
-		if err := h.repo.InsertLoto(ctx, domain.LotoEntry{
-			UserID:  userID,
-			LotoID:  lotoId,
-			QR:      result[3],
-			Receipt: savePath,
-			DatePay: time.Now().Format("2006-01-02 15:04:05"),
-		}); err != nil {
-			h.logger.Error("error in insert loto", zap.Error(err))
-			return
-		}
-		tickets = append(tickets, lotoId)
+	if err != nil {
+		h.paymentFailed(ctx, b, update.Message.Chat.ID, userID, lang, result[3], err)
+		return
 	}
 
-	kb := models.ReplyKeyboardMarkup{
-		Keyboard: [][]models.KeyboardButton{
-			{
-				{
-					Text:           "📲 Контактіні бөлісу",
-					RequestContact: true,
-				},
-			},
-		},
-		ResizeKeyboard:  true,
-		OneTimeKeyboard: true,
-	}
 	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf("🎟️ Сізге берілген %d билеті:\n\n", len(tickets)))
+	sb.WriteString(i18n.T(lang, "tickets.list", i18n.Args{"count": len(tickets)}))
 	for i := 0; i < len(tickets); i++ {
 		sb.WriteString(fmt.Sprintf("•%08d\n", tickets[i]))
 	}
-	text := sb.String()
+	text := h.text(lang, "payment.receipt_accepted", i18n.Args{
+		"count":   total,
+		"amount":  helper.FormatPrice(actualPrice),
+		"tickets": strings.TrimSpace(sb.String()),
+	})
 	// Чекті сәтті қабылдады
 	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      update.Message.Chat.ID,
-		Text:        "✅ Чек PDF сәтті қабылданды!\nCізбен кері байланысқа шығу үшін төмендегі\n📲 Контактіні бөлісу түймесін 👇 міндетті басыңыз.\n\n" + text,
-		ReplyMarkup: kb,
+		Text:        text,
+		ReplyMarkup: shareContactKeyboard(lang),
 	})
 	if err != nil {
 		h.logger.Warn("Failed to send confirmation message", zap.Error(err))
 	}
 }
 
+// DefaultHandler handles updates no command or button matched: it records new users
+// and hands the update to the conversation flow of the chat
 func (h *Handler) DefaultHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	if update.Message == nil {
+	if update.CallbackQuery != nil {
+		h.dispatchFlow(ctx, b, update, update.CallbackQuery.From.ID)
 		return
 	}
-
-	var userID int64
-	if update.Message != nil {
-		userID = update.Message.From.ID
-	} else {
-		userID = update.CallbackQuery.From.ID
+	if update.InlineQuery != nil {
+		h.InlineQueryHandler(ctx, b, update)
+		return
+	}
+	if update.PreCheckoutQuery != nil {
+		h.PreCheckoutQueryHandler(ctx, b, update)
+		return
+	}
+	if update.Message == nil || update.Message.From == nil {
+		return
+	}
+	if h.handleSupportChat(ctx, b, update) {
+		return
 	}
 
+	userID := update.Message.From.ID
+
 	// Insert user if not exists
 	ok, err := h.repo.ExistsJust(ctx, userID)
 	if err != nil {
@@ -408,97 +366,130 @@ func (h *Handler) DefaultHandler(ctx context.Context, b *bot.Bot, update *models
 			h.logger.Error("Failed to insert user", zap.Error(err))
 		}
 	}
+	h.recordSource(ctx, userID, update.Message.Text)
 
-	if userID == h.cfg.AdminID {
-		var fileId string
-		switch {
-		case len(update.Message.Photo) > 0:
-			fileId = update.Message.Photo[len(update.Message.Photo)-1].FileID
-		case update.Message.Video != nil:
-			fileId = update.Message.Video.FileID
-		}
-		if fileId != "" {
-			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: h.cfg.AdminID,
-				Text:   fileId,
-			})
+	if update.Message.SuccessfulPayment != nil {
+		h.SuccessfulPaymentHandler(ctx, b, update)
+		return
+	}
+	if h.saveMediaUpload(ctx, b, update) {
+		return
+	}
+
+	h.dispatchFlow(ctx, b, update, userID)
+}
+
+// notifyReceipt sends the buyer's receipt to the admins who check payments. The file is
+// uploaded once and sent to the others by its file ID.
+func (h *Handler) notifyReceipt(ctx context.Context, b *bot.Bot, savePath, fileName string, userID int64, count, amount int) {
+	paidAt := time.Now().Format("2006-01-02 15:04:05")
+	var fileID string
+	h.notifyAdmins(ctx, domain.PermissionReceipts, func(chatID int64, lang string) error {
+		var document models.InputFile = &models.InputFileString{Data: fileID}
+		if fileID == "" {
+			f, err := os.Open(savePath)
 			if err != nil {
-				h.logger.Error("error send fileId to admin", zap.Error(err))
+				return err
 			}
+			defer f.Close()
+			document = &models.InputFileUpload{Filename: fileName, Data: f}
 		}
-	}
 
-	userState := h.getOrCreateUserState(ctx, userID)
-	if update.Message.Document != nil {
-		if userState.State != statePaid && userState.State != stateContact {
-			h.logger.Info("Document message", zap.String("user_id", strconv.FormatInt(update.Message.From.ID, 10)))
-			h.JustPaid(ctx, b, update)
-			return
+		msg, err := b.SendDocument(ctx, &bot.SendDocumentParams{
+			ChatID:   chatID,
+			Document: document,
+			Caption: i18n.T(lang, "admin.payment_received", i18n.Args{
+				"user_id": userID,
+				"count":   count,
+				"amount":  amount,
+				"time":    paidAt,
+			}),
+		})
+		if err != nil {
+			return err
 		}
-	}
+		if msg.Document != nil {
+			fileID = msg.Document.FileID
+		}
+		return nil
+	})
+}
 
-	if update.CallbackQuery != nil {
-		switch userState.State {
-		case stateStart:
-			h.StartHandler(ctx, b, update)
-		case stateCount:
-			h.CountHandler(ctx, b, update)
-		case statePaid:
-			h.PaidHandler(ctx, b, update)
-		case stateContact:
-			h.ShareContactCallbackHandler(ctx, b, update)
-		case stateAdminPanel:
-			h.AdminHandler(ctx, b, update)
-		case stateBroadcast:
-			h.SendMessage(ctx, b, update)
-		default:
-			h.StartHandler(ctx, b, update)
-		}
-		return
-	}
-
-	switch userState.State {
-	case stateStart:
-		h.StartHandler(ctx, b, update)
-	case stateCount:
-		h.CountHandler(ctx, b, update)
-	case statePaid:
-		h.PaidHandler(ctx, b, update)
-	case stateContact:
-		h.ShareContactCallbackHandler(ctx, b, update)
-	case stateAdminPanel:
-		h.AdminHandler(ctx, b, update)
-	case stateBroadcast:
-		h.SendMessage(ctx, b, update)
-	default:
-		h.StartHandler(ctx, b, update)
+// completePayment issues the lottery tickets for a paid order and records the payment
+// in one transaction. qr identifies the payment, so the same receipt or charge is never
+// counted twice: a repeat returns repository.ErrChargeProcessed.
+func (h *Handler) completePayment(ctx context.Context, userID int64, count, amount int, qr, receipt string) ([]int, error) {
+	tickets := make([]int, 0, count*domain.TicketsPerSet)
+	entries := make([]domain.LotoEntry, 0, count*domain.TicketsPerSet)
+	issued := make(map[int]bool)
+	datePay := time.Now().Format("2006-01-02 15:04:05")
+	for len(tickets) < count*domain.TicketsPerSet {
+		lotoId := rand.Intn(90000000) + 10000000
+		if issued[lotoId] {
+			continue
+		}
+		issued[lotoId] = true
+		entries = append(entries, domain.LotoEntry{
+			UserID:  userID,
+			LotoID:  lotoId,
+			QR:      qr,
+			Receipt: receipt,
+			DatePay: datePay,
+		})
+		tickets = append(tickets, lotoId)
 	}
+	if err := h.repo.InsertPaidTickets(ctx, userID, amount, count, qr, entries); err != nil {
+		return nil, err
+	}
+	h.markCheckoutConverted(ctx, userID)
+	return tickets, nil
+}
+
+// paymentFailed tells the buyer and the admins that a payment was taken but its tickets
+// could not be issued, so someone settles it by hand
+func (h *Handler) paymentFailed(ctx context.Context, b *bot.Bot, chatID, userID int64, lang, qr string, err error) {
+	h.logger.Error("Failed to issue tickets for a payment", zap.Int64("user_id", userID), zap.String("qr", qr), zap.Error(err))
+	h.resetUser(ctx, b, userID)
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   i18n.T(lang, "payment.issue_failed"),
+	}); err != nil {
+		h.logger.Warn("Failed to send payment failure message", zap.Error(err))
+	}
+	h.notifyAdmins(ctx, domain.PermissionReceipts, func(adminID int64, adminLang string) error {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: adminID,
+			Text:   i18n.T(adminLang, "admin.payment_issue_failed", i18n.Args{"user_id": userID, "qr": qr}),
+		})
+		return err
+	})
 }
 
 func (h *Handler) StartHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.Message == nil {
 		return
 	}
+	lang := h.fromLang(ctx, update.Message.From)
 
-	promoText := "18 900 теңгеге косметикалық жиынтық сатып алыңыз және сыйлықтар ұтып алыңыз!"
+	promoText := h.text(lang, "start.promo")
 
 	inlineKbd := &models.InlineKeyboardMarkup{
 		InlineKeyboard: [][]models.InlineKeyboardButton{
 			{
 				{
-					Text:         "🛍 Сатып алу",
+					Text:         i18n.T(lang, "button.buy"),
 					CallbackData: "buy_cosmetics",
 				},
 			},
+			{
+				{
+					Text:         i18n.T(lang, "button.support"),
+					CallbackData: supportCallback,
+				},
+			},
 		},
 	}
-	_, err := b.SendPhoto(ctx, &bot.SendPhotoParams{
-		ChatID:         update.Message.Chat.ID,
-		Photo:          &models.InputFileString{Data: h.cfg.StartPhotoId},
-		Caption:        promoText,
-		ReplyMarkup:    inlineKbd,
-		ProtectContent: true,
-	})
+	err := h.sendMedia(ctx, b, update.Message.Chat.ID, h.mediaAsset(ctx, mediaStartPhoto), promoText, inlineKbd)
 	if err != nil {
 		h.logger.Warn("Failed to send promo photo", zap.Error(err))
 	}
@@ -510,14 +501,18 @@ func (h *Handler) BuyCosmeticsCallbackHandler(ctx context.Context, b *bot.Bot, u
 	}
 
 	userID := update.CallbackQuery.From.ID
+	lang := h.fromLang(ctx, &update.CallbackQuery.From)
 
 	newState := &domain.UserState{
 		State:  stateCount,
 		Count:  0,
 		IsPaid: false,
 	}
-	if err := h.redisRepo.SaveUserState(ctx, userID, newState); err != nil {
-		h.logger.Error("Failed to save user state to Redis", zap.Error(err))
+	if !h.moveUser(ctx, b, update, userID, newState) {
+		return
+	}
+	if err := h.repo.StartCheckout(ctx, userID, 0, 0, time.Now()); err != nil {
+		h.logger.Error("Failed to record checkout start", zap.Error(err))
 	}
 
 	rows := make([][]models.InlineKeyboardButton, 6)
@@ -546,7 +541,7 @@ func (h *Handler) BuyCosmeticsCallbackHandler(ctx context.Context, b *bot.Bot, u
 
 	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      userID,
-		Text:        "🧴 Косметика санын таңдаңыз 🧴",
+		Text:        i18n.T(lang, "order.choose_count"),
 		ReplyMarkup: btn,
 	})
 	if err != nil {
@@ -562,12 +557,13 @@ func (h *Handler) CountHandler(ctx context.Context, b *bot.Bot, update *models.U
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
+	lang := h.fromLang(ctx, &update.CallbackQuery.From)
 
 	choice := strings.Split(update.CallbackQuery.Data, "_")
 	if len(choice) != 2 {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
-			Text:            "Неверный формат данных",
+			Text:            i18n.T(lang, "common.invalid_data"),
 		})
 		return
 	}
@@ -585,22 +581,18 @@ func (h *Handler) CountHandler(ctx context.Context, b *bot.Bot, update *models.U
 		Count:  userCount,
 		IsPaid: false,
 	}
-	if err := h.redisRepo.SaveUserState(ctx, userID, newState); err != nil {
-		h.logger.Warn("Failed to save user state in count handler", zap.Error(err))
+	if !h.moveUser(ctx, b, update, userID, newState) {
+		return
+	}
+	if err := h.repo.StartCheckout(ctx, userID, userCount, totalSum, time.Now()); err != nil {
+		h.logger.Error("Failed to record checkout start", zap.Error(err))
 	}
 
 	inlineKbd := &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{
-					Text: "💳 Төлем жасау",
-					URL:  "https://pay.kaspi.kz/pay/ndy27jz5",
-				},
-			},
-		},
+		InlineKeyboard: h.payButtons(lang, userCount),
 	}
 
-	msgTxt := fmt.Sprintf("✅ Тамаша! Енді төмендегі сілтемеге өтіп %d теңге төлем жасап, төлемді растайтын чекті PDF форматында ботқа кері жіберіңіз.", totalSum)
+	msgTxt := h.text(lang, "order.pay_prompt", i18n.Args{"amount": totalSum})
 	_, sendErr := b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      userID,
 		Text:        msgTxt,
@@ -611,16 +603,18 @@ func (h *Handler) CountHandler(ctx context.Context, b *bot.Bot, update *models.U
 	}
 }
 
-func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+// PaidHandler checks the receipt against the count the buyer chose in state
+func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Update, state *domain.UserState) {
 	if update.Message == nil || update.Message.Document == nil {
 		return
 	}
 
+	lang := h.fromLang(ctx, update.Message.From)
 	doc := update.Message.Document
 	if !strings.EqualFold(filepath.Ext(doc.FileName), ".pdf") {
 		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "❌ Қате! Тек қана PDF 📄 форматындағы файлдарды қабылдаймыз.",
+			Text:   h.text(lang, "payment.pdf_only"),
 		})
 		return
 	}
@@ -634,9 +628,7 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 		return
 	}
 
-	// Составляем URL для загрузки через HTTP
-	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", h.cfg.Token, fileInfo.FilePath)
-	resp, err := http.Get(fileURL)
+	resp, err := http.Get(b.FileDownloadLink(fileInfo))
 	if err != nil {
 		h.logger.Error("Failed to download file via HTTP", zap.Error(err))
 		return
@@ -672,7 +664,7 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 	if len(result) < 4 {
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "❌ Дұрыс емес форматтағы чек! 📄 Қайталап көріңіз.",
+			Text:   h.text(lang, "payment.bad_receipt"),
 		})
 		return
 	}
@@ -685,7 +677,7 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 	if !ok {
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "⚠️ Бұл чек бұрын төленіп қойылған! 💳 ✅",
+			Text:   h.text(lang, "payment.receipt_used"),
 		})
 		return
 	}
@@ -694,15 +686,10 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 		h.logger.Error("Failed to parse price from PDF file", zap.Error(err))
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: userID,
-			Text:   "❌ Дұрыс емес PDF файл! 📄 Қайталап көріңіз.",
+			Text:   h.text(lang, "payment.invalid_pdf"),
 		})
 		return
 	}
-	state, err := h.redisRepo.GetUserState(ctx, userID)
-	if err != nil {
-		h.logger.Error("Failed to get user state from Redis", zap.Error(err))
-		return
-	}
 	rows := make([][]models.InlineKeyboardButton, 6)
 	for i := 0; i < 6; i++ {
 		row := make([]models.InlineKeyboardButton, 5)
@@ -721,7 +708,7 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 	}
 	totalPrice := state.Count * h.cfg.Cost
 	predictedCount := actualPrice / h.cfg.Cost
-	textPrice := fmt.Sprintf("⚠️ Дұрыс емес сумма! 💰\n\n🔄 Көрсетілген сумаға сәйкес төлеңіз!\n📦 Немесе жиынтық суммасына сәйкес жиынтық санын түймелер таңдаңыз.\n\nСіздң жиынтық саны: %d", predictedCount)
+	textPrice := h.text(lang, "payment.amount_mismatch", i18n.Args{"predicted": predictedCount})
 	if totalPrice != actualPrice {
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:      userID,
@@ -730,8 +717,6 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 		})
 		return
 	}
-	totalLoto := state.Count * 3
-
 	pdf := domain.PdfResult{
 		Total:       state.Count,
 		ActualPrice: actualPrice,
@@ -744,110 +729,61 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 
 		var errorMessage string
 		if errors.Is(err, service.ErrWrongBin) {
-			// Specific message for wrong BIN in Kazakh with emojis
-			errorMessage = "❌ Қате банк картасы! 💳\n\n" +
-				"🏦 Тек біздің серіктес банк картасымен төлем жасауға болады.\n" +
-				"📋 Дұрыс банк картасын пайдаланып қайталап көріңіз!"
+			// Specific message for wrong BIN
+			errorMessage = "payment.wrong_bin"
 		} else if errors.Is(err, service.ErrWrongPrice) {
 			// Message for wrong price
-			errorMessage = "❌ Дұрыс емес сумма! 💰\n\n" +
-				"🔍 Төлем сомасы сәйкес келмейді.\n" +
-				"📄 Чекті қайталап тексеріп көріңіз!"
+			errorMessage = "payment.wrong_price"
 		} else {
 			// Generic error message
-			errorMessage = "❌ Дұрыс емес PDF файл! 📄\n\n" +
-				"🔄 Қайталап көріңіз немесе жаңа чек жүктеңіз."
+			errorMessage = "payment.invalid_pdf"
 		}
 		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: userID,
-			Text:   errorMessage,
+			Text:   h.text(lang, errorMessage),
 		})
 		return
 	}
 
-	if state != nil {
-		state.IsPaid = true
-		state.State = stateContact
-		if err := h.redisRepo.SaveUserState(ctx, userID, state); err != nil {
-			h.logger.Error("Failed to save user state to Redis", zap.Error(err))
-		}
+	state.IsPaid = true
+	state.State = stateContact
+	if !h.moveUser(ctx, b, update, userID, state) {
+		return
 	}
 
-	tickets := make([]int, 0, totalLoto)
-	for i := 0; i < totalLoto; i++ {
-		lotoId := rand.Intn(90000000) + 10000000
-		if err := h.repo.InsertLoto(ctx, domain.LotoEntry{
-			UserID:  userID,
-			LotoID:  lotoId,
-			QR:      result[3],
-			Receipt: savePath,
-			DatePay: time.Now().Format("2006-01-02 15:04:05"),
-		}); err != nil {
-			h.logger.Error("error in insert loto", zap.Error(err))
-			return
-		}
-		tickets = append(tickets, lotoId)
+	tickets, err := h.completePayment(ctx, userID, state.Count, actualPrice, result[3], savePath)
+	if errors.Is(err, repository.ErrChargeProcessed) {
+		h.resetUser(ctx, b, userID)
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   h.text(lang, "payment.receipt_used"),
+		})
+		return
 	}
-
-	f, errFile := os.Open(savePath)
-	if errFile != nil {
-		h.logger.Error("Failed to open file on disk", zap.Error(errFile))
-	}
-
-	// Enhanced message with emojis and better formatting
-	msgText := fmt.Sprintf(
-		"✅ Сәтті төлем жасалды! 🎉\n\n"+
-			"👤 UserId: %d\n"+
-			"🧴 Косметика саны: %d\n"+
-			"💰 Төлем суммасы: %d ₸\n"+
-			"📅 Уақыт: %s\n"+
-			"📄 Чек файлы жоғарыда 👆",
-		userID,
-		state.Count,
-		actualPrice,
-		time.Now().Format("2006-01-02 15:04:05"))
-
-	_, errSendToAdmin := b.SendDocument(ctx, &bot.SendDocumentParams{
-		ChatID: h.cfg.AdminID,
-		Document: &models.InputFileUpload{
-			Filename: fileName,
-			Data:     f,
-		},
-		Caption: msgText,
-	})
-	if errSendToAdmin != nil {
-		h.logger.Error("Failed to send file to admin", zap.Error(errSendToAdmin))
+	if err != nil {
+		h.paymentFailed(ctx, b, update.Message.Chat.ID, userID, lang, result[3], err)
+		return
 	}
 
-	kb := models.ReplyKeyboardMarkup{
-		Keyboard: [][]models.KeyboardButton{
-			{
-				{
-					Text:           "📲 Контактіні бөлісу",
-					RequestContact: true,
-				},
-			},
-		},
-		ResizeKeyboard:  true,
-		OneTimeKeyboard: true,
-	}
+	h.notifyReceipt(ctx, b, savePath, fileName, userID, state.Count, actualPrice)
+
 	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf("🎟️ Сізге берілген %d билеті:\n\n", len(tickets)))
+	sb.WriteString(i18n.T(lang, "tickets.list", i18n.Args{"count": len(tickets)}))
 	for i := 0; i < len(tickets); i++ {
 		sb.WriteString(fmt.Sprintf("🎫 %08d\n", tickets[i]))
 	}
-	text := sb.String()
 
 	// Enhanced success message with more emojis
-	successMessage := "✅ Чек PDF сәтті қабылданды! 🎉\n\n" +
-		"📞 Сізбен кері байланысқа шығу үшін төмендегі\n" +
-		"📲 Контактіні бөлісу түймесін 👇 міндетті басыңыз.\n\n" +
-		"🎊 Сіз лотереяға қатысасыз! 🍀\n\n" + text
+	successMessage := h.text(lang, "payment.receipt_accepted_lottery", i18n.Args{
+		"count":   state.Count,
+		"amount":  helper.FormatPrice(actualPrice),
+		"tickets": strings.TrimSpace(sb.String()),
+	})
 
 	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      update.Message.Chat.ID,
 		Text:        successMessage,
-		ReplyMarkup: kb,
+		ReplyMarkup: shareContactKeyboard(lang),
 	})
 	if err != nil {
 		h.logger.Warn("Failed to send confirmation message", zap.Error(err))
@@ -860,24 +796,13 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 	}
 
 	userId := update.Message.From.ID
+	lang := h.fromLang(ctx, update.Message.From)
 
 	if update.Message.Contact == nil {
-		kb := models.ReplyKeyboardMarkup{
-			Keyboard: [][]models.KeyboardButton{
-				{
-					{
-						Text:           "📲 Контактіні бөлісу",
-						RequestContact: true,
-					},
-				},
-			},
-			ResizeKeyboard:  true,
-			OneTimeKeyboard: true,
-		}
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:      userId,
-			Text:        "Cізбен кері байланысқа шығу үшін контактіні 📲 бөлісу түймесін басыңыз.",
-			ReplyMarkup: kb,
+			Text:        h.text(lang, "contact.prompt"),
+			ReplyMarkup: shareContactKeyboard(lang),
 		})
 		if err != nil {
 			h.logger.Warn("Failed to answer callback query", zap.Error(err))
@@ -886,7 +811,25 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 		return
 	}
 
-	state, err := h.redisRepo.GetUserState(ctx, userId)
+	// A forwarded contact card would make someone else's phone the buyer's
+	if update.Message.Contact.UserID != userId {
+		h.logger.Warn("Rejected contact of another user",
+			zap.Int64("user_id", userId),
+			zap.Int64("contact_user_id", update.Message.Contact.UserID))
+		h.sendForeignContactRejected(ctx, b, userId, lang)
+		return
+	}
+
+	h.completeContact(ctx, b, update.Message.From, update.Message.Chat.ID, update.Message.Contact.PhoneNumber, domain.PhoneVerifiedTelegram)
+}
+
+// completeContact saves the verified phone of the buyer and asks for the delivery address
+func (h *Handler) completeContact(ctx context.Context, b *bot.Bot, from *models.User, chatID int64, phone, verifiedBy string) {
+	userId := from.ID
+	lang := h.fromLang(ctx, from)
+	contact := helper.NormalizePhone(phone)
+
+	state, err := h.userFlow.Current(ctx, userId)
 	if err != nil {
 		h.logger.Error("Failed to get user state from Redis", zap.Error(err))
 		state = &domain.UserState{
@@ -895,17 +838,11 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 			IsPaid: true,
 		}
 	}
-
-	if state != nil {
-		state.Contact = update.Message.Contact.PhoneNumber
-		if err := h.redisRepo.SaveUserState(ctx, userId, state); err != nil {
-			h.logger.Error("Failed to save user state to Redis", zap.Error(err))
-		}
-	}
+	state.Contact = contact
 
 	// FIX: Use state data safely with nil checks
 	userData := fmt.Sprintf("UserID: %d, State: %s, Count: %d, IsPaid: %t, Contact: %s",
-		update.Message.From.ID,
+		userId,
 		func() string {
 			if state != nil {
 				return state.State
@@ -937,7 +874,7 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 		InlineKeyboard: [][]models.InlineKeyboardButton{
 			{
 				{
-					Text: "📍 Мекен-жайды енгізу",
+					Text: i18n.T(lang, "button.enter_address"),
 					URL:  "https://t.me/meilly_cosmetics_bot/MeiLyCosmetics", // Direct static URL
 				},
 			},
@@ -951,38 +888,28 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 	}
 
 	entry := domain.ClientEntry{
-		UserID:       userId,
-		UserName:     update.Message.From.FirstName,
-		Fio:          sql.NullString{},
-		Contact:      state.Contact,
-		Address:      sql.NullString{},
-		DateRegister: sql.NullString{},
-		DatePay:      time.Now().Format("2006-01-02 15:04:05"),
-		Checks:       false,
+		UserID:          userId,
+		UserName:        from.FirstName,
+		Fio:             sql.NullString{},
+		Contact:         contact,
+		Address:         sql.NullString{},
+		DateRegister:    sql.NullString{},
+		DatePay:         time.Now().Format("2006-01-02 15:04:05"),
+		Checks:          false,
+		PhoneVerifiedBy: verifiedBy,
+		Sets:            state.Count,
 	}
 	fmt.Println(entry)
 	if err := h.repo.InsertClient(ctx, entry); err != nil {
 		h.logger.Warn("Failed to insert client", zap.Error(err))
 	}
 
-	_, err = b.SendVideo(ctx, &bot.SendVideoParams{
-		ChatID: update.Message.Chat.ID,
-		Video: &models.InputFileString{
-			Data: h.cfg.InstructorVideoId,
-		},
-		Caption: "✅ Контактіңіз сәтті алынды! 😊\n" +
-			"Косметикалық жинақты қай мекен-жайға жеткізу керек екенін көрсетіңіз. 🚚\n" +
-			"⤵️ Мекен-жайыңызды енгізу үшін батырманы басыңыз👇\nТолығырақ 📹 видео инструкцияда",
-		ReplyMarkup:    kb,
-		ProtectContent: true,
-	})
+	err = h.sendMedia(ctx, b, chatID, h.mediaAsset(ctx, mediaInstructionVideo), h.text(lang, "contact.received"), kb)
 	if err != nil {
 		h.logger.Warn("Failed to send confirmation message", zap.Error(err))
 	}
 
-	if err := h.redisRepo.DeleteUserState(ctx, userId); err != nil {
-		h.logger.Error("Failed to delete user state from Redis", zap.Error(err))
-	}
+	h.resetUser(ctx, b, userId)
 }
 
 // API Handlers
@@ -1124,11 +1051,27 @@ func (h *Handler) ClientSaveHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract form fields
 	telegramIDStr := r.FormValue("telegram_id")
 	fio := r.FormValue("fio")
-	contact := r.FormValue("contact")
+	contact := helper.NormalizePhone(r.FormValue("contact"))
 	address := r.FormValue("address")
 	latitudeStr := r.FormValue("latitude")
 	longitudeStr := r.FormValue("longitude")
 
+	// Structured address; address stays the rendered single line for older readers
+	details, structured := addressmodel.FromForm(r.Form)
+	if structured {
+		details = addressmodel.Normalize(details)
+		if err := addressmodel.Validate(details); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: err.Error(),
+				Data:    err,
+			})
+			return
+		}
+		address = addressmodel.Render(details)
+	}
+
 	// Validate required fields
 	if telegramIDStr == "" || fio == "" || contact == "" || address == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -1149,121 +1092,146 @@ func (h *Handler) ClientSaveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse coordinates
-	latitude, err := strconv.ParseFloat(latitudeStr, 64)
-	if err != nil {
-		h.logger.Warn("Invalid latitude", zap.String("latitude", latitudeStr))
-		latitude = 43.238949 // Default to Almaty
+	// Parse coordinates; without them the typed address is geocoded offline
+	// instead of pinning the order to a default point
+	latitude, latErr := strconv.ParseFloat(latitudeStr, 64)
+	longitude, lonErr := strconv.ParseFloat(longitudeStr, 64)
+	geocode := domain.AddressGeocode{Lat: latitude, Lon: longitude, Confidence: domain.GeoConfidenceGPS}
+	if latErr != nil || lonErr != nil || !repository.ValidateCoordinates(latitude, longitude) {
+		h.logger.Warn("Invalid coordinates, geocoding address",
+			zap.String("latitude", latitudeStr),
+			zap.String("longitude", longitudeStr))
+		geocode = h.geocodeAddress(address)
+		latitude, longitude = geocode.Lat, geocode.Lon
+	}
+
+	// Determine the delivery zone; a city centre says nothing about the address
+	var quote *DeliveryQuote
+	var zone *domain.DeliveryZone
+	if !geocode.NeedsPin() {
+		quote, zone, err = h.quoteDelivery(h.ctx, latitude, longitude)
+		if err != nil {
+			h.logger.Error("Failed to determine delivery zone",
+				zap.Int64("telegram_id", telegramID),
+				zap.Error(err))
+		}
 	}
-
-	longitude, err := strconv.ParseFloat(longitudeStr, 64)
-	if err != nil {
-		h.logger.Warn("Invalid longitude", zap.String("longitude", longitudeStr))
-		longitude = 76.889709 // Default to Almaty
+	if quote != nil && quote.OutOfZone && h.cfg.RejectOutOfZone {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Address is outside the delivery area",
+			Data:    quote,
+		})
+		return
 	}
 
-	// Save geolocation data with proper coordinates format
-	locationString := fmt.Sprintf("%.6f,%.6f", latitude, longitude)
-	err = h.repo.InsertGeo(h.ctx, domain.GeoEntry{
-		UserID:   telegramID,
-		Location: locationString,
-		DataReg:  time.Now().Format("2006-01-02 15:04:05"),
-	})
-	if err != nil {
-		h.logger.Error("Failed to save geo data",
-			zap.Int64("telegram_id", telegramID),
-			zap.Error(err))
+	// Update client data with delivery information and geolocation when it is confirmed
+	var latPtr, lonPtr *float64
+	if !geocode.NeedsPin() {
+		latPtr, lonPtr = &latitude, &longitude
 	}
-
-	// Update client data with delivery information
-	err = h.repo.UpdateClientDeliveryData(h.ctx, telegramID, fio, address, latitude, longitude)
+	err = h.repo.UpdateClientDeliveryData(h.ctx, telegramID, fio, address, geocode)
 	if err != nil {
 		h.logger.Error("Failed to update client delivery data",
 			zap.Int64("telegram_id", telegramID),
 			zap.Error(err))
 	}
+	if structured {
+		if err := h.repo.SetClientAddressDetails(h.ctx, telegramID, details); err != nil {
+			h.logger.Error("Failed to save structured address",
+				zap.Int64("telegram_id", telegramID),
+				zap.Error(err))
+		}
+	}
+	if geocode.NeedsPin() {
+		// The zone of the previous address no longer applies; it is set again with the pin
+		if err := h.repo.ResetClientZone(h.ctx, telegramID); err != nil {
+			h.logger.Error("Failed to reset client delivery zone",
+				zap.Int64("telegram_id", telegramID),
+				zap.Error(err))
+		}
+		go h.notifyAdminPinNeeded(telegramID, fio, address, geocode)
+	}
+
+	if quote != nil {
+		if err := h.repo.SetClientZone(h.ctx, telegramID, zone); err != nil {
+			h.logger.Error("Failed to save client delivery zone",
+				zap.Int64("telegram_id", telegramID),
+				zap.Error(err))
+		}
+		if quote.OutOfZone {
+			go h.notifyAdminOutOfZone(telegramID, fio, address, latitude, longitude)
+		}
+	}
 
 	// Send confirmation message to user via Telegram
-	go h.sendDeliveryConfirmation(telegramID, fio, contact, address, latitude, longitude)
+	go h.sendDeliveryConfirmation(telegramID, fio, contact, address, latPtr, lonPtr)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(APIResponse{
 		Success: true,
 		Message: "Data saved successfully",
+		Data:    quote,
 	})
 }
 
-// sendDeliveryConfirmation sends a confirmation message with location to the user
-func (h *Handler) sendDeliveryConfirmation(telegramID int64, fio, contact, address string, latitude, longitude float64) {
+// sendDeliveryConfirmation sends a confirmation message with location to the user.
+// The location is sent only when the coordinates are known.
+func (h *Handler) sendDeliveryConfirmation(telegramID int64, fio, contact, address string, latitude, longitude *float64) {
 	if h.bot == nil {
 		h.logger.Error("Bot instance is not set")
 		return
 	}
 
-	// Prepare confirmation message in both languages
-	confirmationTextRU := fmt.Sprintf(
-		"🎉 Ваш заказ подтвержден!\n\n"+
-			"👤 ФИО: %s\n"+
-			"📱 Контакт: %s\n"+
-			"📍 Адрес доставки: %s\n\n"+
-			"🚚 Косметический набор Meily будет доставлен по указанному адресу!\n"+
-			"📦 Ожидайте звонка курьера для уточнения времени доставки.\n\n"+
-			"💄 Спасибо за выбор Meily Cosmetics!",
-		fio, contact, address,
-	)
-
-	confirmationTextKZ := fmt.Sprintf(
-		"🎉 Тапсырысыңыз расталды!\n\n"+
-			"👤 Аты-жөні: %s\n"+
-			"📱 Байланыс: %s\n"+
-			"📍 Жеткізу мекенжайы: %s\n\n"+
-			"🚚 Meily косметикалық жинағы көрсетілген мекенжайға жеткізіледі!\n"+
-			"📦 Жеткізу уақытын нақтылау үшін курьердің қоңырауын күтіңіз.\n\n"+
-			"💄 Meily Cosmetics брендін таңдағаныңыз үшін рахмет!",
-		fio, contact, address,
-	)
+	lang := h.userLang(h.ctx, telegramID)
+	confirmationText := h.text(lang, "order.confirmed", i18n.Args{
+		"fio":     fio,
+		"contact": contact,
+		"address": address,
+	})
 
 	if err := h.repo.UpdateLotoWithLoop(h.ctx, telegramID, fio, contact, address); err != nil {
 		h.logger.Error("error update loto table", zap.Error(err))
 		h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
 			ChatID: telegramID,
-			Text:   "Сізде билет енгізгенде қате орын алды.",
+			Text:   i18n.T(lang, "order.tickets_update_failed"),
 		})
 	}
 
-	combinedText := confirmationTextRU + "\n\n" + "═══════════════════" + "\n\n" + confirmationTextKZ
-
 	// First, send the location
-	_, err := h.bot.SendLocation(h.ctx, &bot.SendLocationParams{
-		ChatID:    telegramID,
-		Latitude:  latitude,
-		Longitude: longitude,
-	})
+	if latitude != nil && longitude != nil {
+		_, err := h.bot.SendLocation(h.ctx, &bot.SendLocationParams{
+			ChatID:    telegramID,
+			Latitude:  *latitude,
+			Longitude: *longitude,
+		})
 
-	if err != nil {
-		h.logger.Error("Failed to send location",
-			zap.Int64("telegram_id", telegramID),
-			zap.Error(err))
-	} else {
-		h.logger.Info("Location sent successfully",
-			zap.Int64("telegram_id", telegramID),
-			zap.Float64("latitude", latitude),
-			zap.Float64("longitude", longitude))
+		if err != nil {
+			h.logger.Error("Failed to send location",
+				zap.Int64("telegram_id", telegramID),
+				zap.Error(err))
+		} else {
+			h.logger.Info("Location sent successfully",
+				zap.Int64("telegram_id", telegramID),
+				zap.Float64("latitude", *latitude),
+				zap.Float64("longitude", *longitude))
+		}
 	}
 	// FIXED: Use direct Mini App URL without bot username
 	kb := models.InlineKeyboardMarkup{
 		InlineKeyboard: [][]models.InlineKeyboardButton{
 			{
 				{
-					Text: "✏️ Енгізілген деректерді өзгерту.",
+					Text: i18n.T(lang, "button.edit_order"),
 					URL:  "https://t.me/meilly_cosmetics_bot/MeiLyCosmetics", // Direct static URL
 				},
 			},
 		},
 	}
-	_, err = h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
+	_, err := h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
 		ChatID:      telegramID,
-		Text:        combinedText,
+		Text:        confirmationText,
 		ReplyMarkup: kb,
 	})
 
@@ -1488,19 +1456,16 @@ func (h *Handler) AdminDashboardHandler(w http.ResponseWriter, r *http.Request)
 		Unpaid: repoLottoStats.Unpaid,
 	}
 
-	// Get REAL geo statistics by city
-	cityStatsMap, err := h.repo.GetGeoStatsByCity(h.ctx)
-	if err != nil {
-		h.logger.Error("Failed to get geo stats by city", zap.Error(err))
-		cityStatsMap = make(map[string]int)
-	}
-
+	// Get REAL geo statistics by resolved city and region
+	repoGeoStats := h.repo.GetGeoStats(h.ctx)
 	geoStats := &GeoStats{
-		Almaty:    cityStatsMap["almaty"],
-		Nursultan: cityStatsMap["nursultan"],
-		Shymkent:  cityStatsMap["shymkent"],
-		Karaganda: cityStatsMap["karaganda"],
-		Others:    cityStatsMap["others"],
+		Almaty:    repoGeoStats.Almaty,
+		Nursultan: repoGeoStats.Nursultan,
+		Shymkent:  repoGeoStats.Shymkent,
+		Karaganda: repoGeoStats.Karaganda,
+		Others:    repoGeoStats.Others,
+		Cities:    repoGeoStats.Cities,
+		Regions:   repoGeoStats.Regions,
 	}
 
 	// Get REAL recent data (last 50 records)
@@ -1744,7 +1709,7 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Set CORS headers
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Requested-With")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 
@@ -1921,6 +1886,15 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 		h.ClientSaveHandler(w, r)
 	})
 
+	mux.HandleFunc("/api/client/orders", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		h.ClientOrdersHandler(w, r)
+	})
+
 	// Enhanced Admin API endpoints
 	mux.HandleFunc("/api/admin/dashboard", func(w http.ResponseWriter, r *http.Request) {
 		h.setCORSHeaders(w)
@@ -1928,6 +1902,9 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+		if !h.requireAdmin(w, r, domain.PermissionStats) {
+			return
+		}
 		h.AdminDashboardHandler(w, r)
 	})
 
@@ -1937,6 +1914,9 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+		if !h.requireAdmin(w, r, domain.PermissionStats) {
+			return
+		}
 		h.AdminClientsHandler(w, r)
 	})
 
@@ -1946,9 +1926,156 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+		if !h.requireAdmin(w, r, domain.PermissionStats) {
+			return
+		}
 		h.GeoAnalyticsHandler(w, r)
 	})
 
+	mux.HandleFunc("/api/admin/couriers", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionDelivery) {
+			return
+		}
+		h.AdminCouriersHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/orders/assign", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionDelivery) {
+			return
+		}
+		h.AssignOrdersHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/export/orders", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionOrders) {
+			return
+		}
+		h.AdminExportOrdersHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/orders/status", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionOrders) {
+			return
+		}
+		h.AdminOrderStatusHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/orders/pin", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionDelivery) {
+			return
+		}
+		h.AdminOrderPinsHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/tracking/import", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionOrders) {
+			return
+		}
+		h.AdminTrackingImportHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/proofs", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionDelivery) {
+			return
+		}
+		h.AdminProofsHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/proofs/photo", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionDelivery) {
+			return
+		}
+		h.AdminProofPhotoHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/zones", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionDelivery) {
+			return
+		}
+		h.AdminZonesHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/routes", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionDelivery) {
+			return
+		}
+		h.AdminRoutesHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/customers", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionStats) {
+			return
+		}
+		h.AdminCustomersHandler(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/sources", func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !h.requireAdmin(w, r, domain.PermissionStats) {
+			return
+		}
+		h.AdminSourcesHandler(w, r)
+	})
+
 	// Health check endpoint
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		h.setCORSHeaders(w)
@@ -1967,6 +2094,11 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 		})
 	})
 
+	// Telegram updates in webhook mode; the reverse proxy terminates HTTPS
+	if h.cfg.Webhook {
+		mux.HandleFunc(h.webhookPath(), h.telegramWebhook(b))
+	}
+
 	h.logger.Info("🚀 Enhanced Meily web server starting",
 		zap.String("port", h.cfg.Port),
 		zap.String("welcome_url", "http://localhost"+h.cfg.Port+"/welcome"),
@@ -1983,7 +2115,7 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 // setCORSHeaders sets CORS headers for HTTP responses
 func (h *Handler) setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Requested-With")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
 }
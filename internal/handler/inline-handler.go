@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/traits/helper"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// referralSourcePrefix starts the /start payload of a shared product card, followed by
+	// the sharer's ID, so it is recorded as a campaign source like any other deep link
+	referralSourcePrefix = "ref_"
+
+	// inlineCacheTime is how long Telegram may reuse an answer for the same user, in seconds
+	inlineCacheTime = 300
+)
+
+// referralLink returns the deep link that opens the bot with the sharer's referral source
+func (h *Handler) referralLink(userID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", h.cfg.BotUsername, referralSourcePrefix, userID)
+}
+
+// InlineQueryHandler answers "@bot" typed in any chat with a product card to share: the
+// start photo with the price and the lottery bonus, and a Buy button carrying the
+// sharer's referral link. The answer is personal, since every user has their own link.
+// Inline mode has to be enabled for the bot with /setinline in @BotFather.
+func (h *Handler) InlineQueryHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	query := update.InlineQuery
+	if query.From == nil {
+		return
+	}
+	lang := h.fromLang(ctx, query.From)
+
+	args := i18n.Args{"price": helper.FormatPrice(h.cfg.Cost), "tickets": domain.TicketsPerSet}
+	title := i18n.T(lang, "inline.title")
+	description := i18n.T(lang, "inline.description", args)
+	caption := h.text(lang, "inline.card", args)
+	markup := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: i18n.T(lang, "button.inline_buy"), URL: h.referralLink(query.From.ID)}},
+		},
+	}
+
+	// The card shows whatever the start screen shows; text only when there is no media
+	const resultID = "product"
+	var result models.InlineQueryResult = &models.InlineQueryResultArticle{
+		ID:                  resultID,
+		Title:               title,
+		Description:         description,
+		InputMessageContent: &models.InputTextMessageContent{MessageText: caption},
+		ReplyMarkup:         markup,
+	}
+	if asset := h.mediaAsset(ctx, mediaStartPhoto); asset != nil {
+		switch asset.Kind {
+		case "photo":
+			result = &models.InlineQueryResultCachedPhoto{
+				ID:          resultID,
+				PhotoFileID: asset.FileID,
+				Title:       title,
+				Description: description,
+				Caption:     caption,
+				ReplyMarkup: markup,
+			}
+		case "video":
+			result = &models.InlineQueryResultCachedVideo{
+				ID:          resultID,
+				VideoFileID: asset.FileID,
+				Title:       title,
+				Description: description,
+				Caption:     caption,
+				ReplyMarkup: markup,
+			}
+		}
+	}
+
+	_, err := b.AnswerInlineQuery(ctx, &bot.AnswerInlineQueryParams{
+		InlineQueryID: query.ID,
+		Results:       []models.InlineQueryResult{result},
+		CacheTime:     inlineCacheTime,
+		IsPersonal:    true,
+	})
+	if err != nil {
+		h.logger.Error("Failed to answer inline query", zap.Int64("user_id", query.From.ID), zap.Error(err))
+	}
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/internal/repository"
+	"meily/traits/helper"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+const (
+	invoiceCallbackPrefix = "invoice_"
+
+	// invoicePayloadPrefix starts the invoice payload, followed by the number of sets
+	invoicePayloadPrefix = "sets_"
+
+	// Telegram counts tenge in tiyn, 1/100 of a tenge
+	invoiceCurrency   = "KZT"
+	invoiceMinorUnits = 100
+
+	// invoiceChargePrefix marks a Telegram payment in the loto qr column, where it keeps
+	// the same charge from issuing tickets twice, like a receipt QR code
+	invoiceChargePrefix = "telegram:"
+)
+
+// payButtons returns the pay buttons for count sets: the Kaspi payment link, and a
+// Telegram invoice when a payment provider is configured
+func (h *Handler) payButtons(lang string, count int) [][]models.InlineKeyboardButton {
+	rows := [][]models.InlineKeyboardButton{
+		{{Text: i18n.T(lang, "button.pay"), URL: h.cfg.PaymentURL}},
+	}
+	if h.cfg.PaymentProviderToken != "" && count > 0 {
+		rows = append(rows, []models.InlineKeyboardButton{{
+			Text:         i18n.T(lang, "button.pay_telegram"),
+			CallbackData: fmt.Sprintf("%s%d", invoiceCallbackPrefix, count),
+		}})
+	}
+	return rows
+}
+
+// invoiceCount returns the number of sets from an invoice payload
+func invoiceCount(payload string) (int, bool) {
+	raw, ok := strings.CutPrefix(payload, invoicePayloadPrefix)
+	if !ok {
+		return 0, false
+	}
+	count, err := strconv.Atoi(raw)
+	if err != nil || count <= 0 {
+		return 0, false
+	}
+	return count, true
+}
+
+// InvoiceCallbackHandler handles the "💳 Pay in Telegram" button: it sends an invoice
+// for the number of sets in the button
+func (h *Handler) InvoiceCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	query := update.CallbackQuery
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: query.ID})
+	if h.cfg.PaymentProviderToken == "" {
+		return
+	}
+
+	count, err := strconv.Atoi(strings.TrimPrefix(query.Data, invoiceCallbackPrefix))
+	if err != nil || count <= 0 {
+		h.logger.Warn("Invalid invoice button", zap.String("data", query.Data))
+		return
+	}
+	lang := h.fromLang(ctx, &query.From)
+	amount := count * h.cfg.Cost
+
+	_, err = b.SendInvoice(ctx, &bot.SendInvoiceParams{
+		ChatID:        query.From.ID,
+		Title:         i18n.T(lang, "invoice.title"),
+		Description:   i18n.T(lang, "invoice.description", i18n.Args{"count": count, "tickets": domain.TicketsPerSet}),
+		Payload:       fmt.Sprintf("%s%d", invoicePayloadPrefix, count),
+		ProviderToken: h.cfg.PaymentProviderToken,
+		Currency:      invoiceCurrency,
+		Prices: []models.LabeledPrice{{
+			Label:  i18n.T(lang, "invoice.label", i18n.Args{"count": count}),
+			Amount: amount * invoiceMinorUnits,
+		}},
+	})
+	if err != nil {
+		h.logger.Error("Failed to send invoice", zap.Int64("user_id", query.From.ID), zap.Int("count", count), zap.Error(err))
+		return
+	}
+	h.logger.Info("Invoice sent", zap.Int64("user_id", query.From.ID), zap.Int("count", count), zap.Int("amount", amount))
+}
+
+// PreCheckoutQueryHandler confirms the invoice before Telegram charges the buyer. An
+// invoice sent before the price changed is declined, so the buyer never pays an amount
+// that would not match their sets.
+func (h *Handler) PreCheckoutQueryHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	query := update.PreCheckoutQuery
+	count, ok := invoiceCount(query.InvoicePayload)
+	ok = ok && query.Currency == invoiceCurrency && query.TotalAmount == count*h.cfg.Cost*invoiceMinorUnits
+
+	params := &bot.AnswerPreCheckoutQueryParams{PreCheckoutQueryID: query.ID, OK: ok}
+	if !ok {
+		h.logger.Warn("Pre-checkout declined",
+			zap.String("payload", query.InvoicePayload), zap.String("currency", query.Currency), zap.Int("total_amount", query.TotalAmount))
+		params.ErrorMessage = i18n.T(h.fromLang(ctx, query.From), "invoice.invalid")
+	}
+	if _, err := b.AnswerPreCheckoutQuery(ctx, params); err != nil {
+		h.logger.Error("Failed to answer pre-checkout query", zap.Error(err))
+	}
+}
+
+// SuccessfulPaymentHandler issues the order and the lottery tickets for a paid invoice,
+// the same way as for an accepted receipt
+func (h *Handler) SuccessfulPaymentHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	payment := update.Message.SuccessfulPayment
+	userID := update.Message.From.ID
+	lang := h.fromLang(ctx, update.Message.From)
+
+	count, ok := invoiceCount(payment.InvoicePayload)
+	if !ok {
+		h.logger.Error("Payment with an unknown payload",
+			zap.Int64("user_id", userID), zap.String("payload", payment.InvoicePayload), zap.String("charge_id", payment.TelegramPaymentChargeID))
+		return
+	}
+	amount := payment.TotalAmount / invoiceMinorUnits
+	charge := invoiceChargePrefix + payment.TelegramPaymentChargeID
+
+	// Telegram may deliver the update again, e.g. after a webhook timeout
+	unique, err := h.repo.IsQrUnique(ctx, charge)
+	if err != nil {
+		h.logger.Error("error in check unique", zap.Error(err))
+		return
+	}
+	if !unique {
+		h.logger.Warn("Payment already processed", zap.String("charge_id", payment.TelegramPaymentChargeID))
+		return
+	}
+
+	// The check above is only a shortcut: two deliveries at once both pass it, and the
+	// unique charge in the payment transaction lets only one of them issue tickets
+	tickets, err := h.completePayment(ctx, userID, count, amount, charge, payment.ProviderPaymentChargeID)
+	if errors.Is(err, repository.ErrChargeProcessed) {
+		h.logger.Warn("Payment already processed", zap.String("charge_id", payment.TelegramPaymentChargeID))
+		return
+	}
+	if err != nil {
+		h.paymentFailed(ctx, b, update.Message.Chat.ID, userID, lang, charge, err)
+		return
+	}
+
+	// The money is already taken, so the tickets are issued wherever the chat is
+	h.moveUser(ctx, b, update, userID, &domain.UserState{
+		State:  stateContact,
+		Count:  count,
+		IsPaid: true,
+	})
+	h.logger.Info("Invoice paid",
+		zap.Int64("user_id", userID), zap.Int("count", count), zap.Int("amount", amount), zap.String("charge_id", payment.TelegramPaymentChargeID))
+
+	paidAt := time.Now().Format("2006-01-02 15:04:05")
+	h.notifyAdmins(ctx, domain.PermissionReceipts, func(chatID int64, adminLang string) error {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text: i18n.T(adminLang, "admin.invoice_paid", i18n.Args{
+				"user_id":   userID,
+				"count":     count,
+				"amount":    amount,
+				"time":      paidAt,
+				"charge_id": payment.TelegramPaymentChargeID,
+			}),
+		})
+		return err
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString(i18n.T(lang, "tickets.list", i18n.Args{"count": len(tickets)}))
+	for _, ticket := range tickets {
+		sb.WriteString(fmt.Sprintf("🎫 %08d\n", ticket))
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text: h.text(lang, "payment.invoice_paid", i18n.Args{
+			"count":   count,
+			"amount":  helper.FormatPrice(amount),
+			"tickets": strings.TrimSpace(sb.String()),
+		}),
+		ReplyMarkup: shareContactKeyboard(lang),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send confirmation message", zap.Error(err))
+	}
+}
@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"meily/config"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/internal/repository"
+	"meily/traits/database"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// botCall is one request the handler made to the Bot API
+type botCall struct {
+	method string
+	params map[string]string
+}
+
+// fakeBotAPI answers Bot API requests with success and records them
+type fakeBotAPI struct {
+	mu    sync.Mutex
+	calls []botCall
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := map[string]string{}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(1 << 20); err == nil {
+			for k, v := range r.MultipartForm.Value {
+				params[k] = v[0]
+			}
+		}
+	case "application/json":
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
+			for k, v := range body {
+				params[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	method := path.Base(r.URL.Path)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, botCall{method: method, params: params})
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasPrefix(method, "answer") {
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+		return
+	}
+	fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}`)
+}
+
+// called returns the recorded calls of a Bot API method
+func (f *fakeBotAPI) called(method string) []botCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []botCall
+	for _, c := range f.calls {
+		if c.method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// newInvoiceTestHandler returns a handler over an in-memory database and a bot talking to
+// a fake Bot API. Redis is unreachable, so chat states are only logged as not saved.
+func newInvoiceTestHandler(t *testing.T) (*Handler, *bot.Bot, *fakeBotAPI, *sql.DB) {
+	t.Helper()
+	if err := i18n.Load(); err != nil {
+		t.Fatalf("load i18n: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if err := database.CreateTables(db); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { rdb.Close() })
+
+	api := &fakeBotAPI{}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	b, err := bot.New("1:test", bot.WithServerURL(srv.URL), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatalf("create bot: %v", err)
+	}
+
+	cfg := &config.Config{Cost: 5000, Location: time.UTC}
+	h := NewHandler(cfg, zap.NewNop(), context.Background(), repository.NewUserRepository(db), repository.NewRedisRepository(rdb))
+	return h, b, api, db
+}
+
+func TestPreCheckoutQueryHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		currency string
+		amount   int
+		ok       bool
+	}{
+		{"matching amount", "sets_2", invoiceCurrency, 2 * 5000 * invoiceMinorUnits, true},
+		{"amount from an old price", "sets_2", invoiceCurrency, 2 * 4000 * invoiceMinorUnits, false},
+		{"other currency", "sets_2", "RUB", 2 * 5000 * invoiceMinorUnits, false},
+		{"unknown payload", "gift", invoiceCurrency, 2 * 5000 * invoiceMinorUnits, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, b, api, _ := newInvoiceTestHandler(t)
+			h.PreCheckoutQueryHandler(context.Background(), b, &models.Update{
+				PreCheckoutQuery: &models.PreCheckoutQuery{
+					ID:             "q1",
+					From:           &models.User{ID: 42, LanguageCode: "ru"},
+					Currency:       tt.currency,
+					TotalAmount:    tt.amount,
+					InvoicePayload: tt.payload,
+				},
+			})
+
+			answers := api.called("answerPreCheckoutQuery")
+			if len(answers) != 1 {
+				t.Fatalf("got %d pre-checkout answers, want 1", len(answers))
+			}
+			got := answers[0].params
+			if got["pre_checkout_query_id"] != "q1" {
+				t.Errorf("answered query %q, want q1", got["pre_checkout_query_id"])
+			}
+			if ok := got["ok"] == "true"; ok != tt.ok {
+				t.Errorf("ok = %v, want %v", ok, tt.ok)
+			}
+			if hasError := got["error_message"] != ""; hasError == tt.ok {
+				t.Errorf("error message %q for ok = %v", got["error_message"], tt.ok)
+			}
+		})
+	}
+}
+
+// paidUpdate is a successful payment of sets sets by buyer with the given charge
+func paidUpdate(buyer int64, sets int, charge string) *models.Update {
+	return &models.Update{
+		Message: &models.Message{
+			From: &models.User{ID: buyer, LanguageCode: "ru"},
+			Chat: models.Chat{ID: buyer, Type: models.ChatTypePrivate},
+			SuccessfulPayment: &models.SuccessfulPayment{
+				Currency:                invoiceCurrency,
+				TotalAmount:             sets * 5000 * invoiceMinorUnits,
+				InvoicePayload:          fmt.Sprintf("%s%d", invoicePayloadPrefix, sets),
+				TelegramPaymentChargeID: charge,
+				ProviderPaymentChargeID: "provider-" + charge,
+			},
+		},
+	}
+}
+
+// countRows returns the number of rows a COUNT(*) query finds
+func countRows(t *testing.T, db *sql.DB, q string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(q, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", q, err)
+	}
+	return n
+}
+
+// buyerMessages returns the texts sent to the buyer's chat
+func (f *fakeBotAPI) buyerMessages(buyer int64) []string {
+	var texts []string
+	for _, c := range f.called("sendMessage") {
+		if c.params["chat_id"] == fmt.Sprint(buyer) {
+			texts = append(texts, c.params["text"])
+		}
+	}
+	return texts
+}
+
+func TestSuccessfulPaymentHandlerIssuesTicketsOnce(t *testing.T) {
+	h, b, api, db := newInvoiceTestHandler(t)
+	const buyer = 42
+	update := paidUpdate(buyer, 2, "charge-1")
+
+	// Telegram delivers the same payment twice, e.g. after a webhook timeout, and
+	// sometimes both deliveries arrive at once
+	h.SuccessfulPaymentHandler(context.Background(), b, update)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.SuccessfulPaymentHandler(context.Background(), b, update)
+		}()
+	}
+	wg.Wait()
+
+	tickets := countRows(t, db, `SELECT COUNT(*) FROM loto WHERE id_user = ? AND qr = ?`, buyer, invoiceChargePrefix+"charge-1")
+	if want := 2 * domain.TicketsPerSet; tickets != want {
+		t.Errorf("issued %d tickets, want %d", tickets, want)
+	}
+	if payments := countRows(t, db, `SELECT COUNT(*) FROM payments WHERE id_user = ?`, buyer); payments != 1 {
+		t.Errorf("recorded %d payments, want 1", payments)
+	}
+	if confirmations := api.buyerMessages(buyer); len(confirmations) != 1 {
+		t.Errorf("sent the buyer %d confirmations, want 1", len(confirmations))
+	}
+}
+
+func TestSuccessfulPaymentHandlerRetriesFailedIssue(t *testing.T) {
+	h, b, api, db := newInvoiceTestHandler(t)
+	const buyer = 42
+	update := paidUpdate(buyer, 2, "charge-1")
+
+	// The database fails after the second ticket
+	if _, err := db.Exec(`
+		CREATE TRIGGER fail_loto BEFORE INSERT ON loto
+		WHEN (SELECT COUNT(*) FROM loto) >= 2
+		BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END;
+	`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	h.SuccessfulPaymentHandler(context.Background(), b, update)
+
+	if tickets := countRows(t, db, `SELECT COUNT(*) FROM loto`); tickets != 0 {
+		t.Errorf("kept %d tickets of the failed issue, want 0", tickets)
+	}
+	if payments := countRows(t, db, `SELECT COUNT(*) FROM payments`); payments != 0 {
+		t.Errorf("recorded %d payments of the failed issue, want 0", payments)
+	}
+	messages := api.buyerMessages(buyer)
+	if len(messages) != 1 || !i18n.Matches("payment.issue_failed", messages[0]) {
+		t.Errorf("buyer got %q, want the issue failure message", messages)
+	}
+
+	// Telegram delivers the payment again once the database is back
+	if _, err := db.Exec(`DROP TRIGGER fail_loto`); err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	h.SuccessfulPaymentHandler(context.Background(), b, update)
+
+	if tickets, want := countRows(t, db, `SELECT COUNT(*) FROM loto WHERE id_user = ?`, buyer), 2*domain.TicketsPerSet; tickets != want {
+		t.Errorf("issued %d tickets on redelivery, want %d", tickets, want)
+	}
+	if payments := countRows(t, db, `SELECT COUNT(*) FROM payments WHERE id_user = ?`, buyer); payments != 1 {
+		t.Errorf("recorded %d payments on redelivery, want 1", payments)
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"meily/internal/i18n"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+const languageCallbackPrefix = "lang_"
+
+// userLang returns the language stored for the user, or the default one when the user
+// has never written to the bot. Used for messages the bot sends on its own.
+func (h *Handler) userLang(ctx context.Context, userID int64) string {
+	if lang, ok := h.langs.Load(userID); ok {
+		return lang.(string)
+	}
+	lang, err := h.repo.GetUserLanguage(ctx, userID)
+	if err != nil {
+		h.logger.Warn("Failed to get user language", zap.Int64("user_id", userID), zap.Error(err))
+		return i18n.Default
+	}
+	if !i18n.Supported(lang) {
+		return i18n.Default
+	}
+	h.langs.Store(userID, lang)
+	return lang
+}
+
+// fromLang returns the language of the user who sent an update. On the first message
+// it is detected from the Telegram interface language and saved.
+func (h *Handler) fromLang(ctx context.Context, from *models.User) string {
+	if from == nil {
+		return i18n.Default
+	}
+	if lang, ok := h.langs.Load(from.ID); ok {
+		return lang.(string)
+	}
+	lang, err := h.repo.GetUserLanguage(ctx, from.ID)
+	if err != nil {
+		h.logger.Warn("Failed to get user language", zap.Int64("user_id", from.ID), zap.Error(err))
+		return i18n.Detect(from.LanguageCode)
+	}
+	if !i18n.Supported(lang) {
+		lang = i18n.Detect(from.LanguageCode)
+		if err := h.repo.InitUserLanguage(ctx, from.ID, lang); err != nil {
+			h.logger.Warn("Failed to save detected language", zap.Int64("user_id", from.ID), zap.Error(err))
+		}
+	}
+	h.langs.Store(from.ID, lang)
+	return lang
+}
+
+// adminLang returns the owner's language, used in chats the whole team shares such as
+// the support chat
+func (h *Handler) adminLang(ctx context.Context) string {
+	return h.userLang(ctx, h.cfg.AdminID)
+}
+
+// LanguageCommandHandler handles /language: offers the supported languages
+func (h *Handler) LanguageCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+	lang := h.fromLang(ctx, update.Message.From)
+
+	var row []models.InlineKeyboardButton
+	for _, l := range i18n.Languages {
+		row = append(row, models.InlineKeyboardButton{
+			Text:         i18n.Name(l),
+			CallbackData: languageCallbackPrefix + l,
+		})
+	}
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        i18n.T(lang, "language.choose"),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{row}},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send language picker", zap.Error(err))
+	}
+}
+
+// LanguageCallbackHandler saves the language picked in the /language keyboard
+func (h *Handler) LanguageCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	userID := update.CallbackQuery.From.ID
+	lang := strings.TrimPrefix(update.CallbackQuery.Data, languageCallbackPrefix)
+	if !i18n.Supported(lang) {
+		return
+	}
+
+	if err := h.repo.SetUserLanguage(ctx, userID, lang); err != nil {
+		h.logger.Error("Failed to save user language", zap.Int64("user_id", userID), zap.Error(err))
+		return
+	}
+	h.langs.Store(userID, lang)
+
+	if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            i18n.T(lang, "language.changed"),
+	}); err != nil {
+		h.logger.Warn("Failed to answer callback query", zap.Error(err))
+	}
+
+	if msg := update.CallbackQuery.Message.Message; msg != nil {
+		if _, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    msg.Chat.ID,
+			MessageID: msg.ID,
+			Text:      i18n.T(lang, "language.changed"),
+		}); err != nil {
+			h.logger.Warn("Failed to edit language picker", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"regexp"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// Media library assets the bot sends on its own
+const (
+	mediaStartPhoto       = "start_photo"       // promo on /start, a photo or a video
+	mediaInstructionVideo = "instruction_video" // address form instruction after the contact is shared
+)
+
+// mediaNamePattern is the asset name written after # in the upload caption
+var mediaNamePattern = regexp.MustCompile(`^[a-z0-9_]{2,32}$`)
+
+// mediaAsset returns the asset in use. Until the admin uploads it, the file ID from the
+// config is used; nil means there is neither.
+func (h *Handler) mediaAsset(ctx context.Context, name string) *domain.MediaAsset {
+	asset, err := h.repo.GetMediaAsset(ctx, name)
+	if err != nil {
+		h.logger.Error("Failed to get media asset", zap.String("name", name), zap.Error(err))
+	}
+	if asset != nil {
+		return asset
+	}
+
+	switch name {
+	case mediaStartPhoto:
+		if h.cfg.StartPhotoId != "" {
+			return &domain.MediaAsset{Name: name, Kind: "photo", FileID: h.cfg.StartPhotoId}
+		}
+	case mediaInstructionVideo:
+		if h.cfg.InstructorVideoId != "" {
+			return &domain.MediaAsset{Name: name, Kind: "video", FileID: h.cfg.InstructorVideoId}
+		}
+	}
+	return nil
+}
+
+// sendMedia sends the asset with the caption. Without an asset only the caption is sent,
+// so a missing picture never blocks the conversation.
+func (h *Handler) sendMedia(ctx context.Context, b *bot.Bot, chatID int64, asset *domain.MediaAsset, caption string, markup models.ReplyMarkup) error {
+	if asset == nil {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{ChatID: chatID, Text: caption, ReplyMarkup: markup, ProtectContent: true})
+		return err
+	}
+
+	file := &models.InputFileString{Data: asset.FileID}
+	var err error
+	switch asset.Kind {
+	case "photo":
+		_, err = b.SendPhoto(ctx, &bot.SendPhotoParams{ChatID: chatID, Photo: file, Caption: caption, ReplyMarkup: markup, ProtectContent: true})
+	case "video":
+		_, err = b.SendVideo(ctx, &bot.SendVideoParams{ChatID: chatID, Video: file, Caption: caption, ReplyMarkup: markup, ProtectContent: true})
+	case "document":
+		_, err = b.SendDocument(ctx, &bot.SendDocumentParams{ChatID: chatID, Document: file, Caption: caption, ReplyMarkup: markup, ProtectContent: true})
+	default:
+		err = fmt.Errorf("unknown media kind %q", asset.Kind)
+	}
+	return err
+}
+
+// mediaTag returns the asset name from a caption consisting of a single #name tag
+func mediaTag(caption string) (name string, ok bool) {
+	fields := strings.Fields(caption)
+	if len(fields) != 1 || !strings.HasPrefix(fields[0], "#") {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimPrefix(fields[0], "#")), true
+}
+
+// saveMediaUpload saves a photo, video or document the admin sent with a #name
+// caption as a new version of the asset. It reports false for any other message.
+func (h *Handler) saveMediaUpload(ctx context.Context, b *bot.Bot, update *models.Update) bool {
+	msg := update.Message
+	if msg == nil || msg.From == nil {
+		return false
+	}
+	name, ok := mediaTag(msg.Caption)
+	if !ok {
+		return false
+	}
+	kind, fileID, _ := h.parseMessage(msg)
+	if kind != "photo" && kind != "video" && kind != "document" {
+		return false
+	}
+	if !h.can(ctx, msg.From.ID, domain.PermissionBroadcast) {
+		return false
+	}
+	// A tagged photo sent while composing a broadcast belongs to the broadcast
+	if active, err := h.adminFlow.Active(ctx, msg.From.ID); err != nil || active {
+		return false
+	}
+
+	lang := h.userLang(ctx, msg.From.ID)
+	if !mediaNamePattern.MatchString(name) {
+		h.sendHint(ctx, b, msg.Chat.ID, i18n.T(lang, "admin.media.invalid_name"))
+		return true
+	}
+
+	asset := &domain.MediaAsset{Name: name, Kind: kind, FileID: fileID, CreatedBy: msg.From.ID}
+	if err := h.repo.SaveMediaAsset(ctx, asset); err != nil {
+		h.logger.Error("Failed to save media asset", zap.String("name", name), zap.Error(err))
+		h.sendHint(ctx, b, msg.Chat.ID, i18n.T(lang, "admin.media.save_failed"))
+		return true
+	}
+	h.logger.Info("Media asset saved", zap.String("name", name), zap.String("kind", kind), zap.Int("version", asset.Version))
+
+	h.sendHint(ctx, b, msg.Chat.ID, i18n.T(lang, "admin.media.saved", i18n.Args{
+		"name":    name,
+		"kind":    kind,
+		"version": asset.Version,
+	}))
+	return true
+}
+
+// AdminMediaHandler handles /media: "/media" lists the library, "/media <name>" shows an
+// asset and "/media delete <name>" deletes its current version
+func (h *Handler) AdminMediaHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || !h.allowed(ctx, b, update.Message.From.ID, domain.PermissionBroadcast) {
+		return
+	}
+	chatID := update.Message.Chat.ID
+	lang := h.userLang(ctx, update.Message.From.ID)
+
+	args := strings.Fields(update.Message.Text)[1:]
+	for i := range args {
+		args[i] = strings.ToLower(strings.TrimPrefix(args[i], "#"))
+	}
+
+	switch {
+	case len(args) == 0:
+		h.sendMediaList(ctx, b, chatID, lang)
+	case len(args) == 1:
+		asset, err := h.repo.GetMediaAsset(ctx, args[0])
+		if err != nil {
+			h.logger.Error("Failed to get media asset", zap.String("name", args[0]), zap.Error(err))
+			return
+		}
+		if asset == nil {
+			h.sendHint(ctx, b, chatID, i18n.T(lang, "admin.media.not_found", i18n.Args{"name": args[0]}))
+			return
+		}
+		if err := h.sendMedia(ctx, b, chatID, asset, mediaItem(lang, asset), nil); err != nil {
+			h.logger.Warn("Failed to send media asset", zap.String("name", asset.Name), zap.Error(err))
+		}
+	case len(args) == 2 && args[0] == "delete":
+		h.deleteMediaAsset(ctx, b, chatID, lang, args[1])
+	default:
+		h.sendHint(ctx, b, chatID, i18n.T(lang, "admin.media.help"))
+	}
+}
+
+func (h *Handler) sendMediaList(ctx context.Context, b *bot.Bot, chatID int64, lang string) {
+	assets, err := h.repo.GetMediaAssets(ctx)
+	if err != nil {
+		h.logger.Error("Failed to get media assets", zap.Error(err))
+		return
+	}
+
+	items := i18n.T(lang, "admin.media.empty")
+	if len(assets) > 0 {
+		lines := make([]string, len(assets))
+		for i := range assets {
+			lines[i] = mediaItem(lang, &assets[i])
+		}
+		items = strings.Join(lines, "\n")
+	}
+	h.sendHint(ctx, b, chatID, i18n.T(lang, "admin.media.title", i18n.Args{"items": items})+"\n\n"+i18n.T(lang, "admin.media.help"))
+}
+
+func (h *Handler) deleteMediaAsset(ctx context.Context, b *bot.Bot, chatID int64, lang, name string) {
+	previous, err := h.repo.DeleteMediaAsset(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.sendHint(ctx, b, chatID, i18n.T(lang, "admin.media.not_found", i18n.Args{"name": name}))
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to delete media asset", zap.String("name", name), zap.Error(err))
+		return
+	}
+	h.logger.Info("Media asset deleted", zap.String("name", name))
+
+	if previous != nil {
+		h.sendHint(ctx, b, chatID, i18n.T(lang, "admin.media.restored", i18n.Args{"name": name, "version": previous.Version}))
+		return
+	}
+	h.sendHint(ctx, b, chatID, i18n.T(lang, "admin.media.deleted", i18n.Args{"name": name}))
+}
+
+func mediaItem(lang string, asset *domain.MediaAsset) string {
+	return i18n.T(lang, "admin.media.item", i18n.Args{
+		"name":    asset.Name,
+		"kind":    asset.Kind,
+		"version": asset.Version,
+		"date":    asset.CreatedAt.Format("2006-01-02 15:04"),
+	})
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/internal/service"
+	"meily/traits/helper"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+const (
+	phoneVerifyCallback = "phone_verify_sms"
+
+	// maxPhoneCodeAttempts limits guessing of the 4-digit SMS code
+	maxPhoneCodeAttempts = 5
+
+	// phoneCodeResendInterval and phoneCodeDailyLimit stop SMS codes from being sent in a
+	// loop, per buyer and per phone number
+	phoneCodeResendInterval = time.Minute
+	phoneCodeDailyLimit     = 5
+
+	// phoneCodeTTL is how long an SMS code stays valid
+	phoneCodeTTL = 10 * time.Minute
+)
+
+// SetSMSGateway sets the gateway used to send phone confirmation codes
+func (h *Handler) SetSMSGateway(g service.SMSGateway) {
+	h.sms = g
+}
+
+func shareContactKeyboard(lang string) *models.ReplyKeyboardMarkup {
+	return &models.ReplyKeyboardMarkup{
+		Keyboard: [][]models.KeyboardButton{
+			{{Text: i18n.T(lang, "button.share_contact"), RequestContact: true}},
+		},
+		ResizeKeyboard:  true,
+		OneTimeKeyboard: true,
+	}
+}
+
+func (h *Handler) sendPhoneText(ctx context.Context, b *bot.Bot, userID int64, text string, markup models.ReplyMarkup) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        text,
+		ReplyMarkup: markup,
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send phone verification message", zap.Int64("user_id", userID), zap.Error(err))
+	}
+}
+
+// sendForeignContactRejected explains that only the buyer's own contact is accepted
+// and offers the SMS code instead
+func (h *Handler) sendForeignContactRejected(ctx context.Context, b *bot.Bot, userID int64, lang string) {
+	h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.foreign_contact"), shareContactKeyboard(lang))
+
+	if h.sms == nil {
+		return
+	}
+	h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.offer_sms"),
+		&models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: i18n.T(lang, "button.verify_sms"), CallbackData: phoneVerifyCallback}},
+			},
+		})
+}
+
+// PhoneVerifyCallbackHandler starts the SMS verification: the buyer types the number next
+func (h *Handler) PhoneVerifyCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	userID := update.CallbackQuery.From.ID
+	lang := h.fromLang(ctx, &update.CallbackQuery.From)
+
+	if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	}); err != nil {
+		h.logger.Warn("Failed to answer callback query", zap.Error(err))
+	}
+	if h.sms == nil {
+		return
+	}
+
+	state, err := h.userFlow.Current(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get user state", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	state.State = statePhoneNumber
+	state.Code, state.Attempts = "", 0
+	if !h.moveUser(ctx, b, update, userID, state) {
+		return
+	}
+
+	h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.enter_number"),
+		&models.ReplyKeyboardMarkup{
+			Keyboard:       [][]models.KeyboardButton{{{Text: i18n.T(lang, "button.cancel")}}},
+			ResizeKeyboard: true,
+		})
+}
+
+// PhoneVerificationHandler handles the typed phone number and the SMS code
+func (h *Handler) PhoneVerificationHandler(ctx context.Context, b *bot.Bot, update *models.Update, state *domain.UserState) {
+	if update.Message == nil {
+		return
+	}
+	msg := update.Message
+	userID := msg.From.ID
+	lang := h.fromLang(ctx, msg.From)
+	text := strings.TrimSpace(msg.Text)
+
+	// Sharing the own contact is still the quickest way out
+	if msg.Contact != nil || i18n.Matches("button.cancel", text) {
+		state.State = stateContact
+		state.Code, state.Attempts = "", 0
+		h.moveUser(ctx, b, update, userID, state)
+		if msg.Contact != nil {
+			h.ShareContactCallbackHandler(ctx, b, update)
+			return
+		}
+		h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.share_prompt"), shareContactKeyboard(lang))
+		return
+	}
+
+	switch state.State {
+	case statePhoneNumber:
+		phone := helper.NormalizePhone(text)
+		if !helper.IsE164(phone) {
+			h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.invalid_number"), nil)
+			return
+		}
+		h.sendPhoneCode(ctx, b, update, phone, state, lang)
+
+	case statePhoneCode:
+		if text == "" {
+			return
+		}
+		// An expired code never gets here: the flow moves the chat back to the number
+		if subtle.ConstantTimeCompare([]byte(state.Code), []byte(text)) != 1 {
+			state.Attempts++
+			if state.Attempts >= maxPhoneCodeAttempts {
+				h.logger.Warn("Phone code attempts exhausted",
+					zap.Int64("user_id", userID),
+					zap.String("phone", state.Contact))
+				state.State = stateContact
+				state.Code, state.Attempts = "", 0
+				h.moveUser(ctx, b, update, userID, state)
+				h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.attempts_exhausted"), shareContactKeyboard(lang))
+				return
+			}
+			h.saveUserState(ctx, userID, state)
+			h.sendPhoneText(ctx, b, userID,
+				i18n.T(lang, "phone.wrong_code", i18n.Args{"count": maxPhoneCodeAttempts - state.Attempts}), nil)
+			return
+		}
+
+		h.logger.Info("Phone verified by SMS code", zap.Int64("user_id", userID), zap.String("phone", state.Contact))
+		h.completeContact(ctx, b, msg.From, msg.Chat.ID, state.Contact, domain.PhoneVerifiedSMS)
+	}
+}
+
+// sendPhoneCode sends a new confirmation code to the typed number
+func (h *Handler) sendPhoneCode(ctx context.Context, b *bot.Bot, update *models.Update, phone string, state *domain.UserState, lang string) {
+	userID := update.Message.From.ID
+	wait, ok, err := h.redisRepo.ReserveSMSCode(ctx, userID, phone, phoneCodeResendInterval, phoneCodeDailyLimit)
+	if err != nil {
+		h.logger.Error("Failed to check phone code limits", zap.Int64("user_id", userID), zap.Error(err))
+		h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.sms_failed"), nil)
+		return
+	}
+	if wait > 0 {
+		h.sendPhoneText(ctx, b, userID,
+			i18n.T(lang, "phone.resend_wait", i18n.Args{"count": int(wait.Seconds())}), nil)
+		return
+	}
+	if !ok {
+		h.logger.Warn("Phone code daily limit reached", zap.Int64("user_id", userID), zap.String("phone", phone))
+		h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.sms_limit"), shareContactKeyboard(lang))
+		return
+	}
+
+	code, err := generateDeliveryCode()
+	if err != nil {
+		h.logger.Error("Failed to generate phone code", zap.Error(err))
+		return
+	}
+	err = h.sms.Send(ctx, phone, i18n.T(lang, "phone.sms_text", i18n.Args{"code": code}))
+	if err != nil {
+		h.logger.Error("Failed to send phone code", zap.Int64("user_id", userID), zap.String("phone", phone), zap.Error(err))
+		h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.sms_failed"), nil)
+		return
+	}
+
+	state.State = statePhoneCode
+	state.Contact = phone
+	state.Code = code
+	state.Attempts = 0
+	h.moveUser(ctx, b, update, userID, state)
+
+	h.sendPhoneText(ctx, b, userID, i18n.T(lang, "phone.code_sent", i18n.Args{"phone": phone}), nil)
+}
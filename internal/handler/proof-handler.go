@@ -0,0 +1,447 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/traits/qrcode"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// deliveryQRScale is the size of one QR module in pixels
+	deliveryQRScale = 10
+
+	// maxProofCodeAttempts limits guessing of the 4-digit customer code. The count is kept
+	// on the order's delivery proof, so neither a new code nor a new chat state resets it;
+	// once it is reached the order can only be closed or reassigned by an admin.
+	maxProofCodeAttempts = 5
+)
+
+// proofPhotoClient downloads delivery photos from Telegram for the admin panel
+var proofPhotoClient = &http.Client{Timeout: 30 * time.Second}
+
+// generateDeliveryCode returns a random 4-digit confirmation code
+func generateDeliveryCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(10000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%04d", n.Int64()), nil
+}
+
+// deliveryProofLink is the deep link encoded in the customer's QR. Scanning it with
+// the phone camera opens the bot for the courier with /start pod_<order>_<code>.
+func (h *Handler) deliveryProofLink(orderID int64, code string) string {
+	return fmt.Sprintf("https://t.me/%s?start=pod_%d_%s", h.cfg.BotUsername, orderID, code)
+}
+
+// issueDeliveryCode stores a new confirmation code for the order and sends it to the customer
+func (h *Handler) issueDeliveryCode(ctx context.Context, b *bot.Bot, order *domain.CourierOrder, courierID int64) error {
+	code, err := generateDeliveryCode()
+	if err != nil {
+		return err
+	}
+	if err := h.repo.CreateDeliveryProof(ctx, order.OrderID, courierID, code, time.Now()); err != nil {
+		return err
+	}
+
+	text := h.text(h.userLang(ctx, order.UserID), "delivery.code", i18n.Args{"id": order.OrderID, "code": code})
+
+	// The QR is rendered here: the link carries the code, so it must not pass through a QR service
+	qr, err := qrcode.PNG(h.deliveryProofLink(order.OrderID, code), deliveryQRScale)
+	if err == nil {
+		_, err = b.SendPhoto(ctx, &bot.SendPhotoParams{
+			ChatID:  order.UserID,
+			Photo:   &models.InputFileUpload{Filename: "delivery-qr.png", Data: bytes.NewReader(qr)},
+			Caption: text,
+		})
+	}
+	if err != nil {
+		// The code alone is enough to confirm the delivery
+		h.logger.Warn("Failed to send delivery QR, sending code as text", zap.Int64("order_id", order.OrderID), zap.Error(err))
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: order.UserID,
+			Text:   text,
+		})
+	}
+	return err
+}
+
+func courierCancelKeyboard(lang string) *models.ReplyKeyboardMarkup {
+	return &models.ReplyKeyboardMarkup{
+		Keyboard: [][]models.KeyboardButton{
+			{{Text: i18n.T(lang, "button.cancel")}},
+		},
+		ResizeKeyboard: true,
+	}
+}
+
+func courierLocationKeyboard(lang string) *models.ReplyKeyboardMarkup {
+	return &models.ReplyKeyboardMarkup{
+		Keyboard: [][]models.KeyboardButton{
+			{{Text: i18n.T(lang, "button.send_location"), RequestLocation: true}},
+			{{Text: i18n.T(lang, "button.cancel")}},
+		},
+		ResizeKeyboard: true,
+	}
+}
+
+func (h *Handler) sendCourierText(ctx context.Context, b *bot.Bot, userID int64, text string, markup models.ReplyMarkup) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        text,
+		ReplyMarkup: markup,
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send courier message", zap.Int64("user_id", userID), zap.Error(err))
+	}
+}
+
+// startDeliveryProof asks the courier for the customer code instead of closing the order right away
+func (h *Handler) startDeliveryProof(ctx context.Context, b *bot.Bot, courier *domain.Courier, order *domain.CourierOrder) {
+	lang := h.userLang(ctx, courier.UserID)
+	proof, err := h.repo.GetDeliveryProof(ctx, order.OrderID)
+	if err != nil && err != sql.ErrNoRows {
+		h.logger.Error("Failed to get delivery proof", zap.Int64("order_id", order.OrderID), zap.Error(err))
+		return
+	}
+	// Orders delivered without pressing "picked up" still need a code sent to the customer
+	if proof == nil || proof.CourierID != courier.ID {
+		if err := h.issueDeliveryCode(ctx, b, order, courier.ID); err != nil {
+			h.logger.Error("Failed to issue delivery code", zap.Int64("order_id", order.OrderID), zap.Error(err))
+			h.sendCourierText(ctx, b, courier.UserID, i18n.T(lang, "courier.code_send_failed"), courierKeyboard(lang))
+			return
+		}
+	}
+
+	next := &domain.UserState{
+		State:   stateCourierCode,
+		OrderID: order.OrderID,
+	}
+	if !h.moveUser(ctx, b, nil, courier.UserID, next) {
+		return
+	}
+	h.sendCourierText(ctx, b, courier.UserID,
+		i18n.T(lang, "courier.ask_code", i18n.Args{"id": order.OrderID}),
+		courierCancelKeyboard(lang))
+}
+
+// verifyDeliveryProof checks the customer code for the courier's order and moves on to the photo step
+func (h *Handler) verifyDeliveryProof(ctx context.Context, b *bot.Bot, courier *domain.Courier, state *domain.UserState, orderID int64, code, method string) {
+	lang := h.userLang(ctx, courier.UserID)
+	order, err := h.repo.GetOrderByID(ctx, orderID)
+	if err != nil || order.CourierID == nil || *order.CourierID != courier.ID ||
+		(order.Status != domain.OrderStatusAssigned && order.Status != domain.OrderStatusPickedUp) {
+		h.sendCourierText(ctx, b, courier.UserID, i18n.T(lang, "courier.order_unavailable"), courierKeyboard(lang))
+		h.resetUser(ctx, b, courier.UserID)
+		return
+	}
+
+	proof, err := h.repo.GetDeliveryProof(ctx, orderID)
+	if err != nil {
+		h.logger.Error("Failed to get delivery proof", zap.Int64("order_id", orderID), zap.Error(err))
+		h.sendCourierText(ctx, b, courier.UserID, i18n.T(lang, "courier.code_missing"), courierKeyboard(lang))
+		return
+	}
+
+	if proof.FailedAttempts >= maxProofCodeAttempts {
+		h.resetUser(ctx, b, courier.UserID)
+		h.sendCourierText(ctx, b, courier.UserID, i18n.T(lang, "courier.attempts_exhausted"), courierKeyboard(lang))
+		return
+	}
+
+	if proof.CourierID != courier.ID || subtle.ConstantTimeCompare([]byte(proof.Code), []byte(code)) != 1 {
+		attempts, err := h.repo.AddProofFailedAttempt(ctx, orderID)
+		if err != nil {
+			h.logger.Error("Failed to count wrong delivery code", zap.Int64("order_id", orderID), zap.Error(err))
+			return
+		}
+		if attempts >= maxProofCodeAttempts {
+			h.resetUser(ctx, b, courier.UserID)
+			h.sendCourierText(ctx, b, courier.UserID, i18n.T(lang, "courier.attempts_exhausted"), courierKeyboard(lang))
+			h.notifyAdmins(ctx, domain.PermissionDelivery, func(chatID int64, lang string) error {
+				_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+					ChatID: chatID,
+					Text: i18n.T(lang, "admin.courier_code_attempts", i18n.Args{
+						"name":  courier.Name,
+						"id":    orderID,
+						"count": attempts,
+					}),
+				})
+				return err
+			})
+			return
+		}
+		state.State = stateCourierCode
+		state.OrderID = orderID
+		if !h.moveUser(ctx, b, nil, courier.UserID, state) {
+			return
+		}
+		h.sendCourierText(ctx, b, courier.UserID,
+			i18n.T(lang, "phone.wrong_code", i18n.Args{"count": maxProofCodeAttempts - attempts}),
+			courierCancelKeyboard(lang))
+		return
+	}
+
+	if err := h.repo.MarkProofVerified(ctx, orderID, method, time.Now()); err != nil {
+		h.logger.Error("Failed to mark delivery proof verified", zap.Int64("order_id", orderID), zap.Error(err))
+		return
+	}
+
+	next := &domain.UserState{
+		State:   stateCourierPhoto,
+		OrderID: orderID,
+	}
+	if !h.moveUser(ctx, b, nil, courier.UserID, next) {
+		return
+	}
+	h.sendCourierText(ctx, b, courier.UserID, i18n.T(lang, "courier.code_verified"), courierCancelKeyboard(lang))
+}
+
+// CourierProofHandler walks the courier through code → doorstep photo → location.
+// The order becomes delivered only after all three are stored.
+func (h *Handler) CourierProofHandler(ctx context.Context, b *bot.Bot, update *models.Update, state *domain.UserState) {
+	msg := update.Message
+	userID := msg.From.ID
+	lang := h.fromLang(ctx, msg.From)
+
+	if i18n.Matches("button.cancel", msg.Text) {
+		h.resetUser(ctx, b, userID)
+		h.sendCourierText(ctx, b, userID, i18n.T(lang, "courier.proof_cancelled"), courierKeyboard(lang))
+		return
+	}
+
+	courier, err := h.repo.GetActiveCourierByUserID(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get courier", zap.Error(err))
+		return
+	}
+	if courier == nil {
+		h.resetUser(ctx, b, userID)
+		h.StartHandler(ctx, b, update)
+		return
+	}
+
+	switch state.State {
+	case stateCourierCode:
+		code := strings.TrimSpace(msg.Text)
+		if len(code) != 4 {
+			h.sendCourierText(ctx, b, userID, i18n.T(lang, "courier.code_format"), courierCancelKeyboard(lang))
+			return
+		}
+		h.verifyDeliveryProof(ctx, b, courier, state, state.OrderID, code, domain.ProofVerifiedByCode)
+
+	case stateCourierPhoto:
+		var fileID string
+		switch {
+		case len(msg.Photo) > 0:
+			fileID = msg.Photo[len(msg.Photo)-1].FileID
+		case msg.Document != nil && strings.HasPrefix(msg.Document.MimeType, "image/"):
+			fileID = msg.Document.FileID
+		default:
+			h.sendCourierText(ctx, b, userID, i18n.T(lang, "courier.ask_photo"), courierCancelKeyboard(lang))
+			return
+		}
+		if err := h.repo.SaveProofPhoto(ctx, state.OrderID, fileID); err != nil {
+			h.logger.Error("Failed to save delivery photo", zap.Int64("order_id", state.OrderID), zap.Error(err))
+			h.sendCourierText(ctx, b, userID, i18n.T(lang, "courier.photo_failed"), courierCancelKeyboard(lang))
+			return
+		}
+		next := &domain.UserState{
+			State:   stateCourierLocation,
+			OrderID: state.OrderID,
+		}
+		if !h.moveUser(ctx, b, update, userID, next) {
+			return
+		}
+		h.sendCourierText(ctx, b, userID, i18n.T(lang, "courier.ask_location"), courierLocationKeyboard(lang))
+
+	case stateCourierLocation:
+		if msg.Location == nil {
+			h.sendCourierText(ctx, b, userID, i18n.T(lang, "courier.location_button"), courierLocationKeyboard(lang))
+			return
+		}
+		if err := h.repo.CompleteDelivery(ctx, state.OrderID, msg.Location.Latitude, msg.Location.Longitude, time.Now()); err != nil {
+			h.logger.Error("Failed to complete delivery", zap.Int64("order_id", state.OrderID), zap.Error(err))
+			h.sendCourierText(ctx, b, userID, i18n.T(lang, "courier.complete_failed"), courierKeyboard(lang))
+			return
+		}
+		h.resetUser(ctx, b, userID)
+		h.sendCourierText(ctx, b, userID, i18n.T(lang, "courier.delivered", i18n.Args{"id": state.OrderID}), courierKeyboard(lang))
+		h.notifyAdminDelivered(ctx, b, courier, state.OrderID)
+		if order, err := h.repo.GetOrderByID(ctx, state.OrderID); err == nil {
+			h.notifyOrderStatus(ctx, b, *order)
+		}
+	}
+}
+
+// notifyAdminDelivered sends the admin the doorstep photo of a confirmed delivery
+func (h *Handler) notifyAdminDelivered(ctx context.Context, b *bot.Bot, courier *domain.Courier, orderID int64) {
+	proof, err := h.repo.GetDeliveryProof(ctx, orderID)
+	if err != nil {
+		h.logger.Error("Failed to get delivery proof", zap.Int64("order_id", orderID), zap.Error(err))
+		return
+	}
+
+	h.notifyAdmins(ctx, domain.PermissionDelivery, func(chatID int64, lang string) error {
+		_, err := b.SendPhoto(ctx, &bot.SendPhotoParams{
+			ChatID: chatID,
+			Photo:  &models.InputFileString{Data: proof.PhotoFileID},
+			Caption: i18n.T(lang, "admin.delivery_proof", i18n.Args{
+				"name":        courier.Name,
+				"id":          orderID,
+				"status":      orderStatusLabel(lang, domain.OrderStatusDelivered),
+				"fio":         proof.Fio,
+				"address":     proof.Address,
+				"verified_by": proof.VerifiedBy,
+			}),
+		})
+		return err
+	})
+}
+
+// CourierProofDeepLinkHandler handles /start pod_<order>_<code> opened by scanning the customer's QR
+func (h *Handler) CourierProofDeepLinkHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+
+	userID := update.Message.From.ID
+	payload := strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(update.Message.Text, "/start")), "pod_")
+	parts := strings.Split(payload, "_")
+	if len(parts) != 2 {
+		h.StartHandler(ctx, b, update)
+		return
+	}
+	orderID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		h.StartHandler(ctx, b, update)
+		return
+	}
+
+	courier, err := h.repo.GetActiveCourierByUserID(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get courier", zap.Error(err))
+		return
+	}
+	if courier == nil {
+		// Most likely the customer scanned their own code
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: userID,
+			Text:   i18n.T(h.fromLang(ctx, update.Message.From), "delivery.qr_hint"),
+		})
+		if err != nil {
+			h.logger.Warn("Failed to send QR hint", zap.Error(err))
+		}
+		return
+	}
+
+	state, err := h.userFlow.Current(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get courier state", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	h.verifyDeliveryProof(ctx, b, courier, state, orderID, parts[1], domain.ProofVerifiedByQR)
+}
+
+// AdminProofsHandler handles /api/admin/proofs - recent confirmed deliveries for the dashboard
+func (h *Handler) AdminProofsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	proofs, err := h.repo.GetRecentDeliveryProofs(h.ctx, 50)
+	if err != nil {
+		h.logger.Error("Failed to get delivery proofs", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    proofs,
+	})
+}
+
+// AdminProofPhotoHandler handles /api/admin/proofs/photo?order=.. - streams the doorstep photo from Telegram
+func (h *Handler) AdminProofPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(r.URL.Query().Get("order"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid order", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := h.repo.GetDeliveryProof(h.ctx, orderID)
+	if err != nil || proof.PhotoFileID == "" {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+	if h.bot == nil {
+		http.Error(w, "Bot is not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	file, err := h.bot.GetFile(h.ctx, &bot.GetFileParams{FileID: proof.PhotoFileID})
+	if err != nil {
+		h.logger.Error("Failed to get delivery photo", zap.Int64("order_id", orderID), zap.Error(err))
+		http.Error(w, "Telegram error", http.StatusBadGateway)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, h.bot.FileDownloadLink(file), nil)
+	if err != nil {
+		h.logger.Error("Failed to build delivery photo request", zap.Int64("order_id", orderID), zap.Error(err))
+		http.Error(w, "Telegram error", http.StatusBadGateway)
+		return
+	}
+	resp, err := proofPhotoClient.Do(req)
+	if err != nil {
+		h.logger.Error("Failed to download delivery photo", zap.Int64("order_id", orderID), zap.Error(err))
+		http.Error(w, "Telegram error", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		h.logger.Error("Failed to download delivery photo", zap.Int64("order_id", orderID), zap.Int("status", resp.StatusCode))
+		http.Error(w, "Telegram error", http.StatusBadGateway)
+		return
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		h.logger.Warn("Failed to stream delivery photo", zap.Error(err))
+	}
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/traits/helper"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// StartReminderScheduler periodically reminds users who chose a count but never sent a receipt.
+// Reminders are due at cfg.ReminderIntervals after the checkout started and are held back during quiet hours;
+// a held back reminder delays the following ones, see reminderDueAt.
+func (h *Handler) StartReminderScheduler(ctx context.Context, b *bot.Bot) {
+	if len(h.cfg.ReminderIntervals) == 0 {
+		h.logger.Info("Checkout reminders disabled: no intervals configured")
+		return
+	}
+
+	ticker := time.NewTicker(h.cfg.ReminderCheckInterval)
+	defer ticker.Stop()
+
+	h.logger.Info("Checkout reminder scheduler started",
+		zap.Any("intervals", h.cfg.ReminderIntervals),
+		zap.Duration("check_interval", h.cfg.ReminderCheckInterval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.logger.Info("Checkout reminder scheduler stopped")
+			return
+		case <-ticker.C:
+			h.processCheckoutReminders(ctx, b, time.Now())
+		}
+	}
+}
+
+func (h *Handler) processCheckoutReminders(ctx context.Context, b *bot.Bot, now time.Time) {
+	if h.inQuietHours(now) {
+		return
+	}
+
+	checkouts, err := h.repo.GetPendingCheckouts(ctx)
+	if err != nil {
+		h.logger.Error("Failed to load pending checkouts", zap.Error(err))
+		return
+	}
+
+	for _, checkout := range checkouts {
+		if checkout.RemindersSent >= len(h.cfg.ReminderIntervals) {
+			if err := h.repo.MarkCheckoutExhausted(ctx, checkout.UserID); err != nil {
+				h.logger.Error("Failed to close exhausted checkout", zap.Int64("user_id", checkout.UserID), zap.Error(err))
+			}
+			continue
+		}
+
+		if now.Before(reminderDueAt(checkout, h.cfg.ReminderIntervals)) {
+			continue
+		}
+
+		// Someone who already became a client must not be reminded even if the conversion was missed
+		paid, err := h.repo.ExistsClient(ctx, checkout.UserID)
+		if err != nil {
+			h.logger.Error("Failed to check client before reminder", zap.Int64("user_id", checkout.UserID), zap.Error(err))
+			continue
+		}
+		if paid {
+			if err := h.repo.MarkCheckoutConverted(ctx, checkout.UserID, now); err != nil {
+				h.logger.Error("Failed to mark checkout converted", zap.Int64("user_id", checkout.UserID), zap.Error(err))
+			}
+			continue
+		}
+
+		if err := h.sendCheckoutReminder(ctx, b, checkout); err != nil {
+			h.logger.Warn("Failed to send checkout reminder", zap.Int64("user_id", checkout.UserID), zap.Error(err))
+		}
+		// The attempt is counted even on failure so a blocked user is not retried every tick
+		if err := h.repo.MarkReminderSent(ctx, checkout.UserID, now); err != nil {
+			h.logger.Error("Failed to mark reminder sent", zap.Int64("user_id", checkout.UserID), zap.Error(err))
+		}
+	}
+}
+
+// reminderDueAt returns when the next reminder of the checkout is due. The intervals count
+// from the checkout start, but the gap between two reminders is kept from the time the
+// previous one was actually sent, so reminders held back by quiet hours do not go out in a burst.
+func reminderDueAt(checkout domain.CheckoutReminder, intervals []time.Duration) time.Time {
+	n := checkout.RemindersSent
+	dueAt := checkout.StartedAt.Add(intervals[n])
+	if n > 0 && checkout.LastRemindedAt != nil {
+		if next := checkout.LastRemindedAt.Add(intervals[n] - intervals[n-1]); next.After(dueAt) {
+			dueAt = next
+		}
+	}
+	return dueAt
+}
+
+func (h *Handler) sendCheckoutReminder(ctx context.Context, b *bot.Bot, checkout domain.CheckoutReminder) error {
+	lang := h.userLang(ctx, checkout.UserID)
+	optOutButton := models.InlineKeyboardButton{
+		Text:         i18n.T(lang, "button.reminder_optout"),
+		CallbackData: "reminder_optout",
+	}
+
+	var text string
+	var buttons [][]models.InlineKeyboardButton
+	if checkout.Count > 0 {
+		text = h.text(lang, "reminder.unpaid", i18n.Args{
+			"count":  checkout.Count,
+			"amount": helper.FormatPrice(checkout.Amount),
+		})
+		buttons = append(h.payButtons(lang, checkout.Count), []models.InlineKeyboardButton{optOutButton})
+	} else {
+		text = h.text(lang, "reminder.not_chosen", i18n.Args{"price": helper.FormatPrice(h.cfg.Cost)})
+		buttons = [][]models.InlineKeyboardButton{
+			{{Text: i18n.T(lang, "button.buy"), CallbackData: "buy_cosmetics"}},
+			{optOutButton},
+		}
+	}
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      checkout.UserID,
+		Text:        text,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	})
+	return err
+}
+
+// ReminderOptOutHandler handles the "reminder_optout" button under reminder messages
+func (h *Handler) ReminderOptOutHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.CallbackQuery == nil {
+		return
+	}
+
+	userID := update.CallbackQuery.From.ID
+	if err := h.repo.OptOutReminders(ctx, userID); err != nil {
+		h.logger.Error("Failed to opt out of reminders", zap.Int64("user_id", userID), zap.Error(err))
+	}
+
+	_, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            i18n.T(h.fromLang(ctx, &update.CallbackQuery.From), "reminder.opted_out"),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to answer callback query", zap.Error(err))
+	}
+}
+
+// markCheckoutConverted records a purchase so the reminder scheduler stops for this user
+func (h *Handler) markCheckoutConverted(ctx context.Context, userID int64) {
+	if err := h.repo.MarkCheckoutConverted(ctx, userID, time.Now()); err != nil {
+		h.logger.Error("Failed to mark checkout converted", zap.Int64("user_id", userID), zap.Error(err))
+	}
+}
+
+// inQuietHours reports whether now falls into the configured quiet window in Almaty time.
+// The window may wrap around midnight (e.g. 22 → 9).
+func (h *Handler) inQuietHours(now time.Time) bool {
+	start, end := h.cfg.QuietHoursStart, h.cfg.QuietHoursEnd
+	if start == end {
+		return false
+	}
+	hour := now.In(h.cfg.Location).Hour()
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/internal/repository"
+	"meily/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// buildCourierRoute optimizes the visiting order of the courier's orders on the given day: the
+// orders assigned before the day ended that are still open, and for a day that is over also the
+// orders closed during it. Orders without coordinates are returned as unlocated.
+func (h *Handler) buildCourierRoute(ctx context.Context, courierID int64, day time.Time) (*domain.Route, error) {
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, h.cfg.Location)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	orders, err := h.repo.GetCourierOrdersSince(ctx, courierID, dayStart)
+	if err != nil {
+		return nil, err
+	}
+	orders = routeDayOrders(orders, dayStart, dayEnd, time.Now())
+
+	route := &domain.Route{
+		CourierID: courierID,
+		Date:      dayStart.Format("2006-01-02"),
+		OriginLat: h.cfg.WarehouseLat,
+		OriginLon: h.cfg.WarehouseLon,
+	}
+
+	var stops []domain.RouteStop
+	stops, route.Unlocated = routeStops(orders)
+	route.Stops, route.TotalKm = service.OptimizeRoute(route.OriginLat, route.OriginLon, stops, repository.CalculateDistance)
+	return route, nil
+}
+
+// routeDayOrders keeps the orders that belong to the route of the day [dayStart, dayEnd):
+// open orders assigned before the day ended and, once the day is over, the orders closed during it
+func routeDayOrders(orders []domain.CourierOrder, dayStart, dayEnd, now time.Time) []domain.CourierOrder {
+	var day []domain.CourierOrder
+	for _, o := range orders {
+		if o.AssignedAt != nil && !o.AssignedAt.Before(dayEnd) {
+			continue
+		}
+		switch o.Status {
+		case domain.OrderStatusAssigned, domain.OrderStatusPickedUp:
+		case domain.OrderStatusDelivered, domain.OrderStatusFailed:
+			if now.Before(dayEnd) || o.StatusUpdatedAt == nil ||
+				o.StatusUpdatedAt.Before(dayStart) || !o.StatusUpdatedAt.Before(dayEnd) {
+				continue
+			}
+		default:
+			continue
+		}
+		day = append(day, o)
+	}
+	return day
+}
+
+// routeStops splits the orders into stops and orders without confirmed coordinates
+func routeStops(orders []domain.CourierOrder) ([]domain.RouteStop, []domain.CourierOrder) {
+	var stops []domain.RouteStop
+	var unlocated []domain.CourierOrder
+	for _, o := range orders {
+		if o.Latitude == nil || o.Longitude == nil || domain.GeoNeedsPin(o.GeoConfidence) {
+			unlocated = append(unlocated, o)
+			continue
+		}
+		stops = append(stops, domain.RouteStop{
+			OrderID:   o.OrderID,
+			UserID:    o.UserID,
+			Fio:       o.Fio,
+			Contact:   o.Contact,
+			Address:   o.Address,
+			Status:    o.Status,
+			Latitude:  *o.Latitude,
+			Longitude: *o.Longitude,
+		})
+	}
+	return stops, unlocated
+}
+
+// routeMapURL builds a Yandex Maps link that opens the whole route for navigation
+func routeMapURL(route *domain.Route) string {
+	points := make([]string, 0, len(route.Stops)+1)
+	points = append(points, fmt.Sprintf("%.6f,%.6f", route.OriginLat, route.OriginLon))
+	for _, s := range route.Stops {
+		points = append(points, fmt.Sprintf("%.6f,%.6f", s.Latitude, s.Longitude))
+	}
+	return "https://yandex.kz/maps/?rtt=auto&rtext=" + strings.Join(points, "~")
+}
+
+// AdminRoutesHandler handles /api/admin/routes?courier=..&date=YYYY-MM-DD
+func (h *Handler) AdminRoutesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	courierID, err := strconv.ParseInt(r.URL.Query().Get("courier"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Invalid courier",
+		})
+		return
+	}
+
+	day := time.Now().In(h.cfg.Location)
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		day, err = time.ParseInLocation("2006-01-02", dateStr, h.cfg.Location)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Invalid date, expected YYYY-MM-DD",
+			})
+			return
+		}
+	}
+
+	route, err := h.buildCourierRoute(h.ctx, courierID, day)
+	if err != nil {
+		h.logger.Error("Failed to build courier route", zap.Int64("courier_id", courierID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    route,
+	})
+}
+
+// CourierRouteHandler handles /route and the route button: today's optimized route for the courier
+func (h *Handler) CourierRouteHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+
+	userID := update.Message.From.ID
+	lang := h.fromLang(ctx, update.Message.From)
+	courier, err := h.repo.GetActiveCourierByUserID(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get courier", zap.Error(err))
+		return
+	}
+	if courier == nil {
+		h.StartHandler(ctx, b, update)
+		return
+	}
+
+	route, err := h.buildCourierRoute(ctx, courier.ID, time.Now().In(h.cfg.Location))
+	if err != nil {
+		h.logger.Error("Failed to build courier route", zap.Int64("courier_id", courier.ID), zap.Error(err))
+		return
+	}
+
+	if len(route.Stops) == 0 && len(route.Unlocated) == 0 {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      userID,
+			Text:        i18n.T(lang, "route.empty"),
+			ReplyMarkup: courierKeyboard(lang),
+		})
+		if err != nil {
+			h.logger.Warn("Failed to send empty route", zap.Error(err))
+		}
+		return
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(i18n.T(lang, "route.title", i18n.Args{"date": route.Date}))
+	sb.WriteString(i18n.T(lang, "route.total", i18n.Args{"km": fmt.Sprintf("%.1f", route.TotalKm)}))
+	for _, s := range route.Stops {
+		sb.WriteString(i18n.T(lang, "route.stop", i18n.Args{
+			"seq":     s.Sequence,
+			"id":      s.OrderID,
+			"address": s.Address,
+			"fio":     s.Fio,
+			"phone":   s.Contact,
+			"km":      fmt.Sprintf("%.1f", s.LegKm),
+		}))
+	}
+	if len(route.Unlocated) > 0 {
+		sb.WriteString(i18n.T(lang, "route.unlocated"))
+		for _, o := range route.Unlocated {
+			sb.WriteString(fmt.Sprintf("• #%d %s\n", o.OrderID, o.Address))
+		}
+	}
+
+	params := &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        sb.String(),
+		ReplyMarkup: courierKeyboard(lang),
+	}
+	if len(route.Stops) > 0 {
+		params.ReplyMarkup = &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: i18n.T(lang, "button.open_map"), URL: routeMapURL(route)}},
+			},
+		}
+	}
+	if _, err := b.SendMessage(ctx, params); err != nil {
+		h.logger.Warn("Failed to send courier route", zap.Error(err))
+	}
+}
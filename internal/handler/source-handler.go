@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"meily/internal/repository"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sourcePattern is a campaign deep-link payload, e.g. insta_reels_0714 in
+// t.me/<bot>?start=insta_reels_0714. Telegram allows up to 64 of these characters.
+var sourcePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// startSource returns the campaign source of a "/start <payload>" message
+func startSource(text string) (string, bool) {
+	payload, ok := strings.CutPrefix(text, "/start ")
+	if !ok {
+		return "", false
+	}
+	payload = strings.ToLower(strings.TrimSpace(payload))
+	if !sourcePattern.MatchString(payload) {
+		return "", false
+	}
+	return payload, true
+}
+
+// recordSource saves the campaign source of a /start deep link: the first one stays on
+// the user, the last one is copied to the orders the user places afterwards
+func (h *Handler) recordSource(ctx context.Context, userID int64, text string) {
+	source, ok := startSource(text)
+	if !ok {
+		return
+	}
+	if err := h.repo.SetUserSource(ctx, userID, source); err != nil {
+		h.logger.Error("Failed to save user source", zap.Int64("user_id", userID), zap.String("source", source), zap.Error(err))
+		return
+	}
+	h.logger.Info("User came from campaign", zap.Int64("user_id", userID), zap.String("source", source))
+}
+
+// AdminSourcesHandler handles /api/admin/sources: users, buyers, orders, revenue and
+// conversion per campaign source. model=first (default) credits a buyer's orders to the
+// source they first came from, model=last credits each order to the latest one. from and
+// to (YYYY-MM-DD) limit the registration and payment dates.
+func (h *Handler) AdminSourcesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	model := query.Get("model")
+	if model == "" {
+		model = repository.AttributionFirstTouch
+	}
+	if model != repository.AttributionFirstTouch && model != repository.AttributionLastTouch {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Invalid model, expected first or last",
+		})
+		return
+	}
+
+	from, to := query.Get("from"), query.Get("to")
+	for _, date := range []string{from, to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Invalid date, expected YYYY-MM-DD",
+			})
+			return
+		}
+	}
+
+	report, err := h.repo.GetSourceReport(h.ctx, model, from, to)
+	if err != nil {
+		h.logger.Error("Failed to get source report", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Message: "Attribution: " + model + "-touch",
+		Data:    report,
+	})
+}
@@ -0,0 +1,330 @@
+package handler
+
+import (
+	"context"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+const (
+	supportCallback = "support_open"
+
+	// supportTimeout returns a customer who went quiet in the support chat to the start
+	// state; the ticket stays open and support can still reply
+	supportTimeout = 24 * time.Hour
+
+	// supportHeaderInterval repeats the customer card in the support chat when the
+	// conversation resumes after a pause
+	supportHeaderInterval = time.Hour
+)
+
+// supportChatID is where customer messages are relayed: the support group, or the
+// admin's own chat when none is configured
+func (h *Handler) supportChatID() int64 {
+	if h.cfg.SupportChatID != 0 {
+		return h.cfg.SupportChatID
+	}
+	return h.cfg.AdminID
+}
+
+// HelpHandler handles /help: opens the chat with support
+func (h *Handler) HelpHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || update.Message.From == nil || update.Message.Chat.Type != models.ChatTypePrivate {
+		return
+	}
+	h.openSupport(ctx, b, update, update.Message.From)
+}
+
+// SupportCallbackHandler handles the 💬 Support button
+func (h *Handler) SupportCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID}); err != nil {
+		h.logger.Warn("Failed to answer callback query", zap.Error(err))
+	}
+	h.openSupport(ctx, b, update, &update.CallbackQuery.From)
+}
+
+func (h *Handler) openSupport(ctx context.Context, b *bot.Bot, update *models.Update, from *models.User) {
+	lang := h.fromLang(ctx, from)
+	if !h.moveUser(ctx, b, update, from.ID, &domain.UserState{State: stateSupport}) {
+		return
+	}
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: from.ID,
+		Text:   i18n.T(lang, "support.prompt"),
+		ReplyMarkup: &models.ReplyKeyboardMarkup{
+			Keyboard:       [][]models.KeyboardButton{{{Text: i18n.T(lang, "button.support_close")}}},
+			ResizeKeyboard: true,
+		},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send support prompt", zap.Error(err))
+	}
+}
+
+// SupportMessageHandler relays a customer's message in the support state to the support chat
+func (h *Handler) SupportMessageHandler(ctx context.Context, b *bot.Bot, update *models.Update, _ *domain.UserState) {
+	msg := update.Message
+	if msg == nil || msg.From == nil {
+		return
+	}
+	userID := msg.From.ID
+	lang := h.fromLang(ctx, msg.From)
+
+	switch {
+	case i18n.Matches("button.support_close", msg.Text):
+		h.closeSupportByUser(ctx, b, userID, lang)
+		return
+	case msg.Text == "/start" || strings.HasPrefix(msg.Text, "/start "):
+		// Leaving the chat for the shop; the ticket stays open for the reply
+		h.resetUser(ctx, b, userID)
+		h.StartHandler(ctx, b, update)
+		return
+	}
+
+	ticket, err := h.repo.OpenSupportTicket(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to open support ticket", zap.Int64("user_id", userID), zap.Error(err))
+		h.sendHint(ctx, b, userID, i18n.T(lang, "support.failed"))
+		return
+	}
+
+	supportChat := h.supportChatID()
+	resumed := ticket.LastUserMessageAt == nil || time.Since(*ticket.LastUserMessageAt) > supportHeaderInterval
+	if resumed {
+		h.sendSupportHeader(ctx, b, ticket, msg.From)
+	}
+
+	copied, err := b.CopyMessage(ctx, &bot.CopyMessageParams{
+		ChatID:     supportChat,
+		FromChatID: msg.Chat.ID,
+		MessageID:  msg.ID,
+	})
+	if err != nil {
+		h.logger.Error("Failed to relay support message", zap.Int64("ticket", ticket.ID), zap.Error(err))
+		h.sendHint(ctx, b, userID, i18n.T(lang, "support.failed"))
+		return
+	}
+
+	kind, _, text := h.parseMessage(msg)
+	if kind == "" {
+		kind = "other"
+	}
+	h.saveSupportMessage(ctx, domain.SupportMessage{
+		TicketID:  ticket.ID,
+		Direction: domain.SupportMessageIn,
+		ChatID:    supportChat,
+		MessageID: copied.ID,
+		FromID:    userID,
+		Kind:      kind,
+		Text:      text,
+	})
+
+	if resumed {
+		h.sendHint(ctx, b, userID, i18n.T(lang, "support.sent"))
+	}
+}
+
+// sendSupportHeader posts the customer card: who is writing, their phone and last order
+func (h *Handler) sendSupportHeader(ctx context.Context, b *bot.Bot, ticket *domain.SupportTicket, from *models.User) {
+	lang := h.adminLang(ctx)
+	unknown := i18n.T(lang, "admin.support.unknown")
+
+	name := strings.TrimSpace(from.FirstName + " " + from.LastName)
+	if from.Username != "" {
+		name += " (@" + from.Username + ")"
+	}
+
+	phone, order := unknown, i18n.T(lang, "admin.support.no_order")
+	orders, err := h.repo.GetOrdersByUserID(ctx, from.ID)
+	if err != nil {
+		h.logger.Warn("Failed to get customer orders", zap.Int64("user_id", from.ID), zap.Error(err))
+	}
+	if len(orders) > 0 {
+		last := orders[0]
+		if last.Contact != "" {
+			phone = last.Contact
+		}
+		order = i18n.T(lang, "admin.support.order", i18n.Args{
+			"id":     last.OrderID,
+			"status": orderStatusLabel(lang, last.Status),
+		})
+	}
+
+	header, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: h.supportChatID(),
+		Text: i18n.T(lang, "admin.support.header", i18n.Args{
+			"id":      ticket.ID,
+			"name":    name,
+			"user_id": from.ID,
+			"phone":   phone,
+			"order":   order,
+		}),
+	})
+	if err != nil {
+		h.logger.Error("Failed to send support header", zap.Int64("ticket", ticket.ID), zap.Error(err))
+		return
+	}
+	// A reply to the card reaches the customer too
+	h.saveSupportMessage(ctx, domain.SupportMessage{
+		TicketID:  ticket.ID,
+		Direction: domain.SupportMessageIn,
+		ChatID:    header.Chat.ID,
+		MessageID: header.ID,
+		FromID:    from.ID,
+		Kind:      "header",
+	})
+}
+
+func (h *Handler) closeSupportByUser(ctx context.Context, b *bot.Bot, userID int64, lang string) {
+	h.resetUser(ctx, b, userID)
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        i18n.T(lang, "support.closed"),
+		ReplyMarkup: &models.ReplyKeyboardRemove{RemoveKeyboard: true},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send support closed message", zap.Error(err))
+	}
+
+	ticket, err := h.repo.GetOpenSupportTicket(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get support ticket", zap.Int64("user_id", userID), zap.Error(err))
+		return
+	}
+	if ticket == nil {
+		// Nothing was asked, support has nothing to close
+		return
+	}
+	closed, err := h.repo.CloseSupportTicket(ctx, ticket.ID)
+	if err != nil {
+		h.logger.Error("Failed to close support ticket", zap.Int64("ticket", ticket.ID), zap.Error(err))
+		return
+	}
+	if !closed {
+		return
+	}
+	h.sendHint(ctx, b, h.supportChatID(), i18n.T(h.adminLang(ctx), "admin.support.closed_by_user", i18n.Args{"id": ticket.ID}))
+}
+
+// handleSupportChat handles messages in the support chat: a reply to a relayed message
+// goes back to the customer, /close in reply closes the ticket. It reports whether the
+// update belonged to the support chat and must not reach the customer flows.
+func (h *Handler) handleSupportChat(ctx context.Context, b *bot.Bot, update *models.Update) bool {
+	msg := update.Message
+	supportChat := h.supportChatID()
+	if msg.Chat.ID != supportChat {
+		return false
+	}
+	// In a group every message is support staff talk; in the admin's own chat only
+	// replies to relayed messages are
+	inGroup := msg.Chat.Type != models.ChatTypePrivate
+	if msg.ReplyToMessage == nil {
+		return inGroup
+	}
+
+	ticket, err := h.repo.GetSupportTicketByMessage(ctx, supportChat, msg.ReplyToMessage.ID)
+	if err != nil {
+		h.logger.Error("Failed to find support ticket", zap.Error(err))
+		return inGroup
+	}
+	if ticket == nil {
+		return inGroup
+	}
+	adminLang := h.adminLang(ctx)
+
+	if command := strings.SplitN(msg.Text, "@", 2)[0]; command == "/close" {
+		closed, err := h.repo.CloseSupportTicket(ctx, ticket.ID)
+		if err != nil {
+			h.logger.Error("Failed to close support ticket", zap.Int64("ticket", ticket.ID), zap.Error(err))
+			return true
+		}
+		if !closed {
+			h.sendHint(ctx, b, supportChat, i18n.T(adminLang, "admin.support.already_closed", i18n.Args{"id": ticket.ID}))
+			return true
+		}
+		h.sendHint(ctx, b, supportChat, i18n.T(adminLang, "admin.support.closed", i18n.Args{"id": ticket.ID}))
+
+		lang := h.userLang(ctx, ticket.UserID)
+		if cur, err := h.userFlow.Current(ctx, ticket.UserID); err == nil && cur.State == stateSupport {
+			h.resetUser(ctx, b, ticket.UserID)
+		}
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      ticket.UserID,
+			Text:        i18n.T(lang, "support.closed_by_admin"),
+			ReplyMarkup: &models.ReplyKeyboardRemove{RemoveKeyboard: true},
+		})
+		if err != nil {
+			h.logger.Warn("Failed to notify customer of closed ticket", zap.Int64("user_id", ticket.UserID), zap.Error(err))
+		}
+		return true
+	}
+
+	if _, err := b.CopyMessage(ctx, &bot.CopyMessageParams{
+		ChatID:     ticket.UserID,
+		FromChatID: supportChat,
+		MessageID:  msg.ID,
+	}); err != nil {
+		h.logger.Warn("Failed to deliver support reply", zap.Int64("ticket", ticket.ID), zap.Error(err))
+		h.sendHint(ctx, b, supportChat, i18n.T(adminLang, "admin.support.delivery_failed", i18n.Args{"error": err.Error()}))
+		return true
+	}
+
+	kind, _, text := h.parseMessage(msg)
+	if kind == "" {
+		kind = "other"
+	}
+	h.saveSupportMessage(ctx, domain.SupportMessage{
+		TicketID:  ticket.ID,
+		Direction: domain.SupportMessageOut,
+		ChatID:    supportChat,
+		MessageID: msg.ID,
+		FromID:    msg.From.ID,
+		Kind:      kind,
+		Text:      text,
+	})
+	return true
+}
+
+func (h *Handler) saveSupportMessage(ctx context.Context, m domain.SupportMessage) {
+	if err := h.repo.AddSupportMessage(ctx, m); err != nil {
+		h.logger.Error("Failed to save support message", zap.Int64("ticket", m.TicketID), zap.Error(err))
+	}
+}
+
+// AdminTicketsHandler handles /tickets: lists the open support tickets
+func (h *Handler) AdminTicketsHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || !h.allowed(ctx, b, update.Message.From.ID, domain.PermissionSupport) {
+		return
+	}
+	lang := h.userLang(ctx, update.Message.From.ID)
+
+	tickets, err := h.repo.GetOpenSupportTickets(ctx)
+	if err != nil {
+		h.logger.Error("Failed to get support tickets", zap.Error(err))
+		return
+	}
+	if len(tickets) == 0 {
+		h.sendHint(ctx, b, update.Message.Chat.ID, i18n.T(lang, "admin.support.no_tickets"))
+		return
+	}
+
+	lines := make([]string, len(tickets))
+	for i, t := range tickets {
+		lines[i] = i18n.T(lang, "admin.support.ticket_item", i18n.Args{
+			"id":      t.ID,
+			"name":    t.UserName,
+			"user_id": t.UserID,
+			"date":    t.CreatedAt.In(h.cfg.Location).Format("2006-01-02 15:04"),
+		})
+	}
+	h.sendHint(ctx, b, update.Message.Chat.ID, i18n.T(lang, "admin.support.tickets", i18n.Args{"items": strings.Join(lines, "\n")}))
+}
@@ -0,0 +1,497 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"meily/internal/domain"
+	"meily/internal/fsm"
+	"meily/internal/i18n"
+	"meily/internal/service"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+const (
+	templateCallbackPrefix = "tpl_"
+
+	// templateTimeout cancels a template edit the admin walked away from
+	templateTimeout = 30 * time.Minute
+)
+
+const sampleTickets = "🎫 Your tickets:\n•00000017\n•00000018\n•00000019"
+
+// messageTemplateDefs lists the customer-facing messages admins can edit with /templates.
+// The sample values are used to validate a template on save and to preview it.
+var messageTemplateDefs = []service.TemplateDef{
+	{
+		Key:         "start.promo",
+		Description: "Caption of the promo video sent on /start",
+		MaxLength:   service.MaxCaptionLength,
+	},
+	{
+		Key:         "inline.card",
+		Description: "Product card shared with @bot in any chat",
+		Sample:      map[string]interface{}{"price": "18 900", "tickets": domain.TicketsPerSet},
+		MaxLength:   service.MaxCaptionLength,
+	},
+	{
+		Key:         "order.pay_prompt",
+		Description: "Payment request after the number of sets is chosen",
+		Sample:      map[string]interface{}{"amount": 37800},
+	},
+	{
+		Key:         "payment.receipt_accepted",
+		Description: "Receipt accepted, with the lottery tickets",
+		Sample:      map[string]interface{}{"count": 1, "amount": "18 900", "tickets": sampleTickets},
+	},
+	{
+		Key:         "payment.receipt_accepted_lottery",
+		Description: "Receipt accepted after the number of sets was chosen",
+		Sample:      map[string]interface{}{"count": 1, "amount": "18 900", "tickets": sampleTickets},
+	},
+	{
+		Key:         "payment.invoice_paid",
+		Description: "Telegram invoice paid, with the lottery tickets",
+		Sample:      map[string]interface{}{"count": 1, "amount": "18 900", "tickets": sampleTickets},
+	},
+	{
+		Key:         "payment.amount_mismatch",
+		Description: "The receipt amount differs from the chosen number of sets",
+		Sample:      map[string]interface{}{"predicted": 2},
+	},
+	{Key: "payment.pdf_only", Description: "The receipt is not a PDF file"},
+	{Key: "payment.bad_receipt", Description: "The receipt could not be read"},
+	{Key: "payment.receipt_used", Description: "The receipt was already used"},
+	{Key: "payment.invalid_pdf", Description: "The receipt failed validation"},
+	{Key: "payment.wrong_bin", Description: "The payment went to another seller"},
+	{Key: "payment.wrong_price", Description: "The receipt amount is not a multiple of the price"},
+	{Key: "contact.prompt", Description: "Request to share the contact"},
+	{
+		Key:         "contact.received",
+		Description: "Caption of the address form after the contact is shared",
+		MaxLength:   service.MaxCaptionLength,
+	},
+	{
+		Key:         "order.confirmed",
+		Description: "Order confirmation after the address is saved",
+		Sample:      map[string]interface{}{"fio": "Aigerim Sarsenova", "contact": "+77011234567", "address": "Shymkent, Tauke Khan Ave 1"},
+	},
+	{
+		Key:         "delivery.code",
+		Description: "Caption of the delivery QR code",
+		Sample:      map[string]interface{}{"id": 42, "code": "1234"},
+		MaxLength:   service.MaxCaptionLength,
+	},
+	{
+		Key:         "reminder.unpaid",
+		Description: "Reminder about a started but unpaid checkout",
+		Sample:      map[string]interface{}{"count": 2, "amount": "37 800"},
+	},
+	{
+		Key:         "reminder.not_chosen",
+		Description: "Reminder for a user who has not chosen the number of sets",
+		Sample:      map[string]interface{}{"price": "18 900"},
+	},
+}
+
+// text returns a customer-facing message: the admin's template when one is saved for
+// the language, otherwise the built-in text from the catalog. A template that fails
+// to render is logged and the built-in text is sent instead.
+func (h *Handler) text(lang, key string, args ...i18n.Args) string {
+	var values i18n.Args
+	if len(args) > 0 {
+		values = args[0]
+	}
+	text, ok, err := h.templates.Render(key, lang, values)
+	if err != nil {
+		h.logger.Error("Failed to render message template", zap.String("key", key), zap.String("language", lang), zap.Error(err))
+	}
+	if ok {
+		return text
+	}
+	return i18n.T(lang, key, args...)
+}
+
+// LoadMessageTemplates loads the templates saved by admins. A stored template that no
+// longer compiles is skipped, so the built-in text is used for it.
+func (h *Handler) LoadMessageTemplates(ctx context.Context) error {
+	templates, err := h.repo.GetActiveTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range templates {
+		if err := h.templates.Set(t.Key, t.Language, t.Body); err != nil {
+			h.logger.Warn("Skipping invalid message template",
+				zap.String("key", t.Key), zap.String("language", t.Language), zap.Int("version", t.Version), zap.Error(err))
+		}
+	}
+	h.logger.Info("Message templates loaded", zap.Int("count", len(templates)))
+	return nil
+}
+
+// AdminTemplatesHandler handles /templates: lists the messages admins can edit
+func (h *Handler) AdminTemplatesHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || !h.allowed(ctx, b, update.Message.From.ID, domain.PermissionBroadcast) {
+		return
+	}
+
+	var rows [][]models.InlineKeyboardButton
+	for i, def := range h.templates.Defs() {
+		rows = append(rows, []models.InlineKeyboardButton{{
+			Text:         def.Key,
+			CallbackData: templateCallbackPrefix + "show_" + strconv.Itoa(i),
+		}})
+	}
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        i18n.T(h.userLang(ctx, update.Message.From.ID), "admin.templates.title"),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send templates list", zap.Error(err))
+	}
+}
+
+// TemplateCallbackHandler handles the tpl_<action>[_<index>[_<language>]] buttons of /templates
+func (h *Handler) TemplateCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	cq := update.CallbackQuery
+	if cq == nil || !h.allowed(ctx, b, cq.From.ID, domain.PermissionBroadcast) {
+		return
+	}
+	adminID := cq.From.ID
+	if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: cq.ID}); err != nil {
+		h.logger.Warn("Failed to answer callback query", zap.Error(err))
+	}
+
+	parts := strings.Split(strings.TrimPrefix(cq.Data, templateCallbackPrefix), "_")
+	switch parts[0] {
+	case "save":
+		h.saveTemplate(ctx, b, adminID)
+		return
+	case "cancel":
+		h.cancelTemplate(ctx, b, adminID)
+		return
+	}
+
+	if len(parts) < 2 {
+		return
+	}
+	defs := h.templates.Defs()
+	i, err := strconv.Atoi(parts[1])
+	if err != nil || i < 0 || i >= len(defs) {
+		return
+	}
+	def := defs[i]
+	if parts[0] == "show" {
+		h.showTemplate(ctx, b, adminID, i, def)
+		return
+	}
+
+	if len(parts) != 3 || !i18n.Supported(parts[2]) {
+		return
+	}
+	lang := parts[2]
+	switch parts[0] {
+	case "edit":
+		h.editTemplate(ctx, b, update, adminID, def, lang)
+	case "preview":
+		h.previewTemplate(ctx, b, adminID, def, lang)
+	case "rollback":
+		h.rollbackTemplate(ctx, b, adminID, def, lang)
+	}
+}
+
+// showTemplate sends the message description with its saved versions and actions
+func (h *Handler) showTemplate(ctx context.Context, b *bot.Bot, adminID int64, index int, def service.TemplateDef) {
+	adminLang := h.userLang(ctx, adminID)
+
+	status := make([]string, 0, len(i18n.Languages))
+	var rows [][]models.InlineKeyboardButton
+	for _, lang := range i18n.Languages {
+		t, err := h.repo.GetActiveTemplate(ctx, def.Key, lang)
+		if err != nil {
+			h.logger.Error("Failed to get message template", zap.String("key", def.Key), zap.Error(err))
+			return
+		}
+		if t != nil {
+			status = append(status, i18n.T(adminLang, "admin.templates.custom", i18n.Args{
+				"language": i18n.Name(lang),
+				"version":  t.Version,
+				"date":     t.CreatedAt.Format("2006-01-02 15:04"),
+			}))
+		} else {
+			status = append(status, i18n.T(adminLang, "admin.templates.builtin", i18n.Args{"language": i18n.Name(lang)}))
+		}
+
+		data := strconv.Itoa(index) + "_" + lang
+		args := i18n.Args{"language": lang}
+		rows = append(rows, []models.InlineKeyboardButton{
+			{Text: i18n.T(adminLang, "button.template_edit", args), CallbackData: templateCallbackPrefix + "edit_" + data},
+			{Text: i18n.T(adminLang, "button.template_preview", args), CallbackData: templateCallbackPrefix + "preview_" + data},
+			{Text: i18n.T(adminLang, "button.template_rollback", args), CallbackData: templateCallbackPrefix + "rollback_" + data},
+		})
+	}
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: adminID,
+		Text: i18n.T(adminLang, "admin.templates.item", i18n.Args{
+			"key":         def.Key,
+			"description": def.Description,
+			"fields":      templateFields(adminLang, def),
+			"status":      strings.Join(status, "\n"),
+		}),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send template", zap.Error(err))
+	}
+}
+
+// editTemplate starts editing the message in the language
+func (h *Handler) editTemplate(ctx context.Context, b *bot.Bot, update *models.Update, adminId int64, def service.TemplateDef, lang string) {
+	next := &domain.UserState{State: stateTemplateEdit, TemplateKey: def.Key, Language: lang}
+	err := h.adminFlow.Transition(ctx, adminId, next, &flowEvent{b: b, update: update, userID: adminId})
+	if errors.Is(err, fsm.ErrNotAllowed) {
+		h.sendHint(ctx, b, adminId, i18n.T(h.userLang(ctx, adminId), "admin.templates.busy"))
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to start template edit", zap.Error(err))
+	}
+}
+
+// sendTemplateEditor shows the current text of the message being edited
+func (h *Handler) sendTemplateEditor(ctx context.Context, e *flowEvent, s *domain.UserState) {
+	adminLang := h.userLang(ctx, e.userID)
+	def, _ := h.templates.Def(s.TemplateKey)
+
+	_, err := e.b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: e.userID,
+		Text: i18n.T(adminLang, "admin.templates.edit", i18n.Args{
+			"key":      def.Key,
+			"language": i18n.Name(s.Language),
+			"fields":   templateFields(adminLang, def),
+		}),
+		ReplyMarkup: &models.ReplyKeyboardMarkup{
+			Keyboard:       [][]models.KeyboardButton{{{Text: i18n.T(adminLang, "button.cancel")}}},
+			ResizeKeyboard: true,
+		},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send template editor", zap.Error(err))
+		return
+	}
+
+	// The source goes in a separate message so it can be copied as is
+	if _, err := e.b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: e.userID,
+		Text:   h.templateSource(ctx, def, s.Language),
+	}); err != nil {
+		h.logger.Warn("Failed to send template source", zap.Error(err))
+	}
+}
+
+// TemplateDraftHandler validates the text the admin sent for the message and shows
+// its preview with the save button
+func (h *Handler) TemplateDraftHandler(ctx context.Context, b *bot.Bot, update *models.Update, state *domain.UserState) {
+	if update.Message == nil || !h.can(ctx, update.Message.From.ID, domain.PermissionBroadcast) {
+		return
+	}
+	adminId := update.Message.From.ID
+	adminLang := h.userLang(ctx, adminId)
+	body := update.Message.Text
+
+	if i18n.Matches("button.cancel", body) {
+		h.cancelTemplate(ctx, b, adminId)
+		return
+	}
+
+	preview, err := h.templates.Preview(state.TemplateKey, body)
+	if err != nil {
+		h.sendHint(ctx, b, adminId, i18n.T(adminLang, "admin.templates.invalid", i18n.Args{"error": err.Error()}))
+		return
+	}
+
+	next := *state
+	next.State = stateTemplateConfirm
+	next.Draft = body
+	if err := h.adminFlow.Transition(ctx, adminId, &next, &flowEvent{b: b, update: update, userID: adminId}); err != nil {
+		h.logger.Error("Failed to save template draft", zap.Error(err))
+		return
+	}
+
+	h.sendTemplatePreview(ctx, b, adminId, state.TemplateKey, state.Language, preview)
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: adminId,
+		Text:   i18n.T(adminLang, "admin.templates.confirm"),
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{{
+				{Text: i18n.T(adminLang, "button.save"), CallbackData: templateCallbackPrefix + "save"},
+				{Text: i18n.T(adminLang, "button.cancel"), CallbackData: templateCallbackPrefix + "cancel"},
+			}},
+		},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send template confirmation", zap.Error(err))
+	}
+}
+
+// saveTemplate stores the confirmed draft as a new version and starts using it
+func (h *Handler) saveTemplate(ctx context.Context, b *bot.Bot, adminId int64) {
+	adminLang := h.userLang(ctx, adminId)
+
+	state, err := h.adminFlow.Current(ctx, adminId)
+	if err != nil {
+		h.logger.Error("Failed to get admin state", zap.Error(err))
+		return
+	}
+	if state.State != stateTemplateConfirm || state.Draft == "" {
+		h.sendHint(ctx, b, adminId, i18n.T(adminLang, h.adminFlow.Hint(state.State)))
+		return
+	}
+
+	// The draft was validated when it was sent, but the message may have changed since
+	if _, err := h.templates.Compile(state.TemplateKey, state.Draft); err != nil {
+		h.sendHint(ctx, b, adminId, i18n.T(adminLang, "admin.templates.invalid", i18n.Args{"error": err.Error()}))
+		return
+	}
+
+	t := &domain.MessageTemplate{
+		Key:       state.TemplateKey,
+		Language:  state.Language,
+		Body:      state.Draft,
+		CreatedBy: adminId,
+	}
+	if err := h.repo.SaveTemplate(ctx, t); err != nil {
+		h.logger.Error("Failed to save message template", zap.String("key", t.Key), zap.Error(err))
+		h.sendHint(ctx, b, adminId, i18n.T(adminLang, "admin.templates.save_failed"))
+		return
+	}
+	if err := h.templates.Set(t.Key, t.Language, t.Body); err != nil {
+		h.logger.Error("Failed to apply message template", zap.String("key", t.Key), zap.Error(err))
+	}
+	h.logger.Info("Message template saved",
+		zap.String("key", t.Key), zap.String("language", t.Language), zap.Int("version", t.Version))
+
+	h.finishTemplateEdit(ctx, b, adminId, i18n.T(adminLang, "admin.templates.saved", i18n.Args{
+		"key":      t.Key,
+		"language": i18n.Name(t.Language),
+		"version":  t.Version,
+	}))
+}
+
+// cancelTemplate drops the template being edited
+func (h *Handler) cancelTemplate(ctx context.Context, b *bot.Bot, adminID int64) {
+	h.finishTemplateEdit(ctx, b, adminID, i18n.T(h.userLang(ctx, adminID), "admin.templates.cancelled"))
+}
+
+func (h *Handler) finishTemplateEdit(ctx context.Context, b *bot.Bot, adminId int64, text string) {
+	if err := h.adminFlow.Reset(ctx, adminId, &flowEvent{b: b, userID: adminId}); err != nil {
+		h.logger.Error("Failed to reset admin state", zap.Error(err))
+	}
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      adminId,
+		Text:        text,
+		ReplyMarkup: &models.ReplyKeyboardRemove{RemoveKeyboard: true},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send template result", zap.Error(err))
+	}
+}
+
+// previewTemplate renders the message in use for the language with the sample values
+func (h *Handler) previewTemplate(ctx context.Context, b *bot.Bot, adminID int64, def service.TemplateDef, lang string) {
+	h.sendTemplatePreview(ctx, b, adminID, def.Key, lang, h.text(lang, def.Key, def.Sample))
+}
+
+func (h *Handler) sendTemplatePreview(ctx context.Context, b *bot.Bot, adminID int64, key, lang, preview string) {
+	adminLang := h.userLang(ctx, adminID)
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: adminID,
+		Text: i18n.T(adminLang, "admin.templates.preview", i18n.Args{
+			"key":      key,
+			"language": i18n.Name(lang),
+		}),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send template preview", zap.Error(err))
+		return
+	}
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{ChatID: adminID, Text: preview}); err != nil {
+		h.logger.Warn("Failed to send template preview", zap.Error(err))
+	}
+}
+
+// rollbackTemplate reverts the version in use, bringing back the previous one or the built-in text
+func (h *Handler) rollbackTemplate(ctx context.Context, b *bot.Bot, adminID int64, def service.TemplateDef, lang string) {
+	adminLang := h.userLang(ctx, adminID)
+	args := i18n.Args{"key": def.Key, "language": i18n.Name(lang)}
+
+	t, err := h.repo.RollbackTemplate(ctx, def.Key, lang)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.sendHint(ctx, b, adminID, i18n.T(adminLang, "admin.templates.nothing_to_roll_back"))
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to roll back message template", zap.String("key", def.Key), zap.Error(err))
+		return
+	}
+
+	text := i18n.T(adminLang, "admin.templates.rolled_back_builtin", args)
+	if t != nil {
+		if err := h.templates.Set(t.Key, t.Language, t.Body); err != nil {
+			// An older version that no longer compiles is not used either
+			h.logger.Warn("Previous message template is invalid", zap.String("key", t.Key), zap.Error(err))
+			h.templates.Remove(def.Key, lang)
+		} else {
+			args["version"] = t.Version
+			text = i18n.T(adminLang, "admin.templates.rolled_back", args)
+		}
+	} else {
+		h.templates.Remove(def.Key, lang)
+	}
+	h.logger.Info("Message template rolled back", zap.String("key", def.Key), zap.String("language", lang))
+	h.sendHint(ctx, b, adminID, text)
+}
+
+// templateSource returns the template in use for the language. The built-in text is
+// converted to the template syntax so it can be taken as a starting point.
+func (h *Handler) templateSource(ctx context.Context, def service.TemplateDef, lang string) string {
+	t, err := h.repo.GetActiveTemplate(ctx, def.Key, lang)
+	if err != nil {
+		h.logger.Warn("Failed to get message template", zap.String("key", def.Key), zap.Error(err))
+	}
+	if t != nil {
+		return t.Body
+	}
+	text, _ := i18n.Raw(lang, def.Key)
+	for name := range def.Sample {
+		text = strings.ReplaceAll(text, "{"+name+"}", "{{."+service.TemplateField(name)+"}}")
+	}
+	return text
+}
+
+func templateFields(lang string, def service.TemplateDef) string {
+	fields := def.Fields()
+	if len(fields) == 0 {
+		return i18n.T(lang, "admin.templates.no_fields")
+	}
+	return strings.Join(fields, ", ")
+}
+
+func (h *Handler) templateEditExpired(ctx context.Context, e *flowEvent, _ *domain.UserState) {
+	_, err := e.b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      e.userID,
+		Text:        i18n.T(h.userLang(ctx, e.userID), "flow.timeout.template"),
+		ReplyMarkup: &models.ReplyKeyboardRemove{RemoveKeyboard: true},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send template timeout", zap.Error(err))
+	}
+}
@@ -0,0 +1,375 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/internal/repository"
+	"meily/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// OrderStatusRequest is the body of POST /api/admin/orders/status
+type OrderStatusRequest struct {
+	OrderID        int64  `json:"orderId"`
+	Status         string `json:"status"`
+	TrackingNumber string `json:"trackingNumber,omitempty"`
+}
+
+// adminSettableStatuses are the statuses an admin may set by hand; courier
+// statuses go through the courier bot and delivery proof.
+var adminSettableStatuses = map[string]bool{
+	domain.OrderStatusNew:       true,
+	domain.OrderStatusPacked:    true,
+	domain.OrderStatusShipped:   true,
+	domain.OrderStatusDelivered: true,
+}
+
+// estimateArrival estimates when the courier reaches the order: the courier's remaining
+// stops are optimized from the warehouse and the distance is converted with the average speed.
+func (h *Handler) estimateArrival(ctx context.Context, order domain.CourierOrder, now time.Time) *time.Time {
+	if order.CourierID == nil || h.cfg.CourierSpeedKmh <= 0 {
+		return nil
+	}
+	if order.Status != domain.OrderStatusAssigned && order.Status != domain.OrderStatusPickedUp {
+		return nil
+	}
+
+	orders, err := h.repo.GetCourierActiveOrders(ctx, *order.CourierID)
+	if err != nil {
+		h.logger.Warn("Failed to load courier orders for ETA", zap.Int64("order_id", order.OrderID), zap.Error(err))
+		return nil
+	}
+	stops, _ := routeStops(orders)
+	route, _ := service.OptimizeRoute(h.cfg.WarehouseLat, h.cfg.WarehouseLon, stops, repository.CalculateDistance)
+
+	var km float64
+	for _, stop := range route {
+		km += stop.LegKm
+		if stop.OrderID != order.OrderID {
+			continue
+		}
+		travel := time.Duration(km / h.cfg.CourierSpeedKmh * float64(time.Hour))
+		eta := now.Add(travel + time.Duration(stop.Sequence-1)*h.cfg.CourierStopDuration)
+		return &eta
+	}
+	return nil
+}
+
+// customerOrderTracking builds the tracking view of all orders of a user
+func (h *Handler) customerOrderTracking(ctx context.Context, userID int64) ([]domain.OrderTracking, error) {
+	lang := h.userLang(ctx, userID)
+	orders, err := h.repo.GetOrdersByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tracking := make([]domain.OrderTracking, 0, len(orders))
+	for _, o := range orders {
+		t := domain.OrderTracking{
+			OrderID:        o.OrderID,
+			Status:         o.Status,
+			StatusLabel:    orderStatusLabel(lang, o.Status),
+			Address:        o.Address,
+			TrackingNumber: o.TrackingNumber,
+			UpdatedAt:      o.StatusUpdatedAt,
+		}
+		if o.CourierID != nil && (o.Status == domain.OrderStatusAssigned || o.Status == domain.OrderStatusPickedUp) {
+			if courier, err := h.repo.GetCourierByID(ctx, *o.CourierID); err == nil {
+				t.CourierName = courier.Name
+				t.CourierPhone = courier.Phone
+			}
+			t.ETA = h.estimateArrival(ctx, o, now)
+		}
+		tracking = append(tracking, t)
+	}
+	return tracking, nil
+}
+
+func (h *Handler) formatOrderTracking(lang string, t domain.OrderTracking) string {
+	sb := strings.Builder{}
+	sb.WriteString(i18n.T(lang, "tracking.order", i18n.Args{"id": t.OrderID}))
+	sb.WriteString(i18n.T(lang, "tracking.status", i18n.Args{"status": t.StatusLabel}))
+	if t.Address != "" {
+		sb.WriteString(i18n.T(lang, "tracking.address", i18n.Args{"address": t.Address}))
+	}
+	if t.CourierName != "" {
+		sb.WriteString(i18n.T(lang, "tracking.courier", i18n.Args{"name": t.CourierName}))
+		if t.CourierPhone != "" {
+			sb.WriteString(fmt.Sprintf(" (%s)", t.CourierPhone))
+		}
+		sb.WriteString("\n")
+	}
+	if t.ETA != nil {
+		sb.WriteString(i18n.T(lang, "tracking.eta", i18n.Args{"time": t.ETA.In(h.cfg.Location).Format("15:04")}))
+	}
+	if t.TrackingNumber != "" {
+		sb.WriteString(i18n.T(lang, "tracking.number", i18n.Args{"number": t.TrackingNumber}))
+	}
+	if t.TrackingURL != "" {
+		sb.WriteString(fmt.Sprintf("🌐 %s\n", t.TrackingURL))
+	}
+	if t.UpdatedAt != nil {
+		sb.WriteString(i18n.T(lang, "tracking.updated", i18n.Args{"time": t.UpdatedAt.In(h.cfg.Location).Format("02.01.2006 15:04")}))
+	}
+	return sb.String()
+}
+
+// StatusCommandHandler handles /status: the user's orders with status, courier ETA and tracking number
+func (h *Handler) StatusCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+
+	userID := update.Message.From.ID
+	lang := h.fromLang(ctx, update.Message.From)
+	tracking, err := h.customerOrderTracking(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get order tracking", zap.Int64("user_id", userID), zap.Error(err))
+		return
+	}
+
+	if len(tracking) == 0 {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: userID,
+			Text:   i18n.T(lang, "tracking.no_orders"),
+			ReplyMarkup: &models.InlineKeyboardMarkup{
+				InlineKeyboard: [][]models.InlineKeyboardButton{
+					{{Text: i18n.T(lang, "button.buy"), CallbackData: "buy_cosmetics"}},
+				},
+			},
+		})
+		if err != nil {
+			h.logger.Warn("Failed to send empty status", zap.Error(err))
+		}
+		return
+	}
+
+	parts := make([]string, 0, len(tracking))
+	for _, t := range tracking {
+		parts = append(parts, h.formatOrderTracking(lang, t))
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: userID,
+		Text:   strings.Join(parts, "\n"),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send order status", zap.Error(err))
+	}
+}
+
+// ClientOrdersHandler handles /api/client/orders - order tracking for the Mini App.
+// The orders carry the address and the courier's phone, so the customer is taken from
+// the signed Web App init data, never from the request body.
+func (h *Handler) ClientOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := webAppUserID(webAppInitData(r), h.cfg.Token, time.Now())
+	if err != nil {
+		h.logger.Warn("Client orders request rejected", zap.Error(err))
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Open the app from Telegram",
+		})
+		return
+	}
+
+	tracking, err := h.customerOrderTracking(h.ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get order tracking", zap.Int64("telegram_id", userID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    tracking,
+	})
+}
+
+// notifyOrderStatus pushes a status transition to the customer
+func (h *Handler) notifyOrderStatus(ctx context.Context, b *bot.Bot, order domain.CourierOrder) {
+	lang := h.userLang(ctx, order.UserID)
+	args := i18n.Args{"id": order.OrderID}
+
+	var text string
+	switch order.Status {
+	case domain.OrderStatusPacked:
+		text = i18n.T(lang, "notify.packed", args)
+	case domain.OrderStatusShipped:
+		text = i18n.T(lang, "notify.shipped", args)
+		if order.TrackingNumber != "" {
+			text += "\n\n" + i18n.T(lang, "tracking.number", i18n.Args{"number": order.TrackingNumber})
+		}
+	case domain.OrderStatusDelivered:
+		text = i18n.T(lang, "notify.delivered", args)
+	case domain.OrderStatusFailed:
+		text = i18n.T(lang, "notify.failed", args)
+	default:
+		return
+	}
+	text = strings.TrimRight(text, "\n") + "\n\n" + i18n.T(lang, "notify.status_hint")
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: order.UserID,
+		Text:   text,
+	})
+	if err != nil {
+		h.logger.Warn("Failed to notify customer about order status",
+			zap.Int64("order_id", order.OrderID),
+			zap.String("status", order.Status),
+			zap.Error(err))
+	}
+}
+
+// changeOrderStatus sets an admin status (and optional tracking number) and notifies the customer
+func (h *Handler) changeOrderStatus(ctx context.Context, b *bot.Bot, orderID int64, status, trackingNumber string) (*domain.CourierOrder, error) {
+	if trackingNumber != "" {
+		if err := h.repo.SetOrderTracking(ctx, orderID, trackingNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	order, err := h.repo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == status {
+		return order, nil
+	}
+
+	if err := h.repo.UpdateOrderStatus(ctx, orderID, status); err != nil {
+		return nil, err
+	}
+	order.Status = status
+
+	if b != nil {
+		h.notifyOrderStatus(ctx, b, *order)
+	}
+	return order, nil
+}
+
+// AdminSetStatusHandler handles /setstatus <order_id> <status> [tracking_number]
+func (h *Handler) AdminSetStatusHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || !h.allowed(ctx, b, update.Message.From.ID, domain.PermissionOrders) {
+		return
+	}
+
+	lang := h.fromLang(ctx, update.Message.From)
+	reply := func(key string, args ...i18n.Args) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   i18n.T(lang, key, args...),
+		})
+		if err != nil {
+			h.logger.Error("Failed to send setstatus reply", zap.Error(err))
+		}
+	}
+
+	fields := strings.Fields(update.Message.Text)
+	if len(fields) < 3 || len(fields) > 4 {
+		reply("admin.setstatus.usage")
+		return
+	}
+	orderID, err := strconv.ParseInt(strings.TrimPrefix(fields[1], "#"), 10, 64)
+	if err != nil {
+		reply("admin.setstatus.bad_order_id")
+		return
+	}
+	status := strings.ToLower(fields[2])
+	if !adminSettableStatuses[status] {
+		reply("admin.setstatus.bad_status")
+		return
+	}
+	var trackingNumber string
+	if len(fields) == 4 {
+		trackingNumber = fields[3]
+	}
+
+	order, err := h.changeOrderStatus(ctx, b, orderID, status, trackingNumber)
+	if err == sql.ErrNoRows {
+		reply("admin.setstatus.not_found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to change order status", zap.Int64("order_id", orderID), zap.Error(err))
+		reply("admin.setstatus.failed")
+		return
+	}
+	reply("admin.setstatus.done", i18n.Args{"id": order.OrderID, "status": orderStatusLabel(lang, order.Status)})
+}
+
+// AdminOrderStatusHandler handles /api/admin/orders/status
+func (h *Handler) AdminOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req OrderStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+	if !adminSettableStatuses[req.Status] {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Status must be one of new, packed, shipped, delivered",
+		})
+		return
+	}
+
+	order, err := h.changeOrderStatus(h.ctx, h.bot, req.OrderID, req.Status, req.TrackingNumber)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Order not found",
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to change order status", zap.Int64("order_id", req.OrderID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Data:    order,
+	})
+}
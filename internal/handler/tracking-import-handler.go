@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/internal/service"
+	"meily/traits/helper"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// maxTrackingFileSize limits uploaded tracking number files
+const maxTrackingFileSize = 5 << 20
+
+// trackingURL returns the carrier page for a tracking number, or "" for an unknown carrier
+func (h *Handler) trackingURL(carrier, trackingNumber string) string {
+	var format string
+	switch carrier {
+	case domain.CarrierKazpost:
+		format = h.cfg.KazpostTrackingURL
+	case domain.CarrierCDEK:
+		format = h.cfg.CDEKTrackingURL
+	}
+	if format == "" || trackingNumber == "" {
+		return ""
+	}
+	return fmt.Sprintf(format, trackingNumber)
+}
+
+// importTracking attaches tracking numbers to orders and moves them to shipped.
+// It returns the report and the orders whose customers should be notified. The orders
+// are saved in one transaction, so on error none of them is shipped.
+func (h *Handler) importTracking(ctx context.Context, rows []domain.TrackingImportRow, failed []domain.TrackingImportFailure) (*domain.TrackingImportReport, []domain.CourierOrder, error) {
+	report := &domain.TrackingImportReport{
+		Total:  len(rows) + len(failed),
+		Failed: failed,
+	}
+	fail := func(row domain.TrackingImportRow, reason string) {
+		report.Failed = append(report.Failed, domain.TrackingImportFailure{TrackingImportRow: row, Reason: reason})
+	}
+
+	var byPhone map[string][]int64
+	var shipped []domain.CourierOrder
+	pending := make(map[int64]int)
+	for _, row := range rows {
+		var orderID int64
+		if phone := helper.NormalizePhone(row.Key); strings.HasPrefix(phone, "+7") && len(phone) == 12 {
+			if byPhone == nil {
+				var err error
+				if byPhone, err = h.repo.GetOrderIDsByContact(ctx, helper.NormalizePhone); err != nil {
+					return nil, nil, err
+				}
+			}
+			switch ids := byPhone[phone]; len(ids) {
+			case 0:
+				fail(row, "no order with this phone")
+				continue
+			case 1:
+				orderID = ids[0]
+			default:
+				fail(row, fmt.Sprintf("phone matches %d orders, use the order ID", len(ids)))
+				continue
+			}
+		} else {
+			id, err := strconv.ParseInt(strings.TrimPrefix(row.Key, "#"), 10, 64)
+			if err != nil {
+				fail(row, "not an order ID or a phone number")
+				continue
+			}
+			orderID = id
+		}
+
+		// A later line for the same order wins, as if the lines were saved one by one
+		if i, ok := pending[orderID]; ok {
+			if shipped[i].TrackingNumber == row.TrackingNumber {
+				report.Unchanged++
+				continue
+			}
+			shipped[i].TrackingNumber, shipped[i].Carrier = row.TrackingNumber, row.Carrier
+			report.Updated++
+			continue
+		}
+
+		order, err := h.repo.GetOrderByID(ctx, orderID)
+		if err == sql.ErrNoRows {
+			fail(row, "order not found")
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if order.Status == domain.OrderStatusDelivered {
+			fail(row, "order already delivered")
+			continue
+		}
+		if order.Status == domain.OrderStatusShipped && order.TrackingNumber == row.TrackingNumber {
+			report.Unchanged++
+			continue
+		}
+
+		order.Status = domain.OrderStatusShipped
+		order.TrackingNumber = row.TrackingNumber
+		order.Carrier = row.Carrier
+		pending[orderID] = len(shipped)
+		shipped = append(shipped, *order)
+		report.Updated++
+	}
+
+	if err := h.repo.SetOrderShipments(ctx, shipped); err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(report.Failed, func(i, j int) bool { return report.Failed[i].Line < report.Failed[j].Line })
+	return report, shipped, nil
+}
+
+// notifyShipments sends the shipped message to every customer, within Telegram's broadcast limits
+func (h *Handler) notifyShipments(ctx context.Context, b *bot.Bot, orders []domain.CourierOrder) {
+	limiter := rate.NewLimiter(rate.Every(time.Second/30), 1)
+	for _, order := range orders {
+		if err := limiter.Wait(ctx); err != nil {
+			h.logger.Error("Rate limiter wait error", zap.Error(err))
+			return
+		}
+		h.notifyOrderStatus(ctx, b, order)
+	}
+	h.logger.Info("Tracking numbers sent to customers", zap.Int("count", len(orders)))
+}
+
+// AdminTrackingImportHandler handles POST /api/admin/tracking/import with a CSV file in the "file" field.
+// Customers are notified in the background; the response carries the import report.
+func (h *Handler) AdminTrackingImportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxTrackingFileSize)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "CSV file is required in the \"file\" field",
+		})
+		return
+	}
+	defer file.Close()
+
+	rows, failed, err := service.ParseTrackingCSV(file)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Invalid CSV: " + err.Error(),
+		})
+		return
+	}
+
+	report, shipped, err := h.importTracking(h.ctx, rows, failed)
+	if err != nil {
+		h.logger.Error("Failed to import tracking numbers", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: false,
+			Message: "Database error",
+		})
+		return
+	}
+	go h.notifyShipments(h.ctx, h.bot, shipped)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d orders shipped, %d failed", report.Updated, len(report.Failed)),
+		Data:    report,
+	})
+}
+
+// AdminTrackingDocumentHandler handles a CSV document sent to the bot with the /tracking caption
+func (h *Handler) AdminTrackingDocumentHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || !h.allowed(ctx, b, update.Message.From.ID, domain.PermissionOrders) {
+		return
+	}
+
+	lang := h.fromLang(ctx, update.Message.From)
+	reply := func(key string, args ...i18n.Args) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   i18n.T(lang, key, args...),
+		})
+		if err != nil {
+			h.logger.Error("Failed to send tracking import reply", zap.Error(err))
+		}
+	}
+
+	doc := update.Message.Document
+	if doc == nil {
+		reply("admin.tracking.usage")
+		return
+	}
+	if doc.FileSize > maxTrackingFileSize {
+		reply("admin.tracking.too_large")
+		return
+	}
+
+	file, err := b.GetFile(ctx, &bot.GetFileParams{FileID: doc.FileID})
+	if err != nil {
+		h.logger.Error("Failed to get tracking file", zap.Error(err))
+		reply("admin.tracking.get_failed")
+		return
+	}
+	resp, err := http.Get(b.FileDownloadLink(file))
+	if err != nil {
+		h.logger.Error("Failed to download tracking file", zap.Error(err))
+		reply("admin.tracking.download_failed")
+		return
+	}
+	defer resp.Body.Close()
+
+	rows, failed, err := service.ParseTrackingCSV(io.LimitReader(resp.Body, maxTrackingFileSize))
+	if err != nil {
+		reply("admin.tracking.csv_error", i18n.Args{"error": err.Error()})
+		return
+	}
+	report, shipped, err := h.importTracking(ctx, rows, failed)
+	if err != nil {
+		h.logger.Error("Failed to import tracking numbers", zap.Error(err))
+		reply("admin.tracking.save_failed")
+		return
+	}
+
+	reply("admin.tracking.report", i18n.Args{
+		"total":     report.Total,
+		"updated":   report.Updated,
+		"unchanged": report.Unchanged,
+		"failed":    len(report.Failed),
+	})
+
+	if len(report.Failed) > 0 {
+		var buf bytes.Buffer
+		if err := service.WriteCSV(&buf, service.TrackingReportRows(report.Failed), ";"); err != nil {
+			h.logger.Error("Failed to write tracking report", zap.Error(err))
+		} else {
+			_, err := b.SendDocument(ctx, &bot.SendDocumentParams{
+				ChatID: update.Message.Chat.ID,
+				Document: &models.InputFileUpload{
+					Filename: fmt.Sprintf("tracking_failed_%s.csv", time.Now().In(h.cfg.Location).Format("20060102_1504")),
+					Data:     &buf,
+				},
+				Caption: i18n.T(lang, "admin.tracking.failed_rows", i18n.Args{"count": len(report.Failed)}),
+			})
+			if err != nil {
+				h.logger.Error("Failed to send tracking report", zap.Error(err))
+			}
+		}
+	}
+
+	h.notifyShipments(ctx, b, shipped)
+	reply("admin.tracking.notified", i18n.Args{"count": len(shipped)})
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"go.uber.org/zap"
+)
+
+// webhookSecretHeader carries the secret token Telegram was given in setWebhook
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookPath returns the path of the webhook URL on the web server
+func (h *Handler) webhookPath() string {
+	u, err := url.Parse(h.cfg.WebhookURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// SetWebhook registers the webhook with Telegram. Pending updates are kept, so nothing
+// sent while the bot was down or in long polling mode is lost.
+func (h *Handler) SetWebhook(ctx context.Context, b *bot.Bot) error {
+	_, err := b.SetWebhook(ctx, &bot.SetWebhookParams{
+		URL:         h.cfg.WebhookURL,
+		SecretToken: h.cfg.WebhookSecret,
+	})
+	if err != nil {
+		return err
+	}
+	h.logger.Info("Webhook registered", zap.String("url", h.cfg.WebhookURL))
+	return nil
+}
+
+// DeleteWebhook deregisters the webhook on shutdown. The bot context is already
+// cancelled by then, so the request gets its own.
+func (h *Handler) DeleteWebhook(b *bot.Bot) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := b.DeleteWebhook(ctx, &bot.DeleteWebhookParams{}); err != nil {
+		h.logger.Error("Failed to delete webhook", zap.Error(err))
+		return
+	}
+	h.logger.Info("Webhook deleted")
+}
+
+// telegramWebhook accepts updates from Telegram. The bot only logs a wrong secret
+// and answers 200, so the header is checked here and anyone else gets 401.
+func (h *Handler) telegramWebhook(b *bot.Bot) http.HandlerFunc {
+	next := b.WebhookHandler()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		secret := r.Header.Get(webhookSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(h.cfg.WebhookSecret)) != 1 {
+			h.logger.Warn("Webhook request with a wrong secret token", zap.String("remote_addr", r.RemoteAddr))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
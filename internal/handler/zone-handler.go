@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/i18n"
+	"meily/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/go-telegram/bot"
+	"go.uber.org/zap"
+)
+
+// ZoneRequest is one zone in the body of POST /api/admin/zones
+type ZoneRequest struct {
+	Name         string          `json:"name"`
+	Fee          int             `json:"fee"`
+	DeliveryDays int             `json:"deliveryDays"`
+	Enabled      *bool           `json:"enabled,omitempty"`
+	GeoJSON      json.RawMessage `json:"geojson"`
+}
+
+// zoneFeatureCollection lets admins upload a whole GeoJSON file where each
+// feature carries name, fee, delivery_days and enabled in its properties.
+type zoneFeatureCollection struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Properties struct {
+			Name         string `json:"name"`
+			Fee          int    `json:"fee"`
+			DeliveryDays int    `json:"delivery_days"`
+			Enabled      *bool  `json:"enabled"`
+		} `json:"properties"`
+		Geometry json.RawMessage `json:"geometry"`
+	} `json:"features"`
+}
+
+// DeliveryQuote is the zone and fee returned to the Mini App after saving an address
+type DeliveryQuote struct {
+	ZoneID       int64  `json:"zoneId,omitempty"`
+	ZoneName     string `json:"zoneName,omitempty"`
+	Fee          int    `json:"fee"`
+	DeliveryDays int    `json:"deliveryDays,omitempty"`
+	OutOfZone    bool   `json:"outOfZone"`
+}
+
+// quoteDelivery finds the delivery zone for the coordinates.
+// It returns nil when no zone is enabled, so the check is skipped entirely.
+func (h *Handler) quoteDelivery(ctx context.Context, latitude, longitude float64) (*DeliveryQuote, *domain.DeliveryZone, error) {
+	zones, err := h.repo.GetDeliveryZones(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	anyEnabled := false
+	for _, z := range zones {
+		if z.Enabled {
+			anyEnabled = true
+			break
+		}
+	}
+	if !anyEnabled {
+		return nil, nil, nil
+	}
+
+	zone := service.FindZone(zones, latitude, longitude)
+	if zone == nil {
+		return &DeliveryQuote{OutOfZone: true}, nil, nil
+	}
+	return &DeliveryQuote{
+		ZoneID:       zone.ID,
+		ZoneName:     zone.Name,
+		Fee:          zone.Fee,
+		DeliveryDays: zone.DeliveryDays,
+	}, zone, nil
+}
+
+// notifyAdminOutOfZone warns the admin about an order that was accepted outside every zone
+func (h *Handler) notifyAdminOutOfZone(telegramID int64, fio, address string, latitude, longitude float64) {
+	if h.bot == nil {
+		return
+	}
+	h.notifyAdmins(h.ctx, domain.PermissionDelivery, func(chatID int64, lang string) error {
+		_, err := h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text: i18n.T(lang, "admin.out_of_zone", i18n.Args{
+				"fio":     fio,
+				"id":      telegramID,
+				"address": address,
+				"coords":  fmt.Sprintf("%.6f, %.6f", latitude, longitude),
+			}),
+		})
+		return err
+	})
+}
+
+// AdminZonesHandler handles /api/admin/zones:
+// GET lists zones, POST uploads a zone or a FeatureCollection of zones,
+// PATCH ?id=..&enabled=true|false toggles a zone, DELETE ?id=.. removes it.
+func (h *Handler) AdminZonesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		zones, err := h.repo.GetDeliveryZones(h.ctx)
+		if err != nil {
+			h.logger.Error("Failed to get delivery zones", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Database error",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: true,
+			Data:    zones,
+		})
+
+	case http.MethodPost:
+		zones, err := decodeZoneUpload(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		ids := make([]int64, 0, len(zones))
+		for _, z := range zones {
+			id, err := h.repo.UpsertDeliveryZone(h.ctx, z)
+			if err != nil {
+				h.logger.Error("Failed to save delivery zone", zap.String("name", z.Name), zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(APIResponse{
+					Success: false,
+					Message: "Database error",
+				})
+				return
+			}
+			ids = append(ids, id)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d zone(s) saved", len(ids)),
+			Data:    ids,
+		})
+
+	case http.MethodPatch, http.MethodDelete:
+		zoneID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Invalid zone id",
+			})
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			err = h.repo.DeleteDeliveryZone(h.ctx, zoneID)
+		} else {
+			var enabled bool
+			enabled, err = strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(APIResponse{
+					Success: false,
+					Message: "Invalid enabled flag",
+				})
+				return
+			}
+			err = h.repo.SetDeliveryZoneEnabled(h.ctx, zoneID, enabled)
+		}
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Zone not found",
+			})
+			return
+		}
+		if err != nil {
+			h.logger.Error("Failed to update delivery zone", zap.Int64("zone_id", zoneID), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(APIResponse{
+				Success: false,
+				Message: "Database error",
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(APIResponse{
+			Success: true,
+			Message: "Zone updated",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeZoneUpload reads either a single ZoneRequest or a FeatureCollection with zone properties
+func decodeZoneUpload(r *http.Request) ([]domain.DeliveryZone, error) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid request format")
+	}
+
+	var requests []ZoneRequest
+	var collection zoneFeatureCollection
+	if err := json.Unmarshal(body, &collection); err == nil && collection.Type == "FeatureCollection" {
+		for _, f := range collection.Features {
+			requests = append(requests, ZoneRequest{
+				Name:         f.Properties.Name,
+				Fee:          f.Properties.Fee,
+				DeliveryDays: f.Properties.DeliveryDays,
+				Enabled:      f.Properties.Enabled,
+				GeoJSON:      f.Geometry,
+			})
+		}
+	} else {
+		var req ZoneRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf("invalid request format")
+		}
+		requests = append(requests, req)
+	}
+
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no zones in request")
+	}
+
+	zones := make([]domain.DeliveryZone, 0, len(requests))
+	for _, req := range requests {
+		if req.Name == "" {
+			return nil, fmt.Errorf("zone name is required")
+		}
+		if req.Fee < 0 || req.DeliveryDays < 0 {
+			return nil, fmt.Errorf("zone %q: fee and delivery days must not be negative", req.Name)
+		}
+		if _, err := service.ParseZoneShape(req.GeoJSON); err != nil {
+			return nil, fmt.Errorf("zone %q: %v", req.Name, err)
+		}
+		zone := domain.DeliveryZone{
+			Name:         req.Name,
+			GeoJSON:      req.GeoJSON,
+			Fee:          req.Fee,
+			DeliveryDays: req.DeliveryDays,
+			Enabled:      true,
+		}
+		if req.Enabled != nil {
+			zone.Enabled = *req.Enabled
+		}
+		if zone.DeliveryDays == 0 {
+			zone.DeliveryDays = 1
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
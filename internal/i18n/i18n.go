@@ -0,0 +1,251 @@
+// Package i18n translates bot messages. Catalogs for Kazakh, Russian and English are
+// embedded JSON files mapping a message key to its text. A text may contain {name}
+// placeholders; a message that depends on a number is an object of plural forms
+// ("one", "few", "many", "other") chosen by the "count" argument.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// Supported languages
+const (
+	Kazakh  = "kk"
+	Russian = "ru"
+	English = "en"
+
+	// Default is used when the user's language is unknown or unsupported
+	Default = Kazakh
+)
+
+// Languages lists the supported languages in the order they are offered to users
+var Languages = []string{Kazakh, Russian, English}
+
+// names are the language names shown in the language picker, each in its own language
+var names = map[string]string{
+	Kazakh:  "🇰🇿 Қазақша",
+	Russian: "🇷🇺 Русский",
+	English: "🇬🇧 English",
+}
+
+// Args are the placeholder values of a message
+type Args map[string]interface{}
+
+// message is either a plain text or a set of plural forms
+type message struct {
+	text   string
+	plural map[string]string
+}
+
+func (m *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &m.plural); err != nil {
+		return fmt.Errorf("message must be a string or an object of plural forms")
+	}
+	if _, ok := m.plural["other"]; !ok {
+		return fmt.Errorf("plural message has no \"other\" form")
+	}
+	return nil
+}
+
+var (
+	loadOnce sync.Once
+	catalogs map[string]map[string]message
+	loadErr  error
+)
+
+// Load parses the embedded catalogs and checks that every language has the same keys.
+// It is called at startup so a broken catalog stops the bot instead of a reply.
+func Load() error {
+	loadOnce.Do(func() {
+		catalogs, loadErr = parseCatalogs()
+	})
+	return loadErr
+}
+
+func parseCatalogs() (map[string]map[string]message, error) {
+	result := make(map[string]map[string]message, len(Languages))
+	for _, lang := range Languages {
+		data, err := locales.ReadFile(path.Join("locales", lang+".json"))
+		if err != nil {
+			return nil, err
+		}
+		var catalog map[string]message
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("catalog %s: %w", lang, err)
+		}
+		result[lang] = catalog
+	}
+
+	for _, lang := range Languages[1:] {
+		if missing := missingKeys(result[Default], result[lang]); len(missing) > 0 {
+			return nil, fmt.Errorf("catalog %s is missing %s", lang, strings.Join(missing, ", "))
+		}
+		if extra := missingKeys(result[lang], result[Default]); len(extra) > 0 {
+			return nil, fmt.Errorf("catalog %s has keys unknown to %s: %s", lang, Default, strings.Join(extra, ", "))
+		}
+	}
+	return result, nil
+}
+
+func missingKeys(from, in map[string]message) []string {
+	var missing []string
+	for key := range from {
+		if _, ok := in[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// T returns the message key in the given language with placeholders filled in.
+// A key missing from the catalog is returned as is, so it shows up in the chat.
+func T(lang, key string, args ...Args) string {
+	if err := Load(); err != nil {
+		panic("i18n: " + err.Error())
+	}
+	if !Supported(lang) {
+		lang = Default
+	}
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		return key
+	}
+
+	var values Args
+	if len(args) > 0 {
+		values = args[0]
+	}
+	text := msg.text
+	if msg.plural != nil {
+		text = msg.plural[PluralForm(lang, count(values))]
+		if text == "" {
+			text = msg.plural["other"]
+		}
+	}
+	return fill(text, values)
+}
+
+// Raw returns the message key in the given language with its placeholders unfilled;
+// for a plural message it is the "other" form. ok is false for an unknown key.
+func Raw(lang, key string) (text string, ok bool) {
+	if err := Load(); err != nil {
+		panic("i18n: " + err.Error())
+	}
+	if !Supported(lang) {
+		lang = Default
+	}
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		return "", false
+	}
+	if msg.plural != nil {
+		return msg.plural["other"], true
+	}
+	return msg.text, true
+}
+
+// Matches reports whether text is the message key in any language.
+// It recognizes reply keyboard buttons pressed in another language.
+func Matches(key, text string) bool {
+	for _, lang := range Languages {
+		if T(lang, key) == text {
+			return true
+		}
+	}
+	return false
+}
+
+// All returns the message key in every supported language
+func All(key string) []string {
+	texts := make([]string, 0, len(Languages))
+	for _, lang := range Languages {
+		texts = append(texts, T(lang, key))
+	}
+	return texts
+}
+
+// Supported reports whether lang is one of Languages
+func Supported(lang string) bool {
+	_, ok := names[lang]
+	return ok
+}
+
+// Name returns the language name shown in the language picker
+func Name(lang string) string {
+	return names[lang]
+}
+
+// Detect maps a Telegram language_code such as "ru", "kk" or "en-US" to a supported
+// language. Telegram clients have no Kazakh interface, so an unknown code falls back
+// to Default rather than English.
+func Detect(languageCode string) string {
+	code := strings.ToLower(strings.SplitN(strings.ReplaceAll(languageCode, "_", "-"), "-", 2)[0])
+	switch code {
+	case "kk", "kz":
+		return Kazakh
+	case "ru", "uk", "be", "uz", "ky":
+		return Russian
+	case "en":
+		return English
+	default:
+		return Default
+	}
+}
+
+// PluralForm returns the CLDR plural category of n: Russian distinguishes one, few and
+// many; Kazakh and English only one and other.
+func PluralForm(lang string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	if lang == Russian {
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	}
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+func count(args Args) int {
+	switch v := args["count"].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// fill replaces {name} placeholders with their values; unknown placeholders stay as they are
+func fill(text string, args Args) string {
+	if len(args) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	pairs := make([]string, 0, len(args)*2)
+	for name, value := range args {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
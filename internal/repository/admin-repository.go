@@ -0,0 +1,75 @@
+// ── internal/repository/admin-repository.go ──────────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"meily/internal/domain"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                                  ADMINS METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// GetAdmins возвращает всех администраторов, сначала более привилегированные роли
+func (r *UserRepository) GetAdmins(ctx context.Context) ([]domain.Admin, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id_user, name, role, added_by, created_at
+		FROM admins
+		ORDER BY CASE role
+			WHEN 'owner' THEN 1 WHEN 'manager' THEN 2 WHEN 'operator' THEN 3
+			WHEN 'courier' THEN 4 ELSE 5 END, created_at;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var admins []domain.Admin
+	for rows.Next() {
+		var a domain.Admin
+		if err := rows.Scan(&a.UserID, &a.Name, &a.Role, &a.AddedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		admins = append(admins, a)
+	}
+	return admins, rows.Err()
+}
+
+// GetAdmin возвращает администратора по Telegram ID или nil
+func (r *UserRepository) GetAdmin(ctx context.Context, userID int64) (*domain.Admin, error) {
+	var a domain.Admin
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id_user, name, role, added_by, created_at FROM admins WHERE id_user = ?;
+	`, userID).Scan(&a.UserID, &a.Name, &a.Role, &a.AddedBy, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// SaveAdmin добавляет администратора или меняет его роль и имя
+func (r *UserRepository) SaveAdmin(ctx context.Context, a domain.Admin) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admins (id_user, name, role, added_by)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(id_user) DO UPDATE SET
+			name = CASE WHEN excluded.name != '' THEN excluded.name ELSE admins.name END,
+			role = excluded.role,
+			updated_at = CURRENT_TIMESTAMP;
+	`, a.UserID, a.Name, a.Role, a.AddedBy)
+	return err
+}
+
+// DeleteAdmin удаляет администратора; false — такого не было
+func (r *UserRepository) DeleteAdmin(ctx context.Context, userID int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM admins WHERE id_user = ?;`, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
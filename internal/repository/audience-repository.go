@@ -0,0 +1,149 @@
+// ── internal/repository/audience-repository.go ───────────────────────────────
+package repository
+
+import (
+	"context"
+	"fmt"
+	"meily/internal/domain"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                             BROADCAST AUDIENCE METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// paidUsersQ выбирает всех, кто когда-либо платил: билеты лото и заказы выдаются
+// только после оплаты, а таблица payments появилась позже них
+const paidUsersQ = `
+	SELECT id_user FROM payments
+	UNION SELECT id_user FROM loto
+	UNION SELECT id_user FROM client
+`
+
+// audienceWhere собирает условия отбора получателей рассылки по таблице just (j)
+func (r *UserRepository) audienceWhere(ctx context.Context, f domain.AudienceFilter) (string, []interface{}, error) {
+	var where []string
+	var args []interface{}
+
+	switch f.Segment {
+	case domain.AudienceAll:
+	case domain.AudienceBuyers:
+		where = append(where, "j.id_user IN ("+paidUsersQ+")")
+	case domain.AudienceTicketHolders:
+		where = append(where, "j.id_user IN (SELECT id_user FROM loto)")
+	case domain.AudienceNeverPaid:
+		where = append(where, "j.id_user NOT IN ("+paidUsersQ+")")
+	case domain.AudienceUndelivered:
+		where = append(where, "j.id_user IN (SELECT id_user FROM client WHERE COALESCE(status, 'new') != ?)")
+		args = append(args, domain.OrderStatusDelivered)
+	default:
+		return "", nil, fmt.Errorf("unknown audience segment: %s", f.Segment)
+	}
+
+	// Кампания — первый источник, как в отчёте first-touch; без источника — direct
+	if f.Source != "" {
+		where = append(where, "COALESCE(NULLIF(j.source, ''), 'direct') = ?")
+		args = append(args, f.Source)
+	}
+	// Город или регион по геолокации, как в выгрузке заказов — ещё и по адресу доставки
+	if f.City != "" {
+		userIDs, err := r.cityUserIDs(ctx, f.City, true)
+		if err != nil {
+			return "", nil, err
+		}
+		placeholders, userArgs := idArgs(userIDs)
+		where = append(where, "j.id_user IN ("+placeholders+")")
+		args = append(args, userArgs...)
+	}
+	// paid_at и created_at хранятся в UTC, как и datetime('now')
+	if f.PaidDays > 0 {
+		where = append(where, `j.id_user IN (
+			SELECT id_user FROM payments WHERE paid_at >= datetime('now', ?)
+			UNION SELECT id_user FROM loto WHERE created_at >= datetime('now', ?)
+		)`)
+		since := fmt.Sprintf("-%d days", f.PaidDays)
+		args = append(args, since, since)
+	}
+
+	if len(where) == 0 {
+		return "", args, nil
+	}
+	return " WHERE " + strings.Join(where, " AND "), args, nil
+}
+
+// GetAudienceUserIDs возвращает пользователей, подходящих под фильтр рассылки
+func (r *UserRepository) GetAudienceUserIDs(ctx context.Context, f domain.AudienceFilter) ([]int64, error) {
+	where, args, err := r.audienceWhere(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT j.id_user FROM just j`+where+` ORDER BY j.created_at DESC;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// CountAudience возвращает число получателей рассылки по фильтру
+func (r *UserRepository) CountAudience(ctx context.Context, f domain.AudienceFilter) (int, error) {
+	where, args, err := r.audienceWhere(ctx, f)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM just j`+where+`;`, args...).Scan(&n)
+	return n, err
+}
+
+// GetAudienceSources возвращает до limit самых частых источников пользователей для
+// кнопок фильтра кампании
+func (r *UserRepository) GetAudienceSources(ctx context.Context, limit int) ([]string, error) {
+	return r.queryAudienceValues(ctx, `
+		SELECT COALESCE(NULLIF(source, ''), 'direct') AS src
+		FROM just
+		GROUP BY src
+		ORDER BY COUNT(*) DESC, src
+		LIMIT ?;
+	`, limit)
+}
+
+// GetAudienceCities возвращает до limit самых частых городов пользователей для кнопок
+// фильтра города
+func (r *UserRepository) GetAudienceCities(ctx context.Context, limit int) ([]string, error) {
+	return r.queryAudienceValues(ctx, `
+		SELECT city
+		FROM geo
+		WHERE COALESCE(city, '') != ''
+		GROUP BY city
+		ORDER BY COUNT(*) DESC, city
+		LIMIT ?;
+	`, limit)
+}
+
+func (r *UserRepository) queryAudienceValues(ctx context.Context, q string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,214 @@
+// ── internal/repository/broadcast-repository.go ──────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"meily/internal/domain"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                                BROADCAST METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+const broadcastJobSelect = `
+	SELECT j.id, j.admin_id, j.audience, j.kind, COALESCE(j.file_id, ''), COALESCE(j.text, ''),
+		j.status, COALESCE(j.status_chat_id, 0), COALESCE(j.status_message_id, 0),
+		COUNT(r.id_user),
+		COALESCE(SUM(r.status = 'pending'), 0),
+		COALESCE(SUM(r.status = 'sent'), 0),
+		COALESCE(SUM(r.status = 'failed'), 0),
+		COALESCE(SUM(r.status = 'blocked'), 0),
+		j.created_at, j.finished_at
+	FROM broadcast_jobs j
+	LEFT JOIN broadcast_recipients r ON r.job_id = j.id
+`
+
+// CreateBroadcastJob сохраняет рассылку и её получателей в статусе pending
+func (r *UserRepository) CreateBroadcastJob(ctx context.Context, job domain.BroadcastJob, userIDs []int64) (int64, error) {
+	audience, err := json.Marshal(job.Audience)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO broadcast_jobs (admin_id, audience, kind, file_id, text, status)
+		VALUES (?, ?, ?, ?, ?, ?);
+	`, job.AdminID, string(audience), job.Kind, nullIfEmpty(job.FileID), nullIfEmpty(job.Text), domain.BroadcastRunning)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO broadcast_recipients (job_id, id_user) VALUES (?, ?);`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for _, userID := range userIDs {
+		if _, err := stmt.ExecContext(ctx, id, userID); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// SetBroadcastStatusMessage запоминает сообщение администратора, в котором показывается прогресс
+func (r *UserRepository) SetBroadcastStatusMessage(ctx context.Context, id, chatID int64, messageID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE broadcast_jobs SET status_chat_id = ?, status_message_id = ?, updated_at = datetime('now') WHERE id = ?;
+	`, chatID, messageID, id)
+	return err
+}
+
+// GetBroadcastJob возвращает рассылку со счётчиками получателей или nil
+func (r *UserRepository) GetBroadcastJob(ctx context.Context, id int64) (*domain.BroadcastJob, error) {
+	jobs, err := r.queryBroadcastJobs(ctx, broadcastJobSelect+`WHERE j.id = ? GROUP BY j.id;`, id)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// GetUnfinishedBroadcastJobs возвращает идущие и приостановленные рассылки, старые первыми
+func (r *UserRepository) GetUnfinishedBroadcastJobs(ctx context.Context) ([]domain.BroadcastJob, error) {
+	return r.queryBroadcastJobs(ctx, broadcastJobSelect+`
+		WHERE j.status IN (?, ?)
+		GROUP BY j.id
+		ORDER BY j.id;
+	`, domain.BroadcastRunning, domain.BroadcastPaused)
+}
+
+// GetPendingBroadcastRecipients возвращает до limit получателей, которым рассылка ещё не отправлена
+func (r *UserRepository) GetPendingBroadcastRecipients(ctx context.Context, jobID int64, limit int) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id_user FROM broadcast_recipients
+		WHERE job_id = ? AND status = ?
+		ORDER BY id_user
+		LIMIT ?;
+	`, jobID, domain.BroadcastRecipientPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SaveBroadcastResults сохраняет итог отправки получателям одной транзакцией.
+// Получатель с повторяемой ошибкой остаётся в очереди, пока не наберёт maxAttempts попыток.
+func (r *UserRepository) SaveBroadcastResults(ctx context.Context, jobID int64, results []domain.BroadcastResult, maxAttempts int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE broadcast_recipients
+		SET status = ?, error_code = ?, error = ?, sent_at = datetime('now')
+		WHERE job_id = ? AND id_user = ?;
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	retryStmt, err := tx.PrepareContext(ctx, `
+		UPDATE broadcast_recipients
+		SET attempts = attempts + 1, error = ?, sent_at = datetime('now'),
+			status = CASE WHEN attempts + 1 >= ? THEN ? ELSE status END
+		WHERE job_id = ? AND id_user = ?;
+	`)
+	if err != nil {
+		return err
+	}
+	defer retryStmt.Close()
+
+	for _, res := range results {
+		if res.Retry {
+			if _, err := retryStmt.ExecContext(ctx, nullIfEmpty(res.Error), maxAttempts, res.Status, jobID, res.UserID); err != nil {
+				return err
+			}
+			continue
+		}
+		var code sql.NullInt64
+		if res.Status != domain.BroadcastRecipientSent {
+			code = sql.NullInt64{Int64: int64(res.ErrorCode), Valid: true}
+		}
+		if _, err := stmt.ExecContext(ctx, res.Status, code, nullIfEmpty(res.Error), jobID, res.UserID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetBroadcastJobStatus переводит рассылку в status, если она сейчас в одном из статусов from;
+// false — рассылка уже в другом статусе
+func (r *UserRepository) SetBroadcastJobStatus(ctx context.Context, id int64, status string, from ...string) (bool, error) {
+	finished := status == domain.BroadcastDone || status == domain.BroadcastCancelled
+	args := []interface{}{status, finished, id}
+	for _, s := range from {
+		args = append(args, s)
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE broadcast_jobs
+		SET status = ?,
+			finished_at = CASE WHEN ? THEN datetime('now') ELSE finished_at END,
+			updated_at = datetime('now')
+		WHERE id = ? AND status IN (?`+strings.Repeat(", ?", len(from)-1)+`);
+	`, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *UserRepository) queryBroadcastJobs(ctx context.Context, q string, args ...interface{}) ([]domain.BroadcastJob, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []domain.BroadcastJob
+	for rows.Next() {
+		var j domain.BroadcastJob
+		var audience string
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&j.ID, &j.AdminID, &audience, &j.Kind, &j.FileID, &j.Text,
+			&j.Status, &j.StatusChatID, &j.StatusMessageID,
+			&j.Total, &j.Pending, &j.Sent, &j.Failed, &j.Blocked,
+			&j.CreatedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		// Аудитория хранится фильтром в JSON; в старых рассылках — только название сегмента
+		if err := json.Unmarshal([]byte(audience), &j.Audience); err != nil {
+			j.Audience = domain.AudienceFilter{Segment: audience}
+		}
+		if finishedAt.Valid {
+			j.FinishedAt = &finishedAt.Time
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,495 @@
+// ── internal/repository/courier-repository.go ────────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"meily/internal/domain"
+	"meily/traits/helper"
+	"strings"
+	"time"
+)
+
+// orderSelect — общий SELECT заказа (строка client) вместе с геолокацией
+const orderSelect = `
+	SELECT
+		c.id, c.id_user,
+		COALESCE(c.fio, '') as fio,
+		COALESCE(c.contact, '') as contact,
+		COALESCE(c.address, '') as address,
+		COALESCE(c.status, 'new') as status,
+		COALESCE(c.tracking_number, '') as tracking_number,
+		COALESCE(c.carrier, '') as carrier,
+		c.courier_id, c.assigned_at, c.status_updated_at,
+		g.latitude, g.longitude,
+		COALESCE(g.city, '') as city,
+		COALESCE(c.geo_confidence, '') as geo_confidence
+	FROM client c
+	LEFT JOIN geo g ON c.id_user = g.id_user
+`
+
+// assignableStatuses — заказы, которые можно (пере)назначить курьеру
+var assignableStatuses = []string{domain.OrderStatusNew, domain.OrderStatusPacked, domain.OrderStatusFailed}
+
+// statusArgs возвращает плейсхолдеры и аргументы для условия status IN (...)
+func statusArgs(statuses []string) (string, []interface{}) {
+	args := make([]interface{}, len(statuses))
+	for i, s := range statuses {
+		args[i] = s
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(statuses)), ","), args
+}
+
+// idArgs возвращает плейсхолдеры и аргументы для условия id IN (...)
+func idArgs(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","), args
+}
+
+// cityUserIDs возвращает пользователей, у которых город геолокации (а с withRegion — и область)
+// совпадает с city или city встречается в адресе доставки. Регистр сравнивается в Go:
+// LOWER и LIKE в SQLite понимают только латиницу, и «Алматы» не совпало бы с «алматы».
+func (r *UserRepository) cityUserIDs(ctx context.Context, city string, withRegion bool) ([]int64, error) {
+	const q = `
+		SELECT u.id_user, COALESCE(g.city, ''), COALESCE(g.region, ''), COALESCE(c.address, '')
+		FROM (SELECT id_user FROM geo UNION SELECT id_user FROM client) u
+		LEFT JOIN geo g ON g.id_user = u.id_user
+		LEFT JOIN client c ON c.id_user = u.id_user;
+	`
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	city = strings.ToLower(strings.TrimSpace(city))
+	var ids []int64
+	for rows.Next() {
+		var userID int64
+		var geoCity, region, address string
+		if err := rows.Scan(&userID, &geoCity, &region, &address); err != nil {
+			return nil, err
+		}
+		if strings.ToLower(geoCity) == city ||
+			(withRegion && strings.ToLower(region) == city) ||
+			strings.Contains(strings.ToLower(address), city) {
+			ids = append(ids, userID)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            COURIERS METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// UpsertCourier добавляет курьера или повторно активирует существующего
+func (r *UserRepository) UpsertCourier(ctx context.Context, c domain.Courier) (int64, error) {
+	const q = `
+		INSERT INTO couriers (id_user, name, phone, city, active, updated_at)
+		VALUES (?, ?, ?, ?, true, datetime('now'))
+		ON CONFLICT(id_user) DO UPDATE SET
+			name = excluded.name,
+			phone = COALESCE(excluded.phone, couriers.phone),
+			city = excluded.city,
+			active = true,
+			updated_at = datetime('now');
+	`
+	if _, err := r.db.ExecContext(ctx, q, c.UserID, c.Name, nullIfEmpty(helper.NormalizePhone(c.Phone)), c.City); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM couriers WHERE id_user = ?;`, c.UserID).Scan(&id)
+	return id, err
+}
+
+// DeactivateCourier отключает курьера и возвращает его незавершённые заказы в очередь
+func (r *UserRepository) DeactivateCourier(ctx context.Context, courierID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE couriers SET active = false, updated_at = datetime('now') WHERE id = ?;`, courierID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	const releaseQ = `
+		UPDATE client
+		SET courier_id = NULL, assigned_at = NULL, status = ?, status_updated_at = datetime('now'), updated_at = datetime('now')
+		WHERE courier_id = ? AND status IN (?, ?);
+	`
+	if _, err := tx.ExecContext(ctx, releaseQ, domain.OrderStatusNew, courierID, domain.OrderStatusAssigned, domain.OrderStatusPickedUp); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetActiveCouriers возвращает всех активных курьеров
+func (r *UserRepository) GetActiveCouriers(ctx context.Context) ([]domain.Courier, error) {
+	const q = `
+		SELECT id, id_user, name, COALESCE(phone, ''), COALESCE(city, ''), active, created_at
+		FROM couriers
+		WHERE active = true
+		ORDER BY city, name;
+	`
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var couriers []domain.Courier
+	for rows.Next() {
+		var c domain.Courier
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Phone, &c.City, &c.Active, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		couriers = append(couriers, c)
+	}
+	return couriers, rows.Err()
+}
+
+// GetCourierByID возвращает курьера по id
+func (r *UserRepository) GetCourierByID(ctx context.Context, courierID int64) (*domain.Courier, error) {
+	const q = `
+		SELECT id, id_user, name, COALESCE(phone, ''), COALESCE(city, ''), active, created_at
+		FROM couriers
+		WHERE id = ?;
+	`
+	var c domain.Courier
+	err := r.db.QueryRowContext(ctx, q, courierID).Scan(&c.ID, &c.UserID, &c.Name, &c.Phone, &c.City, &c.Active, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetActiveCourierByUserID возвращает активного курьера по Telegram ID; nil, если это не курьер
+func (r *UserRepository) GetActiveCourierByUserID(ctx context.Context, userID int64) (*domain.Courier, error) {
+	const q = `
+		SELECT id, id_user, name, COALESCE(phone, ''), COALESCE(city, ''), active, created_at
+		FROM couriers
+		WHERE id_user = ? AND active = true;
+	`
+	var c domain.Courier
+	err := r.db.QueryRowContext(ctx, q, userID).Scan(&c.ID, &c.UserID, &c.Name, &c.Phone, &c.City, &c.Active, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            ORDER ASSIGNMENT METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// GetCourierActiveOrders возвращает незавершённые заказы курьера
+func (r *UserRepository) GetCourierActiveOrders(ctx context.Context, courierID int64) ([]domain.CourierOrder, error) {
+	q := orderSelect + `
+		WHERE c.courier_id = ? AND c.status IN (?, ?)
+		ORDER BY c.assigned_at ASC;
+	`
+	return r.queryOrders(ctx, q, courierID, domain.OrderStatusAssigned, domain.OrderStatusPickedUp)
+}
+
+// GetCourierOrdersSince возвращает незавершённые заказы курьера и заказы, закрытые им начиная с since
+func (r *UserRepository) GetCourierOrdersSince(ctx context.Context, courierID int64, since time.Time) ([]domain.CourierOrder, error) {
+	q := orderSelect + `
+		WHERE c.courier_id = ?
+		  AND (c.status IN (?, ?) OR (c.status IN (?, ?) AND c.status_updated_at >= ?))
+		ORDER BY c.assigned_at ASC;
+	`
+	// status_updated_at пишется через datetime('now') — строка в UTC
+	return r.queryOrders(ctx, q, courierID,
+		domain.OrderStatusAssigned, domain.OrderStatusPickedUp,
+		domain.OrderStatusDelivered, domain.OrderStatusFailed,
+		since.UTC().Format("2006-01-02 15:04:05"))
+}
+
+// GetUnassignedOrders возвращает заказы с адресом, которые ещё не у курьера
+func (r *UserRepository) GetUnassignedOrders(ctx context.Context) ([]domain.CourierOrder, error) {
+	placeholders, args := statusArgs(assignableStatuses)
+	q := orderSelect + fmt.Sprintf(`
+		WHERE c.status IN (%s) AND c.address IS NOT NULL AND c.address != ''
+		ORDER BY c.dataPay ASC;
+	`, placeholders)
+	return r.queryOrders(ctx, q, args...)
+}
+
+// GetOrdersByUserID возвращает заказы пользователя
+func (r *UserRepository) GetOrdersByUserID(ctx context.Context, userID int64) ([]domain.CourierOrder, error) {
+	q := orderSelect + `
+		WHERE c.id_user = ?
+		ORDER BY c.id DESC;
+	`
+	return r.queryOrders(ctx, q, userID)
+}
+
+// GetOrderByID возвращает заказ по id строки client
+func (r *UserRepository) GetOrderByID(ctx context.Context, orderID int64) (*domain.CourierOrder, error) {
+	q := orderSelect + `WHERE c.id = ?;`
+	orders, err := r.queryOrders(ctx, q, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &orders[0], nil
+}
+
+// AssignOrders назначает курьеру указанные заказы; возвращает число назначенных.
+// Счётчик неверных кодов подтверждения у назначенных заказов обнуляется: новая попытка
+// доставки начинается с чистого листа.
+func (r *UserRepository) AssignOrders(ctx context.Context, courierID int64, orderIDs []int64) (int, error) {
+	if len(orderIDs) == 0 {
+		return 0, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	placeholders, ids := idArgs(orderIDs)
+	statusPlaceholders, statuses := statusArgs(assignableStatuses)
+	q := fmt.Sprintf(`
+		UPDATE client
+		SET courier_id = ?, status = ?, assigned_at = ?, status_updated_at = datetime('now'), updated_at = datetime('now')
+		WHERE id IN (%s) AND status IN (%s) AND address IS NOT NULL AND address != ''
+		RETURNING id;
+	`, placeholders, statusPlaceholders)
+
+	args := []interface{}{courierID, domain.OrderStatusAssigned, time.Now()}
+	args = append(args, ids...)
+	args = append(args, statuses...)
+
+	rows, err := tx.QueryContext(ctx, q, args...)
+	if err != nil {
+		return 0, err
+	}
+	var assigned []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		assigned = append(assigned, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(assigned) > 0 {
+		placeholders, ids := idArgs(assigned)
+		q := `UPDATE delivery_proofs SET failed_attempts = 0 WHERE order_id IN (` + placeholders + `);`
+		if _, err := tx.ExecContext(ctx, q, ids...); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(assigned), tx.Commit()
+}
+
+// AssignOrdersByCity назначает курьеру все свободные заказы города.
+// Город сравнивается с geo.city, а если он не заполнен — ищется в тексте адреса.
+func (r *UserRepository) AssignOrdersByCity(ctx context.Context, courierID int64, city string) (int, error) {
+	userIDs, err := r.cityUserIDs(ctx, city, false)
+	if err != nil {
+		return 0, err
+	}
+	placeholders, args := statusArgs(assignableStatuses)
+	userPlaceholders, userArgs := idArgs(userIDs)
+	q := fmt.Sprintf(`
+		SELECT c.id
+		FROM client c
+		WHERE c.status IN (%s) AND c.address IS NOT NULL AND c.address != ''
+		  AND c.id_user IN (%s);
+	`, placeholders, userPlaceholders)
+	ids, err := r.queryIDs(ctx, q, append(args, userArgs...)...)
+	if err != nil {
+		return 0, err
+	}
+	return r.AssignOrders(ctx, courierID, ids)
+}
+
+// AssignOrdersInBounds назначает курьеру свободные заказы внутри прямоугольной области карты
+func (r *UserRepository) AssignOrdersInBounds(ctx context.Context, courierID int64, south, west, north, east float64) (int, error) {
+	placeholders, args := statusArgs(assignableStatuses)
+	q := fmt.Sprintf(`
+		SELECT c.id
+		FROM client c
+		INNER JOIN geo g ON c.id_user = g.id_user
+		WHERE c.status IN (%s) AND c.address IS NOT NULL AND c.address != ''
+		  AND g.latitude BETWEEN ? AND ?
+		  AND g.longitude BETWEEN ? AND ?;
+	`, placeholders)
+	ids, err := r.queryIDs(ctx, q, append(args, south, north, west, east)...)
+	if err != nil {
+		return 0, err
+	}
+	return r.AssignOrders(ctx, courierID, ids)
+}
+
+// SetOrderTracking сохраняет трек-номер отправления
+func (r *UserRepository) SetOrderTracking(ctx context.Context, orderID int64, trackingNumber string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE client SET tracking_number = ?, updated_at = datetime('now') WHERE id = ?;`, nullIfEmpty(trackingNumber), orderID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetOrderShipments сохраняет трек-номера и перевозчиков заказов и переводит их в shipped
+// одной транзакцией: при ошибке не меняется ни один заказ
+func (r *UserRepository) SetOrderShipments(ctx context.Context, orders []domain.CourierOrder) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const q = `
+		UPDATE client
+		SET tracking_number = ?, carrier = ?, status = ?,
+			status_updated_at = datetime('now'), updated_at = datetime('now')
+		WHERE id = ?;
+	`
+	for _, o := range orders {
+		res, err := tx.ExecContext(ctx, q, o.TrackingNumber, nullIfEmpty(o.Carrier), domain.OrderStatusShipped, o.OrderID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("order %d: %w", o.OrderID, sql.ErrNoRows)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetOrderIDsByContact возвращает id заказов по контактному телефону, приведённому через normalize
+func (r *UserRepository) GetOrderIDsByContact(ctx context.Context, normalize func(string) string) (map[string][]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, COALESCE(contact, '') FROM client;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byPhone := make(map[string][]int64)
+	for rows.Next() {
+		var id int64
+		var contact string
+		if err := rows.Scan(&id, &contact); err != nil {
+			return nil, err
+		}
+		if phone := normalize(contact); phone != "" {
+			byPhone[phone] = append(byPhone[phone], id)
+		}
+	}
+	return byPhone, rows.Err()
+}
+
+// UpdateOrderStatus меняет статус заказа. Доставленный заказ также отмечается в checks,
+// которые админ раньше проставлял вручную.
+func (r *UserRepository) UpdateOrderStatus(ctx context.Context, orderID int64, status string) error {
+	const q = `
+		UPDATE client
+		SET status = ?,
+			checks = CASE WHEN ? THEN true ELSE checks END,
+			status_updated_at = datetime('now'),
+			updated_at = datetime('now')
+		WHERE id = ?;
+	`
+	res, err := r.db.ExecContext(ctx, q, status, status == domain.OrderStatusDelivered, orderID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *UserRepository) queryOrders(ctx context.Context, q string, args ...interface{}) ([]domain.CourierOrder, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []domain.CourierOrder
+	for rows.Next() {
+		var o domain.CourierOrder
+		var courierID sql.NullInt64
+		var assignedAt, statusUpdatedAt sql.NullTime
+		var lat, lon sql.NullFloat64
+		if err := rows.Scan(
+			&o.OrderID, &o.UserID, &o.Fio, &o.Contact, &o.Address, &o.Status, &o.TrackingNumber, &o.Carrier,
+			&courierID, &assignedAt, &statusUpdatedAt, &lat, &lon, &o.City, &o.GeoConfidence,
+		); err != nil {
+			return nil, err
+		}
+		if statusUpdatedAt.Valid {
+			o.StatusUpdatedAt = &statusUpdatedAt.Time
+		}
+		if courierID.Valid {
+			o.CourierID = &courierID.Int64
+		}
+		if assignedAt.Valid {
+			o.AssignedAt = &assignedAt.Time
+		}
+		if lat.Valid && lon.Valid {
+			o.Latitude = &lat.Float64
+			o.Longitude = &lon.Float64
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (r *UserRepository) queryIDs(ctx context.Context, q string, args ...interface{}) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
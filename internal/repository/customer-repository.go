@@ -0,0 +1,290 @@
+// ── internal/repository/customer-repository.go ───────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"meily/internal/domain"
+	"meily/traits/helper"
+	"strconv"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            CUSTOMERS METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// linkCustomer связывает Telegram-аккаунт с покупателем по телефону в формате E.164.
+// Покупатель создаётся при первом появлении телефона. primary — телефон из контакта
+// Telegram: он переносит аккаунт к другому покупателю, если телефон сменился. Телефон
+// из формы Mini App только заполняет пустую связь, потому что люди вводят туда номер
+// получателя.
+func (r *UserRepository) linkCustomer(ctx context.Context, userID int64, phone string, primary bool) error {
+	if !helper.IsE164(phone) {
+		return nil
+	}
+	// Не INSERT OR IGNORE: он тратит значение AUTOINCREMENT при каждом вызове
+	const createQ = `INSERT INTO customers (phone) SELECT ? WHERE NOT EXISTS (SELECT 1 FROM customers WHERE phone = ?);`
+	if _, err := r.db.ExecContext(ctx, createQ, phone, phone); err != nil {
+		return fmt.Errorf("create customer: %w", err)
+	}
+
+	q := `
+		INSERT INTO customer_accounts (id_user, customer_id, phone, linked_at)
+		SELECT ?, id, phone, datetime('now') FROM customers WHERE phone = ?
+		ON CONFLICT(id_user) DO NOTHING;
+	`
+	if primary {
+		q = `
+		INSERT INTO customer_accounts (id_user, customer_id, phone, linked_at)
+		SELECT ?, id, phone, datetime('now') FROM customers WHERE phone = ?
+		ON CONFLICT(id_user) DO UPDATE SET
+			customer_id = excluded.customer_id,
+			phone = excluded.phone,
+			linked_at = excluded.linked_at
+		WHERE customer_accounts.phone <> excluded.phone;
+		`
+	}
+	if _, err := r.db.ExecContext(ctx, q, userID, phone); err != nil {
+		return fmt.Errorf("link customer account: %w", err)
+	}
+	return nil
+}
+
+// BackfillPhones приводит client.contact и loto.contact к формату E.164 и связывает
+// аккаунты с покупателями. Возвращает число изменённых телефонов.
+func (r *UserRepository) BackfillPhones(ctx context.Context) (int, error) {
+	updated := 0
+	for _, table := range []string{"client", "loto"} {
+		n, err := r.normalizeContacts(ctx, table)
+		if err != nil {
+			return updated, fmt.Errorf("normalize %s contacts: %w", table, err)
+		}
+		updated += n
+	}
+
+	// Сначала телефоны из контактов Telegram, затем из формы для аккаунтов без связи
+	for _, link := range []struct {
+		query   string
+		primary bool
+	}{
+		{`SELECT id_user, contact FROM client WHERE contact IS NOT NULL AND contact <> '';`, true},
+		{`SELECT id_user, contact FROM loto WHERE contact IS NOT NULL AND contact <> '' ORDER BY id;`, false},
+	} {
+		accounts, err := r.scanAccountPhones(ctx, link.query)
+		if err != nil {
+			return updated, err
+		}
+		for _, a := range accounts {
+			if err := r.linkCustomer(ctx, a.userID, a.phone, link.primary); err != nil {
+				return updated, err
+			}
+		}
+	}
+	return updated, nil
+}
+
+type accountPhone struct {
+	userID int64
+	phone  string
+}
+
+func (r *UserRepository) scanAccountPhones(ctx context.Context, q string) ([]accountPhone, error) {
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []accountPhone
+	for rows.Next() {
+		var a accountPhone
+		if err := rows.Scan(&a.userID, &a.phone); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// normalizeContacts переписывает телефоны одной таблицы (client или loto) в формат E.164
+func (r *UserRepository) normalizeContacts(ctx context.Context, table string) (int, error) {
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`SELECT id, contact FROM %s WHERE contact IS NOT NULL AND contact <> '';`, table))
+	if err != nil {
+		return 0, err
+	}
+	changed := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var contact string
+		if err := rows.Scan(&id, &contact); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if phone := helper.NormalizePhone(contact); phone != contact {
+			changed[id] = phone
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`UPDATE %s SET contact = ? WHERE id = ?;`, table))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for id, phone := range changed {
+		if _, err := stmt.ExecContext(ctx, phone, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(changed), tx.Commit()
+}
+
+// GetCustomerIDByPhone возвращает id покупателя по телефону в любом формате
+func (r *UserRepository) GetCustomerIDByPhone(ctx context.Context, phone string) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM customers WHERE phone = ?;`, helper.NormalizePhone(phone)).Scan(&id)
+	return id, err
+}
+
+// GetCustomerIDByUserID возвращает id покупателя, с которым связан Telegram-аккаунт
+func (r *UserRepository) GetCustomerIDByUserID(ctx context.Context, userID int64) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT customer_id FROM customer_accounts WHERE id_user = ?;`, userID).Scan(&id)
+	return id, err
+}
+
+// GetCustomer возвращает покупателя со всеми его аккаунтами, заказами и билетами.
+// В историю попадают и записи других аккаунтов, где указан тот же телефон.
+func (r *UserRepository) GetCustomer(ctx context.Context, id int64) (*domain.Customer, error) {
+	c := &domain.Customer{ID: id}
+	err := r.db.QueryRowContext(ctx, `SELECT phone, created_at FROM customers WHERE id = ?;`, id).Scan(&c.Phone, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	const accountsQ = `
+		SELECT ca.id_user, COALESCE(j.userName, COALESCE(cl.userName, '')), ca.linked_at
+		FROM customer_accounts ca
+		LEFT JOIN just j ON j.id_user = ca.id_user
+		LEFT JOIN client cl ON cl.id_user = ca.id_user
+		WHERE ca.customer_id = ?
+		ORDER BY ca.linked_at;
+	`
+	rows, err := r.db.QueryContext(ctx, accountsQ, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var a domain.CustomerAccount
+		if err := rows.Scan(&a.UserID, &a.UserName, &a.LinkedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		c.Accounts = append(c.Accounts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	const ordersQ = `
+		SELECT id, id_user, COALESCE(fio, ''), COALESCE(contact, ''), COALESCE(address, ''),
+			COALESCE(status, 'new'), COALESCE(tracking_number, ''), COALESCE(dataPay, ''),
+			COALESCE(phone_verified_by, '')
+		FROM client
+		WHERE contact = ? OR id_user IN (SELECT id_user FROM customer_accounts WHERE customer_id = ?)
+		ORDER BY dataPay;
+	`
+	rows, err = r.db.QueryContext(ctx, ordersQ, c.Phone, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var o domain.CustomerOrder
+		if err := rows.Scan(&o.OrderID, &o.UserID, &o.Fio, &o.Contact, &o.Address,
+			&o.Status, &o.TrackingNumber, &o.DatePay, &o.PhoneVerifiedBy); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		c.Orders = append(c.Orders, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	const ticketsQ = `
+		SELECT id_user, id_loto, COALESCE(who_paid, ''), COALESCE(dataPay, '')
+		FROM loto
+		WHERE contact = ? OR id_user IN (SELECT id_user FROM customer_accounts WHERE customer_id = ?)
+		ORDER BY dataPay, id_loto;
+	`
+	rows, err = r.db.QueryContext(ctx, ticketsQ, c.Phone, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t domain.CustomerTicket
+		if err := rows.Scan(&t.UserID, &t.LotoID, &t.WhoPaid, &t.DatePay); err != nil {
+			return nil, err
+		}
+		c.Tickets = append(c.Tickets, t)
+	}
+	return c, rows.Err()
+}
+
+// GetSharedCustomers возвращает покупателей, у которых несколько Telegram-аккаунтов,
+// — по ним чаще всего отправляют заказ дважды
+func (r *UserRepository) GetSharedCustomers(ctx context.Context) ([]domain.CustomerSummary, error) {
+	const q = `
+		SELECT cu.id, cu.phone,
+			GROUP_CONCAT(ca.id_user),
+			(SELECT COUNT(*) FROM client cl WHERE cl.id_user IN
+				(SELECT id_user FROM customer_accounts WHERE customer_id = cu.id)),
+			(SELECT COUNT(*) FROM loto l WHERE l.id_user IN
+				(SELECT id_user FROM customer_accounts WHERE customer_id = cu.id)),
+			(SELECT COUNT(*) FROM client cl WHERE COALESCE(cl.status, 'new') NOT IN ('new', 'packed') AND cl.id_user IN
+				(SELECT id_user FROM customer_accounts WHERE customer_id = cu.id)),
+			(SELECT COALESCE(MAX(cl.dataPay), '') FROM client cl WHERE cl.id_user IN
+				(SELECT id_user FROM customer_accounts WHERE customer_id = cu.id))
+		FROM customers cu
+		JOIN customer_accounts ca ON ca.customer_id = cu.id
+		GROUP BY cu.id
+		HAVING COUNT(ca.id_user) > 1
+		ORDER BY COUNT(ca.id_user) DESC, cu.id;
+	`
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []domain.CustomerSummary
+	for rows.Next() {
+		var s domain.CustomerSummary
+		var userIDs sql.NullString
+		if err := rows.Scan(&s.ID, &s.Phone, &userIDs, &s.Orders, &s.Tickets, &s.Dispatched, &s.LastPaid); err != nil {
+			return nil, err
+		}
+		for _, id := range strings.Split(userIDs.String, ",") {
+			if v, err := strconv.ParseInt(id, 10, 64); err == nil {
+				s.UserIDs = append(s.UserIDs, v)
+			}
+		}
+		customers = append(customers, s)
+	}
+	return customers, rows.Err()
+}
@@ -0,0 +1,95 @@
+// ── internal/repository/export-repository.go ─────────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"meily/internal/domain"
+	"strings"
+	"time"
+)
+
+// GetOrdersForExport возвращает заказы для выгрузки логистическим партнёрам.
+// Количество наборов хранится в заказе; у старых заказов без него берётся последняя
+// оплата пользователя, а до таблицы payments — его билеты лото.
+func (r *UserRepository) GetOrdersForExport(ctx context.Context, f domain.OrderExportFilter) ([]domain.ExportOrder, error) {
+	var where []string
+	args := []interface{}{domain.TicketsPerSet, domain.TicketsPerSet}
+
+	if f.Status != "" {
+		where = append(where, "COALESCE(c.status, 'new') = ?")
+		args = append(args, f.Status)
+	}
+	// dataPay хранится строкой "2006-01-02 15:04:05", поэтому даты сравниваются как строки
+	if f.From != "" {
+		where = append(where, "c.dataPay >= ?")
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		to, err := time.Parse("2006-01-02", f.To)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, "c.dataPay < ?")
+		args = append(args, to.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+	if f.City != "" {
+		userIDs, err := r.cityUserIDs(ctx, f.City, false)
+		if err != nil {
+			return nil, err
+		}
+		placeholders, userArgs := idArgs(userIDs)
+		where = append(where, "c.id_user IN ("+placeholders+")")
+		args = append(args, userArgs...)
+	}
+
+	q := `
+		SELECT
+			c.id, c.id_user,
+			COALESCE(c.fio, '') as fio,
+			COALESCE(c.contact, '') as contact,
+			COALESCE(c.address, '') as address,
+			COALESCE(g.city, '') as city,
+			g.latitude, g.longitude,
+			CASE WHEN c.sets > 0 THEN c.sets ELSE COALESCE(
+				(SELECT p.sets FROM payments p WHERE p.id_user = c.id_user ORDER BY p.id DESC LIMIT 1),
+				((SELECT COUNT(*) FROM loto l WHERE l.id_user = c.id_user) + ? - 1) / ?
+			) END as items,
+			COALESCE(c.status, 'new') as status,
+			COALESCE(c.tracking_number, '') as tracking_number,
+			c.dataPay,
+			COALESCE(ca.customer_id, 0) as customer_id
+		FROM client c
+		LEFT JOIN geo g ON c.id_user = g.id_user
+		LEFT JOIN customer_accounts ca ON c.id_user = ca.id_user
+	`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY c.dataPay ASC;"
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []domain.ExportOrder
+	for rows.Next() {
+		var o domain.ExportOrder
+		var lat, lon sql.NullFloat64
+		if err := rows.Scan(
+			&o.OrderID, &o.UserID, &o.Fio, &o.Phone, &o.Address, &o.City,
+			&lat, &lon, &o.Items, &o.Status, &o.TrackingNumber, &o.DatePay,
+			&o.CustomerID,
+		); err != nil {
+			return nil, err
+		}
+		if lat.Valid && lon.Valid {
+			o.Latitude = &lat.Float64
+			o.Longitude = &lon.Float64
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
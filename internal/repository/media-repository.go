@@ -0,0 +1,84 @@
+// ── internal/repository/media-repository.go ──────────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"meily/internal/domain"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                              MEDIA LIBRARY METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// activeMediaQ выбирает последнюю неудалённую версию каждого файла
+const activeMediaQ = `
+	SELECT m.id, m.name, m.kind, m.file_id, m.version, m.created_by, m.created_at
+	FROM media_assets m
+	WHERE m.deleted_at IS NULL AND m.version = (
+		SELECT MAX(v.version) FROM media_assets v
+		WHERE v.name = m.name AND v.deleted_at IS NULL
+	)
+`
+
+// GetMediaAssets возвращает действующие версии всех файлов медиатеки
+func (r *UserRepository) GetMediaAssets(ctx context.Context) ([]domain.MediaAsset, error) {
+	rows, err := r.db.QueryContext(ctx, activeMediaQ+` ORDER BY m.name;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []domain.MediaAsset
+	for rows.Next() {
+		var a domain.MediaAsset
+		if err := rows.Scan(&a.ID, &a.Name, &a.Kind, &a.FileID, &a.Version, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		assets = append(assets, a)
+	}
+	return assets, rows.Err()
+}
+
+// GetMediaAsset возвращает действующую версию файла или nil, если его нет в медиатеке
+func (r *UserRepository) GetMediaAsset(ctx context.Context, name string) (*domain.MediaAsset, error) {
+	var a domain.MediaAsset
+	err := r.db.QueryRowContext(ctx, activeMediaQ+` AND m.name = ?;`, name).
+		Scan(&a.ID, &a.Name, &a.Kind, &a.FileID, &a.Version, &a.CreatedBy, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// SaveMediaAsset сохраняет новую версию файла; предыдущие версии остаются в истории
+func (r *UserRepository) SaveMediaAsset(ctx context.Context, a *domain.MediaAsset) error {
+	const q = `
+		INSERT INTO media_assets (name, kind, file_id, version, created_by, created_at)
+		SELECT ?, ?, ?, COALESCE(MAX(version), 0) + 1, ?, datetime('now')
+		FROM media_assets WHERE name = ?
+		RETURNING id, version, created_at;
+	`
+	return r.db.QueryRowContext(ctx, q, a.Name, a.Kind, a.FileID, a.CreatedBy, a.Name).
+		Scan(&a.ID, &a.Version, &a.CreatedAt)
+}
+
+// DeleteMediaAsset удаляет действующую версию файла и возвращает ту, что стала
+// действующей, или nil, если версий не осталось. sql.ErrNoRows — файла нет в медиатеке.
+func (r *UserRepository) DeleteMediaAsset(ctx context.Context, name string) (*domain.MediaAsset, error) {
+	current, err := r.GetMediaAsset(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, sql.ErrNoRows
+	}
+	_, err = r.db.ExecContext(ctx, `UPDATE media_assets SET deleted_at = datetime('now') WHERE id = ?;`, current.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetMediaAsset(ctx, name)
+}
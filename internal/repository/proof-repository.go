@@ -0,0 +1,175 @@
+// ── internal/repository/proof-repository.go ──────────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"meily/internal/domain"
+	"time"
+)
+
+const proofSelect = `
+	SELECT
+		p.id, p.order_id, p.courier_id, COALESCE(cr.name, '') as courier_name,
+		p.code, p.code_sent_at, p.failed_attempts,
+		COALESCE(p.verified_by, '') as verified_by, p.verified_at,
+		COALESCE(p.photo_file_id, '') as photo_file_id,
+		p.latitude, p.longitude, p.delivered_at,
+		COALESCE(c.fio, '') as fio,
+		COALESCE(c.address, '') as address
+	FROM delivery_proofs p
+	LEFT JOIN couriers cr ON p.courier_id = cr.id
+	LEFT JOIN client c ON p.order_id = c.id
+`
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            DELIVERY PROOF METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// CreateDeliveryProof сохраняет код подтверждения для заказа.
+// Повторная выдача (например, после неудачной попытки) сбрасывает прежнее подтверждение,
+// но не счётчик неверных кодов — иначе новый код открывал бы новые попытки подбора.
+// Счётчик обнуляется только при новом назначении заказа курьеру, см. AssignOrders.
+func (r *UserRepository) CreateDeliveryProof(ctx context.Context, orderID, courierID int64, code string, sentAt time.Time) error {
+	const q = `
+		INSERT INTO delivery_proofs (order_id, courier_id, code, code_sent_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(order_id) DO UPDATE SET
+			courier_id = excluded.courier_id,
+			code = excluded.code,
+			code_sent_at = excluded.code_sent_at,
+			verified_by = NULL,
+			verified_at = NULL,
+			photo_file_id = NULL,
+			latitude = NULL,
+			longitude = NULL,
+			delivered_at = NULL;
+	`
+	_, err := r.db.ExecContext(ctx, q, orderID, courierID, code, sentAt)
+	return err
+}
+
+// GetDeliveryProof возвращает подтверждение доставки заказа или sql.ErrNoRows
+func (r *UserRepository) GetDeliveryProof(ctx context.Context, orderID int64) (*domain.DeliveryProof, error) {
+	proofs, err := r.queryProofs(ctx, proofSelect+`WHERE p.order_id = ?;`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(proofs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &proofs[0], nil
+}
+
+// GetRecentDeliveryProofs возвращает последние завершённые подтверждения для админ-панели
+func (r *UserRepository) GetRecentDeliveryProofs(ctx context.Context, limit int) ([]domain.DeliveryProof, error) {
+	q := proofSelect + `
+		WHERE p.delivered_at IS NOT NULL
+		ORDER BY p.delivered_at DESC
+		LIMIT ?;
+	`
+	return r.queryProofs(ctx, q, limit)
+}
+
+// AddProofFailedAttempt увеличивает счётчик неверных кодов заказа и возвращает новое значение
+func (r *UserRepository) AddProofFailedAttempt(ctx context.Context, orderID int64) (int, error) {
+	const q = `
+		UPDATE delivery_proofs SET failed_attempts = failed_attempts + 1
+		WHERE order_id = ?
+		RETURNING failed_attempts;
+	`
+	var n int
+	err := r.db.QueryRowContext(ctx, q, orderID).Scan(&n)
+	return n, err
+}
+
+// MarkProofVerified отмечает, что курьер ввёл верный код или отсканировал QR
+func (r *UserRepository) MarkProofVerified(ctx context.Context, orderID int64, method string, at time.Time) error {
+	const q = `UPDATE delivery_proofs SET verified_by = ?, verified_at = ? WHERE order_id = ?;`
+	_, err := r.db.ExecContext(ctx, q, method, at, orderID)
+	return err
+}
+
+// SaveProofPhoto сохраняет Telegram file_id фотографии у двери
+func (r *UserRepository) SaveProofPhoto(ctx context.Context, orderID int64, fileID string) error {
+	const q = `UPDATE delivery_proofs SET photo_file_id = ? WHERE order_id = ? AND verified_at IS NOT NULL;`
+	res, err := r.db.ExecContext(ctx, q, fileID, orderID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CompleteDelivery сохраняет местоположение курьера и переводит заказ в delivered
+// одной транзакцией — только если код подтверждён и фото загружено.
+func (r *UserRepository) CompleteDelivery(ctx context.Context, orderID int64, latitude, longitude float64, at time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const proofQ = `
+		UPDATE delivery_proofs
+		SET latitude = ?, longitude = ?, delivered_at = ?
+		WHERE order_id = ? AND verified_at IS NOT NULL AND photo_file_id IS NOT NULL;
+	`
+	res, err := tx.ExecContext(ctx, proofQ, latitude, longitude, at, orderID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	const orderQ = `
+		UPDATE client
+		SET status = ?, checks = true, status_updated_at = datetime('now'), updated_at = datetime('now')
+		WHERE id = ?;
+	`
+	if _, err := tx.ExecContext(ctx, orderQ, domain.OrderStatusDelivered, orderID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepository) queryProofs(ctx context.Context, q string, args ...interface{}) ([]domain.DeliveryProof, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var proofs []domain.DeliveryProof
+	for rows.Next() {
+		var p domain.DeliveryProof
+		var verifiedAt, deliveredAt sql.NullTime
+		var lat, lon sql.NullFloat64
+		if err := rows.Scan(
+			&p.ID, &p.OrderID, &p.CourierID, &p.CourierName,
+			&p.Code, &p.CodeSentAt, &p.FailedAttempts,
+			&p.VerifiedBy, &verifiedAt,
+			&p.PhotoFileID,
+			&lat, &lon, &deliveredAt,
+			&p.Fio, &p.Address,
+		); err != nil {
+			return nil, err
+		}
+		if verifiedAt.Valid {
+			p.VerifiedAt = &verifiedAt.Time
+		}
+		if deliveredAt.Valid {
+			p.DeliveredAt = &deliveredAt.Time
+		}
+		if lat.Valid && lon.Valid {
+			p.Latitude = &lat.Float64
+			p.Longitude = &lon.Float64
+		}
+		proofs = append(proofs, p)
+	}
+	return proofs, rows.Err()
+}
@@ -156,6 +156,42 @@ func (r *RedisRepository) DeleteBroadcastState(ctx context.Context, adminID int6
 	return nil
 }
 
+// ReserveSMSCode applies the SMS code limits before a code is sent to phone for the user:
+// at most one code per interval and limit codes a day, both per user and per number.
+// It returns how long to wait while the interval has not passed, and false when the
+// daily limit is used up. The limits live outside the chat state, so no reset skips them.
+func (r *RedisRepository) ReserveSMSCode(ctx context.Context, userID int64, phone string, interval time.Duration, limit int) (time.Duration, bool, error) {
+	for _, key := range []string{fmt.Sprintf("sms_cooldown:user:%d", userID), "sms_cooldown:phone:" + phone} {
+		ok, err := r.client.SetNX(ctx, key, 1, interval).Result()
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to reserve sms code in redis: %w", err)
+		}
+		if !ok {
+			wait, err := r.client.PTTL(ctx, key).Result()
+			if err != nil {
+				return 0, false, fmt.Errorf("failed to get sms cooldown from redis: %w", err)
+			}
+			return max(wait, time.Second), true, nil
+		}
+	}
+
+	for _, key := range []string{fmt.Sprintf("sms_count:user:%d", userID), "sms_count:phone:" + phone} {
+		n, err := r.client.Incr(ctx, key).Result()
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to count sms codes in redis: %w", err)
+		}
+		if n == 1 {
+			if err := r.client.Expire(ctx, key, 24*time.Hour).Err(); err != nil {
+				return 0, false, fmt.Errorf("failed to expire sms code count in redis: %w", err)
+			}
+		}
+		if n > int64(limit) {
+			return 0, false, nil
+		}
+	}
+	return 0, true, nil
+}
+
 // Helper method to clear all states for a user (useful for cleanup)
 func (r *RedisRepository) ClearAllUserStates(ctx context.Context, userID int64) error {
 	keys := []string{
@@ -0,0 +1,135 @@
+// ── internal/repository/reminder-repository.go ───────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"meily/internal/domain"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            CHECKOUT REMINDERS METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// StartCheckout фиксирует начало оформления заказа. Повторный выбор количества
+// перезапускает расписание напоминаний, но сохраняет отказ пользователя от рассылки.
+func (r *UserRepository) StartCheckout(ctx context.Context, userID int64, count, amount int, startedAt time.Time) error {
+	const q = `
+		INSERT INTO checkout_reminders (id_user, count, amount, status, reminders_sent, started_at, updated_at)
+		VALUES (?, ?, ?, ?, 0, ?, datetime('now'))
+		ON CONFLICT(id_user) DO UPDATE SET
+			count = excluded.count,
+			amount = excluded.amount,
+			status = CASE WHEN checkout_reminders.opted_out THEN ? ELSE excluded.status END,
+			reminders_sent = 0,
+			started_at = excluded.started_at,
+			last_reminded_at = NULL,
+			converted_at = NULL,
+			updated_at = datetime('now');
+	`
+	_, err := r.db.ExecContext(ctx, q, userID, count, amount, domain.ReminderStatusPending, startedAt, domain.ReminderStatusOptedOut)
+	return err
+}
+
+// GetPendingCheckouts возвращает незавершённые оформления, по которым ещё могут уйти напоминания
+func (r *UserRepository) GetPendingCheckouts(ctx context.Context) ([]domain.CheckoutReminder, error) {
+	const q = `
+		SELECT id, id_user, count, amount, status, reminders_sent, opted_out, started_at, last_reminded_at
+		FROM checkout_reminders
+		WHERE status = ? AND opted_out = false
+		ORDER BY started_at ASC;
+	`
+	rows, err := r.db.QueryContext(ctx, q, domain.ReminderStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []domain.CheckoutReminder
+	for rows.Next() {
+		var rem domain.CheckoutReminder
+		var lastReminded sql.NullTime
+		if err := rows.Scan(
+			&rem.ID, &rem.UserID, &rem.Count, &rem.Amount, &rem.Status,
+			&rem.RemindersSent, &rem.OptedOut, &rem.StartedAt, &lastReminded,
+		); err != nil {
+			return nil, err
+		}
+		if lastReminded.Valid {
+			rem.LastRemindedAt = &lastReminded.Time
+		}
+		reminders = append(reminders, rem)
+	}
+	return reminders, rows.Err()
+}
+
+// MarkReminderSent увеличивает счётчик отправленных напоминаний
+func (r *UserRepository) MarkReminderSent(ctx context.Context, userID int64, sentAt time.Time) error {
+	const q = `
+		UPDATE checkout_reminders
+		SET reminders_sent = reminders_sent + 1, last_reminded_at = ?, updated_at = datetime('now')
+		WHERE id_user = ?;
+	`
+	_, err := r.db.ExecContext(ctx, q, sentAt, userID)
+	return err
+}
+
+// MarkCheckoutExhausted закрывает оформление, по которому отправлены все напоминания
+func (r *UserRepository) MarkCheckoutExhausted(ctx context.Context, userID int64) error {
+	const q = `
+		UPDATE checkout_reminders
+		SET status = ?, updated_at = datetime('now')
+		WHERE id_user = ? AND status = ?;
+	`
+	_, err := r.db.ExecContext(ctx, q, domain.ReminderStatusExhausted, userID, domain.ReminderStatusPending)
+	return err
+}
+
+// MarkCheckoutConverted отмечает покупку; reminders_sent сохраняется для подсчёта конверсии
+func (r *UserRepository) MarkCheckoutConverted(ctx context.Context, userID int64, convertedAt time.Time) error {
+	const q = `
+		UPDATE checkout_reminders
+		SET status = ?, converted_at = ?, updated_at = datetime('now')
+		WHERE id_user = ? AND status IN (?, ?);
+	`
+	_, err := r.db.ExecContext(ctx, q,
+		domain.ReminderStatusConverted, convertedAt, userID,
+		domain.ReminderStatusPending, domain.ReminderStatusExhausted,
+	)
+	return err
+}
+
+// OptOutReminders отключает напоминания для пользователя навсегда
+func (r *UserRepository) OptOutReminders(ctx context.Context, userID int64) error {
+	const q = `
+		UPDATE checkout_reminders
+		SET opted_out = true,
+			status = CASE WHEN status = ? THEN status ELSE ? END,
+			updated_at = datetime('now')
+		WHERE id_user = ?;
+	`
+	_, err := r.db.ExecContext(ctx, q, domain.ReminderStatusConverted, domain.ReminderStatusOptedOut, userID)
+	return err
+}
+
+// GetReminderStats возвращает статистику напоминаний и конверсий
+func (r *UserRepository) GetReminderStats(ctx context.Context) (*domain.ReminderStats, error) {
+	const q = `
+		SELECT
+			COUNT(CASE WHEN status = ? THEN 1 END) as pending,
+			COUNT(CASE WHEN reminders_sent > 0 THEN 1 END) as reminded,
+			COUNT(CASE WHEN status = ? THEN 1 END) as converted,
+			COUNT(CASE WHEN status = ? AND reminders_sent > 0 THEN 1 END) as converted_after_ping,
+			COUNT(CASE WHEN opted_out THEN 1 END) as opted_out
+		FROM checkout_reminders;
+	`
+	var stats domain.ReminderStats
+	err := r.db.QueryRowContext(ctx, q,
+		domain.ReminderStatusPending, domain.ReminderStatusConverted, domain.ReminderStatusConverted,
+	).Scan(&stats.Pending, &stats.Reminded, &stats.Converted, &stats.ConvertedAfterPing, &stats.OptedOut)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,42 @@
+// ── internal/repository/settings-repository.go ───────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            USER SETTINGS METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// GetUserLanguage возвращает язык пользователя или пустую строку, если он ещё не сохранён
+func (r *UserRepository) GetUserLanguage(ctx context.Context, userID int64) (string, error) {
+	var lang string
+	err := r.db.QueryRowContext(ctx, `SELECT language FROM user_settings WHERE id_user = ?;`, userID).Scan(&lang)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return lang, err
+}
+
+// SetUserLanguage сохраняет язык, выбранный пользователем командой /language
+func (r *UserRepository) SetUserLanguage(ctx context.Context, userID int64, lang string) error {
+	const q = `
+		INSERT INTO user_settings (id_user, language, updated_at)
+		VALUES (?, ?, datetime('now'))
+		ON CONFLICT(id_user) DO UPDATE SET
+			language = excluded.language,
+			updated_at = excluded.updated_at;
+	`
+	_, err := r.db.ExecContext(ctx, q, userID, lang)
+	return err
+}
+
+// InitUserLanguage сохраняет язык, определённый по настройкам Telegram, только если
+// пользователь ещё не выбрал язык сам
+func (r *UserRepository) InitUserLanguage(ctx context.Context, userID int64, lang string) error {
+	const q = `INSERT INTO user_settings (id_user, language) VALUES (?, ?) ON CONFLICT(id_user) DO NOTHING;`
+	_, err := r.db.ExecContext(ctx, q, userID, lang)
+	return err
+}
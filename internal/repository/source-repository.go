@@ -0,0 +1,140 @@
+// ── internal/repository/source-repository.go ─────────────────────────────────
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"meily/internal/domain"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            CAMPAIGN SOURCE METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// ErrChargeProcessed — билеты по этой оплате уже выданы
+var ErrChargeProcessed = errors.New("payment already processed")
+
+// Модели атрибуции отчёта по источникам
+const (
+	AttributionFirstTouch = "first"
+	AttributionLastTouch  = "last"
+)
+
+// SetUserSource сохраняет источник перехода: первый сохраняется один раз,
+// последний перезаписывается при каждом переходе по ссылке
+func (r *UserRepository) SetUserSource(ctx context.Context, userID int64, source string) error {
+	const q = `
+		UPDATE just
+		SET source = CASE WHEN COALESCE(source, '') = '' THEN ? ELSE source END,
+			last_source = ?,
+			updated_at = datetime('now')
+		WHERE id_user = ?;
+	`
+	_, err := r.db.ExecContext(ctx, q, source, source, userID)
+	return err
+}
+
+// InsertPaidTickets записывает оплату с последним источником покупателя и её билеты
+// лото одной транзакцией. charge уникален в payments: повторная доставка той же оплаты
+// возвращает ErrChargeProcessed, а при ошибке не остаётся ни оплаты, ни части билетов.
+func (r *UserRepository) InsertPaidTickets(ctx context.Context, userID int64, amount, sets int, charge string, tickets []domain.LotoEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const paymentQ = `
+		INSERT INTO payments (id_user, amount, sets, charge_id, source, paid_at)
+		VALUES (?, ?, ?, ?, COALESCE((SELECT last_source FROM just WHERE id_user = ?), ''), datetime('now'))
+		ON CONFLICT(charge_id) DO NOTHING;
+	`
+	res, err := tx.ExecContext(ctx, paymentQ, userID, amount, sets, nullIfEmpty(charge), userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrChargeProcessed
+	}
+
+	const lotoQ = `
+		INSERT OR REPLACE INTO loto (id_user, id_loto, qr, who_paid, receipt, fio, contact, address, dataPay, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'));
+	`
+	for _, e := range tickets {
+		if _, err := tx.ExecContext(ctx, lotoQ,
+			e.UserID, e.LotoID, e.QR, e.WhoPaid,
+			e.Receipt, e.Fio, e.Contact, e.Address, e.DatePay,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetSourceReport возвращает пользователей, покупателей, заказы и выручку по источникам.
+// При first-touch пользователь и все его оплаты относятся к первому источнику, при
+// last-touch — каждая оплата к источнику, по которому покупатель пришёл перед ней.
+// from и to (YYYY-MM-DD, включительно) ограничивают дату регистрации и дату оплаты.
+func (r *UserRepository) GetSourceReport(ctx context.Context, model, from, to string) ([]domain.SourceStats, error) {
+	const firstTouchQ = `
+		SELECT COALESCE(NULLIF(j.source, ''), 'direct') AS src,
+			COUNT(*),
+			COUNT(p.id_user),
+			COALESCE(SUM(p.orders), 0),
+			COALESCE(SUM(p.revenue), 0)
+		FROM just j
+		LEFT JOIN (
+			SELECT id_user, COUNT(*) AS orders, SUM(amount) AS revenue
+			FROM payments
+			WHERE (? = '' OR date(paid_at) >= ?) AND (? = '' OR date(paid_at) <= ?)
+			GROUP BY id_user
+		) p ON p.id_user = j.id_user
+		WHERE (? = '' OR date(j.created_at) >= ?) AND (? = '' OR date(j.created_at) <= ?)
+		GROUP BY src
+		ORDER BY 5 DESC, 2 DESC;
+	`
+	const lastTouchQ = `
+		SELECT src, SUM(users), SUM(buyers), SUM(orders), SUM(revenue)
+		FROM (
+			SELECT COALESCE(NULLIF(last_source, ''), 'direct') AS src,
+				COUNT(*) AS users, 0 AS buyers, 0 AS orders, 0 AS revenue
+			FROM just
+			WHERE (? = '' OR date(created_at) >= ?) AND (? = '' OR date(created_at) <= ?)
+			GROUP BY src
+			UNION ALL
+			SELECT COALESCE(NULLIF(source, ''), 'direct'),
+				0, COUNT(DISTINCT id_user), COUNT(*), SUM(amount)
+			FROM payments
+			WHERE (? = '' OR date(paid_at) >= ?) AND (? = '' OR date(paid_at) <= ?)
+			GROUP BY 1
+		)
+		GROUP BY src
+		ORDER BY 5 DESC, 2 DESC;
+	`
+	q := firstTouchQ
+	if model == AttributionLastTouch {
+		q = lastTouchQ
+	}
+
+	rows, err := r.db.QueryContext(ctx, q, from, from, to, to, from, from, to, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []domain.SourceStats
+	for rows.Next() {
+		var s domain.SourceStats
+		if err := rows.Scan(&s.Source, &s.Users, &s.Buyers, &s.Orders, &s.Revenue); err != nil {
+			return nil, err
+		}
+		if s.Users > 0 {
+			s.Conversion = math.Round(float64(s.Buyers)/float64(s.Users)*1000) / 10
+		}
+		report = append(report, s)
+	}
+	return report, rows.Err()
+}
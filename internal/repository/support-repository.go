@@ -0,0 +1,146 @@
+// ── internal/repository/support-repository.go ────────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"meily/internal/domain"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                              SUPPORT TICKETS METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+const supportTicketSelect = `
+	SELECT t.id, t.id_user, COALESCE(j.userName, ''), t.status, t.last_user_message_at, t.closed_at, t.created_at
+	FROM support_tickets t
+	LEFT JOIN just j ON j.id_user = t.id_user
+`
+
+// GetOpenSupportTicket возвращает открытое обращение пользователя или nil
+func (r *UserRepository) GetOpenSupportTicket(ctx context.Context, userID int64) (*domain.SupportTicket, error) {
+	tickets, err := r.querySupportTickets(ctx, supportTicketSelect+`
+		WHERE t.id_user = ? AND t.status = ?
+		ORDER BY t.id DESC LIMIT 1;
+	`, userID, domain.SupportTicketOpen)
+	if err != nil || len(tickets) == 0 {
+		return nil, err
+	}
+	return &tickets[0], nil
+}
+
+// OpenSupportTicket возвращает открытое обращение пользователя, создавая его при необходимости
+func (r *UserRepository) OpenSupportTicket(ctx context.Context, userID int64) (*domain.SupportTicket, error) {
+	ticket, err := r.GetOpenSupportTicket(ctx, userID)
+	if err != nil || ticket != nil {
+		return ticket, err
+	}
+
+	res, err := r.db.ExecContext(ctx, `INSERT INTO support_tickets (id_user, status) VALUES (?, ?);`, userID, domain.SupportTicketOpen)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetSupportTicket(ctx, id)
+}
+
+// GetSupportTicket возвращает обращение по id или nil
+func (r *UserRepository) GetSupportTicket(ctx context.Context, id int64) (*domain.SupportTicket, error) {
+	tickets, err := r.querySupportTickets(ctx, supportTicketSelect+`WHERE t.id = ?;`, id)
+	if err != nil || len(tickets) == 0 {
+		return nil, err
+	}
+	return &tickets[0], nil
+}
+
+// GetSupportTicketByMessage возвращает обращение, к которому относится сообщение чата поддержки, или nil
+func (r *UserRepository) GetSupportTicketByMessage(ctx context.Context, chatID int64, messageID int) (*domain.SupportTicket, error) {
+	var ticketID int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT ticket_id FROM support_messages WHERE chat_id = ? AND message_id = ? LIMIT 1;
+	`, chatID, messageID).Scan(&ticketID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.GetSupportTicket(ctx, ticketID)
+}
+
+// GetOpenSupportTickets возвращает открытые обращения, старые первыми
+func (r *UserRepository) GetOpenSupportTickets(ctx context.Context) ([]domain.SupportTicket, error) {
+	return r.querySupportTickets(ctx, supportTicketSelect+`
+		WHERE t.status = ?
+		ORDER BY t.id;
+	`, domain.SupportTicketOpen)
+}
+
+// AddSupportMessage сохраняет сообщение обращения; сообщение покупателя обновляет
+// время его последнего сообщения
+func (r *UserRepository) AddSupportMessage(ctx context.Context, m domain.SupportMessage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO support_messages (ticket_id, direction, chat_id, message_id, from_id, kind, text)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
+	`, m.TicketID, m.Direction, m.ChatID, m.MessageID, m.FromID, m.Kind, nullIfEmpty(m.Text))
+	if err != nil {
+		return err
+	}
+
+	q := `UPDATE support_tickets SET updated_at = datetime('now') WHERE id = ?;`
+	if m.Direction == domain.SupportMessageIn {
+		q = `UPDATE support_tickets SET last_user_message_at = datetime('now'), updated_at = datetime('now') WHERE id = ?;`
+	}
+	if _, err := tx.ExecContext(ctx, q, m.TicketID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CloseSupportTicket закрывает обращение; false — оно уже было закрыто
+func (r *UserRepository) CloseSupportTicket(ctx context.Context, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE support_tickets
+		SET status = ?, closed_at = datetime('now'), updated_at = datetime('now')
+		WHERE id = ? AND status = ?;
+	`, domain.SupportTicketClosed, id, domain.SupportTicketOpen)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *UserRepository) querySupportTickets(ctx context.Context, q string, args ...interface{}) ([]domain.SupportTicket, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tickets []domain.SupportTicket
+	for rows.Next() {
+		var t domain.SupportTicket
+		var lastMessageAt, closedAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.UserID, &t.UserName, &t.Status, &lastMessageAt, &closedAt, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		if lastMessageAt.Valid {
+			t.LastUserMessageAt = &lastMessageAt.Time
+		}
+		if closedAt.Valid {
+			t.ClosedAt = &closedAt.Time
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, rows.Err()
+}
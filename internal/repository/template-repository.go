@@ -0,0 +1,85 @@
+// ── internal/repository/template-repository.go ───────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"meily/internal/domain"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            MESSAGE TEMPLATES METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// activeTemplatesQ выбирает последнюю неотменённую версию каждого шаблона
+const activeTemplatesQ = `
+	SELECT t.id, t.template_key, t.language, t.body, t.version, t.created_by, t.created_at
+	FROM message_templates t
+	WHERE t.reverted_at IS NULL AND t.version = (
+		SELECT MAX(v.version) FROM message_templates v
+		WHERE v.template_key = t.template_key AND v.language = t.language AND v.reverted_at IS NULL
+	)
+`
+
+// GetActiveTemplates возвращает действующие версии всех шаблонов
+func (r *UserRepository) GetActiveTemplates(ctx context.Context) ([]domain.MessageTemplate, error) {
+	rows, err := r.db.QueryContext(ctx, activeTemplatesQ+` ORDER BY t.template_key, t.language;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []domain.MessageTemplate
+	for rows.Next() {
+		var t domain.MessageTemplate
+		if err := rows.Scan(&t.ID, &t.Key, &t.Language, &t.Body, &t.Version, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// GetActiveTemplate возвращает действующую версию шаблона или nil, если действует встроенный текст
+func (r *UserRepository) GetActiveTemplate(ctx context.Context, key, lang string) (*domain.MessageTemplate, error) {
+	var t domain.MessageTemplate
+	err := r.db.QueryRowContext(ctx, activeTemplatesQ+` AND t.template_key = ? AND t.language = ?;`, key, lang).
+		Scan(&t.ID, &t.Key, &t.Language, &t.Body, &t.Version, &t.CreatedBy, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SaveTemplate сохраняет новую версию шаблона. Номер версии растёт и после отката,
+// поэтому история правок не перезаписывается.
+func (r *UserRepository) SaveTemplate(ctx context.Context, t *domain.MessageTemplate) error {
+	const q = `
+		INSERT INTO message_templates (template_key, language, body, version, created_by, created_at)
+		SELECT ?, ?, ?, COALESCE(MAX(version), 0) + 1, ?, datetime('now')
+		FROM message_templates WHERE template_key = ? AND language = ?
+		RETURNING id, version, created_at;
+	`
+	return r.db.QueryRowContext(ctx, q, t.Key, t.Language, t.Body, t.CreatedBy, t.Key, t.Language).
+		Scan(&t.ID, &t.Version, &t.CreatedAt)
+}
+
+// RollbackTemplate отменяет действующую версию шаблона и возвращает ту, что стала
+// действующей, или nil, если остался встроенный текст. sql.ErrNoRows — откатывать нечего.
+func (r *UserRepository) RollbackTemplate(ctx context.Context, key, lang string) (*domain.MessageTemplate, error) {
+	current, err := r.GetActiveTemplate(ctx, key, lang)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, sql.ErrNoRows
+	}
+	_, err = r.db.ExecContext(ctx, `UPDATE message_templates SET reverted_at = datetime('now') WHERE id = ?;`, current.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetActiveTemplate(ctx, key, lang)
+}
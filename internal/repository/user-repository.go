@@ -9,6 +9,7 @@ import (
 	"log"
 	"math"
 	"meily/internal/domain"
+	"meily/traits/helper"
 	"strconv"
 	"strings"
 	"time"
@@ -33,11 +34,13 @@ type LottoStats struct {
 
 // GeoStats represents geographical distribution statistics
 type GeoStats struct {
-	Almaty    int `json:"almaty"`
-	Nursultan int `json:"nursultan"`
-	Shymkent  int `json:"shymkent"`
-	Karaganda int `json:"karaganda"`
-	Others    int `json:"others"`
+	Almaty    int            `json:"almaty"`
+	Nursultan int            `json:"nursultan"`
+	Shymkent  int            `json:"shymkent"`
+	Karaganda int            `json:"karaganda"`
+	Others    int            `json:"others"`
+	Cities    map[string]int `json:"cities"`
+	Regions   map[string]int `json:"regions"`
 }
 
 // AdminClientEntry represents enhanced client data for admin dashboard with geolocation
@@ -87,7 +90,18 @@ type AdminLog struct {
 
 // UserRepository работает со всеми таблицами: just, client, loto, geo, bot_sessions, admin_logs.
 type UserRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	places PlaceResolver
+}
+
+// PlaceResolver определяет город и область по координатам
+type PlaceResolver interface {
+	Resolve(lat, lon float64) (domain.Place, bool)
+}
+
+// SetPlaceResolver включает определение города и области при сохранении координат
+func (r *UserRepository) SetPlaceResolver(places PlaceResolver) {
+	r.places = places
 }
 
 // NewUserRepository создаёт новый UserRepository.
@@ -144,7 +158,7 @@ func (r *UserRepository) GetClientsByLocationRadius(ctx context.Context, centerL
 
 		// Parse coordinates and calculate distance
 		if lat.Valid && lon.Valid {
-			distance := calculateDistance(centerLat, centerLon, lat.Float64, lon.Float64)
+			distance := CalculateDistance(centerLat, centerLon, lat.Float64, lon.Float64)
 			if distance <= float64(radiusKm) {
 				entry.HasGeo = true
 				entry.Latitude = &lat.Float64
@@ -171,8 +185,8 @@ func (r *UserRepository) GetClientsByLocationRadius(ctx context.Context, centerL
 	return entries, nil
 }
 
-// calculateDistance вычисляет расстояние между двумя точками (формула Haversine)
-func calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
+// CalculateDistance вычисляет расстояние в километрах между двумя точками (формула Haversine)
+func CalculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	const R = 6371 // Earth's radius in kilometers
 
 	dLat := (lat2 - lat1) * math.Pi / 180
@@ -340,11 +354,12 @@ func (r *UserRepository) GetAdminLogs(ctx context.Context, limit int) ([]AdminLo
 //                            ENHANCED GEO METHODS
 // ═══════════════════════════════════════════════════════════════════════════════
 
-// InsertGeoWithEnhancements вставляет расширенную гео-запись (SQLite version)
+// InsertGeoWithEnhancements вставляет расширенную гео-запись (SQLite version).
+// Если город не передан, город и область определяются по координатам.
 func (r *UserRepository) InsertGeoWithEnhancements(ctx context.Context, userID int64, location string, lat, lon *float64, accuracyMeters *int, addressComponents json.RawMessage, city, country *string) error {
 	const q = `
-		INSERT OR REPLACE INTO geo (id_user, location, dataReg, latitude, longitude, accuracy_meters, address_components, city, country, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'));
+		INSERT OR REPLACE INTO geo (id_user, location, dataReg, latitude, longitude, accuracy_meters, address_components, city, region, country, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'));
 	`
 
 	now := time.Now().Format("2006-01-02 15:04:05")
@@ -353,6 +368,16 @@ func (r *UserRepository) InsertGeoWithEnhancements(ctx context.Context, userID i
 		countryVal = *country
 	}
 
+	var region *string
+	if lat != nil && lon != nil && r.places != nil {
+		if place, ok := r.places.Resolve(*lat, *lon); ok {
+			if city == nil && place.City != "" {
+				city = &place.City
+			}
+			region = &place.Region
+		}
+	}
+
 	// Convert JSON to string for SQLite
 	var addressComponentsStr *string
 	if addressComponents != nil {
@@ -360,10 +385,69 @@ func (r *UserRepository) InsertGeoWithEnhancements(ctx context.Context, userID i
 		addressComponentsStr = &str
 	}
 
-	_, err := r.db.ExecContext(ctx, q, userID, location, now, lat, lon, accuracyMeters, addressComponentsStr, city, countryVal)
+	_, err := r.db.ExecContext(ctx, q, userID, location, now, lat, lon, accuracyMeters, addressComponentsStr, city, region, countryVal)
 	return err
 }
 
+// BackfillGeoPlaces определяет город и область для сохранённых координат.
+// Без force обрабатываются только записи без области. Возвращает число обновлённых записей.
+func (r *UserRepository) BackfillGeoPlaces(ctx context.Context, force bool) (int, error) {
+	if r.places == nil {
+		return 0, fmt.Errorf("place resolver is not configured")
+	}
+
+	q := `SELECT id, latitude, longitude FROM geo WHERE latitude IS NOT NULL AND longitude IS NOT NULL`
+	if !force {
+		q += ` AND (region IS NULL OR region = '')`
+	}
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	type geoPoint struct {
+		id       int64
+		lat, lon float64
+	}
+	var points []geoPoint
+	for rows.Next() {
+		var p geoPoint
+		if err := rows.Scan(&p.id, &p.lat, &p.lon); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		points = append(points, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE geo SET city = ?, region = ?, updated_at = datetime('now') WHERE id = ?;`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	updated := 0
+	for _, p := range points {
+		place, ok := r.places.Resolve(p.lat, p.lon)
+		if !ok {
+			continue
+		}
+		if _, err := stmt.ExecContext(ctx, nullIfEmpty(place.City), place.Region, p.id); err != nil {
+			return 0, err
+		}
+		updated++
+	}
+	return updated, tx.Commit()
+}
+
 // GetGeoWithEnhancements получает расширенную гео-информацию
 func (r *UserRepository) GetGeoWithEnhancements(ctx context.Context, userID int64) (*domain.GeoEntry, *float64, *float64, *int, *string, error) {
 	const q = `
@@ -423,21 +507,43 @@ func (r *UserRepository) InsertJust(ctx context.Context, e domain.JustEntry) err
 	return err
 }
 
-// InsertClient вставляет запись в таблицу client с учетом новых полей (SQLite version)
+// InsertClient вставляет запись в таблицу client с учетом новых полей (SQLite version).
+// Телефон сохраняется в формате E.164, аккаунт связывается с покупателем, заказу
+// присваивается последний источник перехода пользователя. Число наборов берётся из
+// последней оплаты пользователя, а без неё — из e.Sets.
+// Повторная покупка обновляет только контакт и оплату: id заказа, статус, курьер и
+// данные доставки сохраняются, иначе потерялись бы ссылки на заказ из delivery_proofs.
 func (r *UserRepository) InsertClient(ctx context.Context, e domain.ClientEntry) error {
+	e.Contact = helper.NormalizePhone(e.Contact)
 	const q = `
-		INSERT OR REPLACE INTO client (id_user, userName, fio, contact, address, dateRegister, dataPay, checks, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, datetime('now'));
+		INSERT INTO client (id_user, userName, fio, contact, address, dateRegister, dataPay, checks, phone_verified_by, source, sets, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE((SELECT last_source FROM just WHERE id_user = ?), ''),
+			COALESCE((SELECT sets FROM payments WHERE id_user = ? ORDER BY id DESC LIMIT 1), ?), datetime('now'))
+		ON CONFLICT(id_user) DO UPDATE SET
+			userName = excluded.userName,
+			contact = excluded.contact,
+			dataPay = excluded.dataPay,
+			phone_verified_by = excluded.phone_verified_by,
+			source = excluded.source,
+			sets = excluded.sets,
+			updated_at = excluded.updated_at;
 	`
 	_, err := r.db.ExecContext(ctx, q,
 		e.UserID, e.UserName, e.Fio, e.Contact,
 		e.Address, e.DateRegister, e.DatePay, e.Checks,
+		nullIfEmpty(e.PhoneVerifiedBy), e.UserID, e.UserID, e.Sets,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return r.linkCustomer(ctx, e.UserID, e.Contact, true)
 }
 
 // InsertLoto вставляет запись в таблицу loto с учетом уникального ключа (SQLite version)
 func (r *UserRepository) InsertLoto(ctx context.Context, e domain.LotoEntry) error {
+	if e.Contact.Valid {
+		e.Contact.String = helper.NormalizePhone(e.Contact.String)
+	}
 	const q = `
 		INSERT OR REPLACE INTO loto (id_user, id_loto, qr, who_paid, receipt, fio, contact, address, dataPay, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'));
@@ -446,11 +552,16 @@ func (r *UserRepository) InsertLoto(ctx context.Context, e domain.LotoEntry) err
 		e.UserID, e.LotoID, e.QR, e.WhoPaid,
 		e.Receipt, e.Fio, e.Contact, e.Address, e.DatePay,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return r.linkCustomer(ctx, e.UserID, e.Contact.String, false)
 }
 
 // UpdateLotoWithLoop updates loto records one by one (if you need individual control)
 func (r *UserRepository) UpdateLotoWithLoop(ctx context.Context, userID int64, fio, contact, address string) error {
+	contact = helper.NormalizePhone(contact)
+
 	// First, get all loto IDs that need updating
 	const selectQ = `
 		SELECT id_loto 
@@ -494,7 +605,7 @@ func (r *UserRepository) UpdateLotoWithLoop(ctx context.Context, userID int64, f
 	}
 
 	log.Printf("Successfully updated %d loto records for user %d", len(lotoIDs), userID)
-	return nil
+	return r.linkCustomer(ctx, userID, contact, false)
 }
 
 // InsertGeo вставляет запись в таблицу geo (legacy support)
@@ -737,38 +848,117 @@ func (r *UserRepository) GetAllJustUserIDs(ctx context.Context) ([]int64, error)
 // GetClientByUserID получает данные клиента по user ID
 func (r *UserRepository) GetClientByUserID(ctx context.Context, userID int64) (*domain.ClientEntry, error) {
 	const q = `
-		SELECT id_user, userName, fio, contact, address, dateRegister, dataPay, checks
+		SELECT id_user, userName, fio, contact, address, dateRegister, dataPay, checks, address_details,
+			COALESCE(phone_verified_by, '')
 		FROM client
 		WHERE id_user = ? AND checks = false;
 	`
 	var client domain.ClientEntry
+	var details sql.NullString
 	err := r.db.QueryRowContext(ctx, q, userID).Scan(
 		&client.UserID, &client.UserName,
 		&client.Fio, &client.Contact, &client.Address,
-		&client.DateRegister, &client.DatePay, &client.Checks,
+		&client.DateRegister, &client.DatePay, &client.Checks, &details,
+		&client.PhoneVerifiedBy,
 	)
 	if err != nil {
 		return nil, err
 	}
+	if details.Valid && details.String != "" {
+		var a domain.Address
+		if err := json.Unmarshal([]byte(details.String), &a); err == nil {
+			client.AddressDetails = &a
+		}
+	}
 	return &client, nil
 }
 
-// UpdateClientDeliveryData обновляет данные доставки клиента (SQLite version)
-func (r *UserRepository) UpdateClientDeliveryData(ctx context.Context, userID int64, fio, address string, latitude, longitude float64) error {
+// SetClientAddressDetails сохраняет структурированный адрес клиента
+func (r *UserRepository) SetClientAddressDetails(ctx context.Context, userID int64, a domain.Address) error {
+	details, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `UPDATE client SET address_details = ?, updated_at = datetime('now') WHERE id_user = ?;`,
+		string(details), userID)
+	return err
+}
+
+// UpdateClientDeliveryData обновляет данные доставки клиента (SQLite version).
+// Координаты, по которым нужна ручная метка (центр города или адрес не найден), не сохраняются:
+// прежние координаты стираются, в гео-записи остаётся только найденный город.
+func (r *UserRepository) UpdateClientDeliveryData(ctx context.Context, userID int64, fio, address string, geocode domain.AddressGeocode) error {
 	const q = `
 		UPDATE client 
-		SET fio = ?, address = ?, checks = true, updated_at = datetime('now')
+		SET fio = ?, address = ?, checks = true, geo_confidence = ?, updated_at = datetime('now')
 		WHERE id_user = ?;
 	`
-	_, err := r.db.ExecContext(ctx, q, fio, address, userID)
+	_, err := r.db.ExecContext(ctx, q, fio, address, geocode.Confidence, userID)
 	if err != nil {
 		return err
 	}
 
+	var city *string
+	if geocode.City != "" {
+		city = &geocode.City
+	}
+	if geocode.NeedsPin() {
+		return r.InsertGeoWithEnhancements(ctx, userID, "", nil, nil, nil, nil, city, nil)
+	}
+
 	// Also insert/update geo data with enhanced fields
 	return r.InsertGeoWithEnhancements(ctx, userID,
-		FormatLocationString(latitude, longitude),
-		&latitude, &longitude, nil, nil, nil, nil)
+		FormatLocationString(geocode.Lat, geocode.Lon),
+		&geocode.Lat, &geocode.Lon, nil, nil, city, nil)
+}
+
+// GetPinRequests возвращает заказы без подтверждённых координат, которым нужна ручная метка
+func (r *UserRepository) GetPinRequests(ctx context.Context) ([]domain.PinRequest, error) {
+	const q = `
+		SELECT c.id, c.id_user, COALESCE(c.fio, ''), COALESCE(c.contact, ''), COALESCE(c.address, ''),
+			c.geo_confidence, g.latitude, g.longitude
+		FROM client c
+		LEFT JOIN geo g ON g.id_user = c.id_user
+		WHERE c.geo_confidence IN (?, ?)
+		ORDER BY c.id;
+	`
+	rows, err := r.db.QueryContext(ctx, q, domain.GeoConfidenceLow, domain.GeoConfidenceNone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []domain.PinRequest
+	for rows.Next() {
+		var p domain.PinRequest
+		var lat, lon sql.NullFloat64
+		if err := rows.Scan(&p.OrderID, &p.UserID, &p.Fio, &p.Contact, &p.Address, &p.Confidence, &lat, &lon); err != nil {
+			return nil, err
+		}
+		if lat.Valid && lon.Valid {
+			p.Latitude, p.Longitude = &lat.Float64, &lon.Float64
+		}
+		requests = append(requests, p)
+	}
+	return requests, rows.Err()
+}
+
+// SetOrderPin сохраняет координаты, поставленные администратором вручную
+func (r *UserRepository) SetOrderPin(ctx context.Context, orderID int64, lat, lon float64) error {
+	if !ValidateCoordinates(lat, lon) {
+		return fmt.Errorf("invalid coordinates: lat=%f, lon=%f", lat, lon)
+	}
+
+	var userID int64
+	if err := r.db.QueryRowContext(ctx, `SELECT id_user FROM client WHERE id = ?;`, orderID).Scan(&userID); err != nil {
+		return err
+	}
+	if err := r.UpdateGeoLocation(ctx, userID, lat, lon); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE client SET geo_confidence = ?, updated_at = datetime('now') WHERE id = ?;`,
+		domain.GeoConfidenceManual, orderID)
+	return err
 }
 
 // GetAllClientsWithDeliveryData получает всех клиентов с данными доставки
@@ -1342,41 +1532,48 @@ func (r *UserRepository) GetLottoStats(ctx context.Context) *LottoStats {
 	return &stats
 }
 
-// GetGeoStats возвращает географическую статистику
+// GetGeoStats возвращает географическую статистику по определённым городу и области
 func (r *UserRepository) GetGeoStats(ctx context.Context) *GeoStats {
 	const q = `
-		SELECT latitude, longitude, COUNT(*) as count
+		SELECT COALESCE(city, ''), COALESCE(region, ''), COUNT(*) as count
 		FROM geo
 		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
-		GROUP BY ROUND(latitude, 1), ROUND(longitude, 1);
+		GROUP BY city, region;
 	`
 
+	stats := GeoStats{Cities: map[string]int{}, Regions: map[string]int{}}
 	rows, err := r.db.QueryContext(ctx, q)
 	if err != nil {
-		return &GeoStats{Almaty: 0, Nursultan: 0, Shymkent: 0, Karaganda: 0, Others: 0}
+		return &stats
 	}
 	defer rows.Close()
 
-	var stats GeoStats
 	for rows.Next() {
-		var lat, lon float64
+		var city, region string
 		var count int
-		if err := rows.Scan(&lat, &lon, &count); err != nil {
+		if err := rows.Scan(&city, &region, &count); err != nil {
 			continue
 		}
 
-		// Categorize by approximate coordinates for Kazakhstan cities
-		if lat >= 43.0 && lat <= 43.5 && lon >= 76.5 && lon <= 77.2 {
-			stats.Almaty += count // Almaty region
-		} else if lat >= 51.0 && lat <= 51.5 && lon >= 71.0 && lon <= 71.8 {
-			stats.Nursultan += count // Nur-Sultan/Astana region
-		} else if lat >= 42.0 && lat <= 42.5 && lon >= 69.0 && lon <= 70.0 {
-			stats.Shymkent += count // Shymkent region
-		} else if lat >= 49.5 && lat <= 50.0 && lon >= 72.5 && lon <= 73.5 {
-			stats.Karaganda += count // Karaganda region
-		} else {
+		switch city {
+		case "Алматы":
+			stats.Almaty += count
+		case "Астана":
+			stats.Nursultan += count
+		case "Шымкент":
+			stats.Shymkent += count
+		case "Караганда":
+			stats.Karaganda += count
+		default:
 			stats.Others += count
 		}
+		if city != "" {
+			stats.Cities[city] += count
+		}
+		if region == "" {
+			region = "Не определено"
+		}
+		stats.Regions[region] += count
 	}
 
 	return &stats
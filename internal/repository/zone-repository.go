@@ -0,0 +1,111 @@
+// ── internal/repository/zone-repository.go ───────────────────────────────────
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"meily/internal/domain"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+//                            DELIVERY ZONES METHODS
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// UpsertDeliveryZone добавляет зону или обновляет существующую с тем же именем
+func (r *UserRepository) UpsertDeliveryZone(ctx context.Context, z domain.DeliveryZone) (int64, error) {
+	const q = `
+		INSERT INTO delivery_zones (name, geojson, fee, delivery_days, enabled, updated_at)
+		VALUES (?, ?, ?, ?, ?, datetime('now'))
+		ON CONFLICT(name) DO UPDATE SET
+			geojson = excluded.geojson,
+			fee = excluded.fee,
+			delivery_days = excluded.delivery_days,
+			enabled = excluded.enabled,
+			updated_at = datetime('now');
+	`
+	if _, err := r.db.ExecContext(ctx, q, z.Name, string(z.GeoJSON), z.Fee, z.DeliveryDays, z.Enabled); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM delivery_zones WHERE name = ?;`, z.Name).Scan(&id)
+	return id, err
+}
+
+// GetDeliveryZones возвращает все зоны доставки
+func (r *UserRepository) GetDeliveryZones(ctx context.Context) ([]domain.DeliveryZone, error) {
+	const q = `
+		SELECT id, name, geojson, fee, delivery_days, enabled, created_at
+		FROM delivery_zones
+		ORDER BY fee ASC, name ASC;
+	`
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zones []domain.DeliveryZone
+	for rows.Next() {
+		var z domain.DeliveryZone
+		var geojson string
+		if err := rows.Scan(&z.ID, &z.Name, &geojson, &z.Fee, &z.DeliveryDays, &z.Enabled, &z.CreatedAt); err != nil {
+			return nil, err
+		}
+		z.GeoJSON = []byte(geojson)
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+// SetDeliveryZoneEnabled включает или отключает зону
+func (r *UserRepository) SetDeliveryZoneEnabled(ctx context.Context, zoneID int64, enabled bool) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE delivery_zones SET enabled = ?, updated_at = datetime('now') WHERE id = ?;`, enabled, zoneID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteDeliveryZone удаляет зону; заказы сохраняют id зоны как историю
+func (r *UserRepository) DeleteDeliveryZone(ctx context.Context, zoneID int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM delivery_zones WHERE id = ?;`, zoneID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetClientZone сохраняет зону доставки и стоимость на заказах пользователя.
+// zone == nil означает, что адрес вне всех зон.
+func (r *UserRepository) SetClientZone(ctx context.Context, userID int64, zone *domain.DeliveryZone) error {
+	const q = `
+		UPDATE client
+		SET zone_id = ?, delivery_fee = ?, out_of_zone = ?, updated_at = datetime('now')
+		WHERE id_user = ?;
+	`
+	var zoneID, fee interface{}
+	if zone != nil {
+		zoneID, fee = zone.ID, zone.Fee
+	}
+	_, err := r.db.ExecContext(ctx, q, zoneID, fee, zone == nil, userID)
+	return err
+}
+
+// ResetClientZone сбрасывает зону доставки заказов пользователя: пока адрес не отмечен
+// на карте, зона неизвестна
+func (r *UserRepository) ResetClientZone(ctx context.Context, userID int64) error {
+	const q = `
+		UPDATE client
+		SET zone_id = NULL, delivery_fee = NULL, out_of_zone = false, updated_at = datetime('now')
+		WHERE id_user = ?;
+	`
+	_, err := r.db.ExecContext(ctx, q, userID)
+	return err
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"meily/internal/domain"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//go:embed geodata/kz_streets.csv
+var streetsCSV string
+
+// smallTownRadiusKm is the radius of the towns whose names are skipped when they match a street
+const smallTownRadiusKm = 6
+
+// settlementAliases are other spellings of settlement names seen in typed addresses
+var settlementAliases = map[string][]string{
+	"Алматы":           {"алма ата", "almaty", "alma ata"},
+	"Астана":           {"нур султан", "нұр сұлтан", "nur sultan", "astana", "акмола", "целиноград"},
+	"Шымкент":          {"чимкент", "shymkent"},
+	"Караганда":        {"karaganda", "qaragandy"},
+	"Актобе":           {"актюбинск", "aktobe"},
+	"Усть-Каменогорск": {"усть каменогорск", "oskemen", "ust kamenogorsk"},
+	"Уральск":          {"uralsk", "oral"},
+	"Петропавловск":    {"petropavlovsk", "petropavl"},
+	"Семей":            {"семипалатинск", "semey"},
+	"Кызылорда":        {"kyzylorda"},
+	"Тараз":            {"джамбул", "taraz"},
+	"Туркестан":        {"turkistan", "turkestan"},
+	"Конаев":           {"капчагай", "қапшағай", "konaev", "kapchagay"},
+}
+
+type street struct {
+	city    string
+	name    string
+	aliases []string
+	lat     float64
+	lon     float64
+}
+
+type cityName struct {
+	settlement *Settlement
+	name       string
+}
+
+// AddressGeocoder finds coordinates for a typed address in the embedded gazetteer of
+// settlements and major streets. It only knows streets, not houses, so the best result
+// is the middle of the street.
+type AddressGeocoder struct {
+	cities  []cityName
+	streets []street
+}
+
+// NewAddressGeocoder loads the embedded gazetteer
+func NewAddressGeocoder() (*AddressGeocoder, error) {
+	list, err := Settlements()
+	if err != nil {
+		return nil, err
+	}
+	streets, err := parseStreets(streetsCSV)
+	if err != nil {
+		return nil, err
+	}
+
+	streetAliases := make(map[string]bool)
+	for _, s := range streets {
+		for _, a := range s.aliases {
+			streetAliases[a] = true
+		}
+	}
+
+	g := &AddressGeocoder{streets: streets}
+	for i := range list {
+		s := &list[i]
+		names := append([]string{s.Name, s.NameKK}, settlementAliases[s.Name]...)
+		for _, n := range names {
+			n = normalizeAddress(n)
+			// Towns named like a street (Абай, Аксай) would turn "Абай 10" into a town pin
+			if s.RadiusKm <= smallTownRadiusKm && streetAliases[n] {
+				continue
+			}
+			g.cities = append(g.cities, cityName{settlement: s, name: n})
+		}
+	}
+	return g, nil
+}
+
+func parseStreets(data string) ([]street, error) {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid streets data: %w", err)
+	}
+	if len(records) < 2 {
+		return nil, errors.New("streets data is empty")
+	}
+
+	result := make([]street, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		if len(rec) != 5 {
+			return nil, fmt.Errorf("streets line %d: expected 5 fields, got %d", i+2, len(rec))
+		}
+		s := street{city: rec[0], name: rec[1]}
+		for _, a := range strings.Split(rec[2], "|") {
+			if a = normalizeAddress(a); a != "" {
+				s.aliases = append(s.aliases, a)
+			}
+		}
+		for j, dst := range []*float64{&s.lat, &s.lon} {
+			v, err := strconv.ParseFloat(rec[3+j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("streets line %d: %w", i+2, err)
+			}
+			*dst = v
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// FindSettlement looks a settlement up by its Russian or Kazakh name or a common
+// alternative spelling, ignoring case and punctuation
+func FindSettlement(name string) (*Settlement, bool) {
+	list, err := Settlements()
+	if err != nil {
+		return nil, false
+	}
+	name = normalizeAddress(name)
+	if name == "" {
+		return nil, false
+	}
+	for i := range list {
+		s := &list[i]
+		for _, n := range append([]string{s.Name, s.NameKK}, settlementAliases[s.Name]...) {
+			if normalizeAddress(n) == name {
+				return s, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Geocode returns coordinates for an address with a confidence level:
+// high for a known street in a known city, medium for a street found in a single city
+// or the centre of a small town, low for the centre of a large city. With confidence
+// none the result has no coordinates.
+func (g *AddressGeocoder) Geocode(address string) domain.AddressGeocode {
+	text := " " + normalizeAddress(address) + " "
+
+	var city *Settlement
+	for _, c := range g.cities {
+		if !strings.Contains(text, " "+c.name+" ") {
+			continue
+		}
+		if city == nil || c.settlement.RadiusKm > city.RadiusKm {
+			city = c.settlement
+		}
+	}
+
+	var matched []*street
+	for i := range g.streets {
+		s := &g.streets[i]
+		if city != nil && s.city != city.Name {
+			continue
+		}
+		for _, a := range s.aliases {
+			if strings.Contains(text, " "+a+" ") {
+				matched = append(matched, s)
+				break
+			}
+		}
+	}
+
+	switch {
+	case city != nil && len(matched) > 0:
+		s := matched[0]
+		return domain.AddressGeocode{Lat: s.lat, Lon: s.lon, City: city.Name, Street: s.name, Confidence: domain.GeoConfidenceHigh}
+	case city == nil && len(matched) > 0:
+		cities := make(map[string]bool)
+		for _, s := range matched {
+			cities[s.city] = true
+		}
+		if len(cities) > 1 {
+			return domain.AddressGeocode{Confidence: domain.GeoConfidenceNone}
+		}
+		s := matched[0]
+		return domain.AddressGeocode{Lat: s.lat, Lon: s.lon, City: s.city, Street: s.name, Confidence: domain.GeoConfidenceMedium}
+	case city != nil:
+		return domain.AddressGeocode{Lat: city.Lat, Lon: city.Lon, City: city.Name, Confidence: domain.GeoConfidenceLow}
+	}
+	return domain.AddressGeocode{Confidence: domain.GeoConfidenceNone}
+}
+
+// normalizeAddress lowercases text, folds ё to е and replaces punctuation with single spaces
+func normalizeAddress(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
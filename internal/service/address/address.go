@@ -0,0 +1,307 @@
+// Package address normalizes, validates and renders structured Kazakhstan delivery addresses.
+package address
+
+import (
+	"fmt"
+	"meily/internal/domain"
+	"meily/internal/service"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Validation error codes returned per field
+const (
+	ErrRequired = "required"
+	ErrInvalid  = "invalid"
+	ErrTooLong  = "too_long"
+)
+
+// ValidationErrors maps a form field to its error code
+type ValidationErrors map[string]string
+
+func (e ValidationErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field, code := range e {
+		fields = append(fields, field+": "+code)
+	}
+	sort.Strings(fields)
+	return "invalid address: " + strings.Join(fields, ", ")
+}
+
+// streetTypes maps spellings of a street type, in Russian, Kazakh and Latin, to its abbreviation
+var streetTypes = map[string]string{
+	"ул": "ул.", "улица": "ул.", "көшесі": "ул.", "көш": "ул.", "koshesi": "ул.",
+	"ulitsa": "ул.", "ulica": "ул.", "ul": "ул.", "street": "ул.", "st": "ул.", "str": "ул.",
+	"пр": "пр.", "пр-т": "пр.", "просп": "пр.", "проспект": "пр.", "даңғылы": "пр.", "даң": "пр.",
+	"dangyly": "пр.", "prospekt": "пр.", "pr": "пр.", "avenue": "пр.", "ave": "пр.", "av": "пр.",
+	"мкр": "мкр", "мкрн": "мкр", "микрорайон": "мкр", "м-н": "мкр", "ш/а": "мкр",
+	"mkr": "мкр", "mikrorayon": "мкр", "microdistrict": "мкр",
+	"пер": "пер.", "переулок": "пер.", "pereulok": "пер.", "lane": "пер.",
+	"б-р": "б-р", "бульвар": "б-р", "bulvar": "б-р", "boulevard": "б-р", "blvd": "б-р",
+	"шоссе": "шоссе", "shosse": "шоссе", "highway": "шоссе",
+	"пл": "пл.", "площадь": "пл.", "алаңы": "пл.", "ploshchad": "пл.", "square": "пл.",
+	"наб": "наб.", "набережная": "наб.",
+	"тракт": "тракт",
+}
+
+// twoWordStreetTypes are Kazakh street types written as two words
+var twoWordStreetTypes = map[string]string{
+	"шағын аудан": "мкр",
+	"тас жолы":    "шоссе",
+	"тұйық көше":  "пер.",
+}
+
+// districtWords mark a city district in any language
+var districtWords = map[string]bool{
+	"р-н": true, "район": true, "ауданы": true, "аудан": true, "district": true, "rayon": true,
+}
+
+// homoglyphs are Latin letters that look like Cyrillic ones and end up in words typed
+// with a switched keyboard layout
+var homoglyphs = map[rune]rune{
+	'a': 'а', 'c': 'с', 'e': 'е', 'o': 'о', 'p': 'р', 'x': 'х', 'y': 'у', 'k': 'к',
+	'A': 'А', 'B': 'В', 'C': 'С', 'E': 'Е', 'H': 'Н', 'K': 'К', 'M': 'М', 'O': 'О',
+	'P': 'Р', 'T': 'Т', 'X': 'Х', 'Y': 'У',
+}
+
+var (
+	housePrefix     = regexp.MustCompile(`(?i)^(д\.?|дом|үй|uy|house|№)\s*`)
+	apartmentPrefix = regexp.MustCompile(`(?i)^(кв\.?|квартира|пәтер|apt\.?|flat)\s*`)
+	entrancePrefix  = regexp.MustCompile(`(?i)^(подъезд|под\.?|п\.|кіреберіс|entrance)\s*`)
+	floorPrefix     = regexp.MustCompile(`(?i)^(этаж|эт\.?|қабат|floor)\s*`)
+	houseLetter     = regexp.MustCompile(`^(\d+)\s+(\pL)$`)
+	houseNumber     = regexp.MustCompile(`^\d+[А-ЯӘҒҚҢӨҰҮҺІA-Z]?(/\d+[А-ЯӘҒҚҢӨҰҮҺІA-Z]?)?( (к|корп|стр)\.? ?\d+)?$`)
+	apartmentNumber = regexp.MustCompile(`^\d+[А-ЯӘҒҚҢӨҰҮҺІA-Z]?$`)
+)
+
+// FromForm reads the structured address fields of the Mini App form.
+// ok is false when the form has no structured address, as with older app versions.
+func FromForm(form url.Values) (domain.Address, bool) {
+	a := domain.Address{
+		Region:         form.Get("region"),
+		City:           form.Get("city"),
+		District:       form.Get("district"),
+		Street:         form.Get("street"),
+		House:          form.Get("house"),
+		Apartment:      form.Get("apartment"),
+		Entrance:       form.Get("entrance"),
+		Floor:          form.Get("floor"),
+		Intercom:       form.Get("intercom"),
+		CourierComment: form.Get("courier_comment"),
+	}
+	a.PrivateHouse, _ = strconv.ParseBool(form.Get("private_house"))
+	return a, a.City != "" || a.Street != "" || a.House != ""
+}
+
+// Normalize cleans up every field: spacing, keyboard layout mix-ups, street type and
+// district abbreviations, and the canonical city and region names
+func Normalize(a domain.Address) domain.Address {
+	a.Region = cleanText(a.Region)
+	a.City = cleanText(a.City)
+	a.District = normalizeDistrict(cleanText(a.District))
+	a.Street = normalizeStreet(cleanText(a.Street))
+	a.House = normalizeHouse(a.House)
+	a.Apartment = strings.ToUpper(apartmentPrefix.ReplaceAllString(cleanText(a.Apartment), ""))
+	a.Entrance = entrancePrefix.ReplaceAllString(cleanText(a.Entrance), "")
+	a.Floor = floorPrefix.ReplaceAllString(cleanText(a.Floor), "")
+	a.Intercom = cleanText(a.Intercom)
+	a.CourierComment = strings.TrimSpace(a.CourierComment)
+
+	if s, ok := service.FindSettlement(a.City); ok {
+		a.City = s.Name
+		if a.Region == "" {
+			a.Region = s.Region
+		}
+	}
+	if a.PrivateHouse {
+		a.Apartment, a.Entrance, a.Floor, a.Intercom = "", "", "", ""
+	}
+	return a
+}
+
+// Validate checks a normalized address. Apartment and entrance are required unless the
+// customer lives in a private house, since couriers cannot find the door without them.
+func Validate(a domain.Address) error {
+	errs := ValidationErrors{}
+	required := map[string]string{"city": a.City, "street": a.Street, "house": a.House}
+	if !a.PrivateHouse {
+		required["apartment"] = a.Apartment
+		required["entrance"] = a.Entrance
+	}
+	for field, value := range required {
+		if value == "" {
+			errs[field] = ErrRequired
+		}
+	}
+
+	limits := map[string]struct {
+		value string
+		max   int
+	}{
+		"region":          {a.Region, 100},
+		"city":            {a.City, 100},
+		"district":        {a.District, 100},
+		"street":          {a.Street, 150},
+		"house":           {a.House, 20},
+		"apartment":       {a.Apartment, 10},
+		"intercom":        {a.Intercom, 20},
+		"courier_comment": {a.CourierComment, 300},
+	}
+	for field, l := range limits {
+		if _, ok := errs[field]; !ok && len([]rune(l.value)) > l.max {
+			errs[field] = ErrTooLong
+		}
+	}
+
+	if _, ok := errs["house"]; !ok && a.House != "" && !houseNumber.MatchString(a.House) {
+		errs["house"] = ErrInvalid
+	}
+	if _, ok := errs["apartment"]; !ok && a.Apartment != "" && !apartmentNumber.MatchString(a.Apartment) {
+		errs["apartment"] = ErrInvalid
+	}
+	if _, ok := errs["entrance"]; !ok && a.Entrance != "" && !inRange(a.Entrance, 1, 99) {
+		errs["entrance"] = ErrInvalid
+	}
+	if a.Floor != "" && !inRange(a.Floor, -5, 200) {
+		errs["floor"] = ErrInvalid
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Render returns the single-line form stored in client.address and loto.address
+func Render(a domain.Address) string {
+	var parts []string
+	add := func(prefix, value string) {
+		if value != "" {
+			parts = append(parts, prefix+value)
+		}
+	}
+
+	if !strings.HasPrefix(a.Region, "г. ") {
+		add("", a.Region)
+	}
+	add("", a.City)
+	add("", a.District)
+	add("", a.Street)
+	add("д. ", a.House)
+	add("кв. ", a.Apartment)
+	add("подъезд ", a.Entrance)
+	add("этаж ", a.Floor)
+	add("домофон ", a.Intercom)
+
+	line := strings.Join(parts, ", ")
+	if a.CourierComment != "" {
+		line += fmt.Sprintf(" (%s)", a.CourierComment)
+	}
+	return line
+}
+
+// cleanText collapses whitespace and fixes words typed with a mixed Latin/Cyrillic layout
+func cleanText(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = fixLayout(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// fixLayout replaces Latin look-alike letters in a word that is otherwise Cyrillic
+func fixLayout(word string) string {
+	var cyrillic, latin bool
+	for _, r := range word {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic = true
+		case unicode.Is(unicode.Latin, r):
+			latin = true
+		}
+	}
+	if !cyrillic || !latin {
+		return word
+	}
+	return strings.Map(func(r rune) rune {
+		if c, ok := homoglyphs[r]; ok {
+			return c
+		}
+		return r
+	}, word)
+}
+
+// normalizeStreet puts the street type abbreviation in front of the name:
+// "Абай көшесі" and "ulitsa Abaya" become "ул. Абай" and "ул. Abaya"
+func normalizeStreet(street string) string {
+	street = strings.ReplaceAll(street, ".", ". ")
+	words := strings.Fields(street)
+	if len(words) == 0 {
+		return ""
+	}
+
+	streetType := ""
+	if len(words) > 2 {
+		if t, ok := twoWordStreetTypes[strings.ToLower(strings.Join(words[len(words)-2:], " "))]; ok {
+			streetType, words = t, words[:len(words)-2]
+		}
+	}
+	if streetType == "" && len(words) > 1 {
+		if t, ok := streetTypes[strings.ToLower(strings.TrimSuffix(words[0], "."))]; ok {
+			streetType, words = t, words[1:]
+		} else if t, ok := streetTypes[strings.ToLower(strings.TrimSuffix(words[len(words)-1], "."))]; ok {
+			streetType, words = t, words[:len(words)-1]
+		}
+	}
+
+	name := capitalize(strings.Join(words, " "))
+	if streetType == "" {
+		return name
+	}
+	return streetType + " " + name
+}
+
+// normalizeHouse drops the "д." prefix and joins the number with its letter: "д. 12 а" becomes "12А"
+func normalizeHouse(house string) string {
+	house = housePrefix.ReplaceAllString(cleanText(house), "")
+	house = strings.ReplaceAll(strings.ReplaceAll(house, " /", "/"), "/ ", "/")
+	house = houseLetter.ReplaceAllString(house, "$1$2")
+	return strings.ToUpper(house)
+}
+
+// normalizeDistrict writes a district as "<name> р-н"
+func normalizeDistrict(district string) string {
+	words := strings.Fields(strings.ReplaceAll(district, ".", " "))
+	name := make([]string, 0, len(words))
+	for _, w := range words {
+		if !districtWords[strings.ToLower(w)] {
+			name = append(name, w)
+		}
+	}
+	if len(name) == 0 {
+		return ""
+	}
+	if len(name) == len(words) {
+		return capitalize(strings.Join(name, " "))
+	}
+	return capitalize(strings.Join(name, " ")) + " р-н"
+}
+
+// capitalize upper-cases the first letter of a name typed in lower case
+func capitalize(s string) string {
+	if s == "" || strings.ToLower(s) != s {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func inRange(value string, min, max int) bool {
+	n, err := strconv.Atoi(value)
+	return err == nil && n >= min && n <= max
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"meily/internal/domain"
+	"meily/traits/helper"
+	"meily/traits/xlsx"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ExportColumn is one column of an order export: a header and the order field it shows
+type ExportColumn struct {
+	Header string `json:"header"`
+	Field  string `json:"field"`
+}
+
+// ExportPreset is a named set of columns agreed with a logistics partner
+type ExportPreset struct {
+	Delimiter string         `json:"delimiter,omitempty"`
+	Columns   []ExportColumn `json:"columns"`
+}
+
+// exportFields maps a column field to its value
+var exportFields = map[string]func(o domain.ExportOrder) string{
+	"order_id":        func(o domain.ExportOrder) string { return strconv.FormatInt(o.OrderID, 10) },
+	"user_id":         func(o domain.ExportOrder) string { return strconv.FormatInt(o.UserID, 10) },
+	"fio":             func(o domain.ExportOrder) string { return o.Fio },
+	"phone":           func(o domain.ExportOrder) string { return helper.NormalizePhone(o.Phone) },
+	"address":         func(o domain.ExportOrder) string { return o.Address },
+	"city":            func(o domain.ExportOrder) string { return o.City },
+	"latitude":        func(o domain.ExportOrder) string { return formatCoordinate(o.Latitude) },
+	"longitude":       func(o domain.ExportOrder) string { return formatCoordinate(o.Longitude) },
+	"coordinates":     coordinates,
+	"items":           func(o domain.ExportOrder) string { return strconv.Itoa(o.Items) },
+	"status":          func(o domain.ExportOrder) string { return o.Status },
+	"tracking_number": func(o domain.ExportOrder) string { return o.TrackingNumber },
+	"date_pay":        func(o domain.ExportOrder) string { return o.DatePay },
+	"customer_id":     customerID,
+	"google_maps":     mapLink("https://www.google.com/maps?q=%[1]f,%[2]f"),
+	"2gis":            mapLink("https://2gis.kz/geo/%[2]f,%[1]f"),
+	"yandex_maps":     mapLink("https://yandex.kz/maps/?pt=%[2]f,%[1]f&z=17"),
+}
+
+// DefaultExportPresets are used when no presets file is configured
+var DefaultExportPresets = map[string]ExportPreset{
+	"default": {
+		Columns: []ExportColumn{
+			{"№", "order_id"},
+			{"ФИО", "fio"},
+			{"Телефон", "phone"},
+			{"Адрес", "address"},
+			{"Город", "city"},
+			{"Широта", "latitude"},
+			{"Долгота", "longitude"},
+			{"Кол-во наборов", "items"},
+			{"Статус", "status"},
+			{"Трек-номер", "tracking_number"},
+			{"Дата оплаты", "date_pay"},
+			{"Покупатель", "customer_id"},
+			{"Google Maps", "google_maps"},
+			{"2GIS", "2gis"},
+			{"Яндекс Карты", "yandex_maps"},
+		},
+	},
+	"kazpost": {
+		Delimiter: ";",
+		Columns: []ExportColumn{
+			{"Номер отправления", "order_id"},
+			{"ФИО получателя", "fio"},
+			{"Телефон получателя", "phone"},
+			{"Населенный пункт", "city"},
+			{"Адрес получателя", "address"},
+			{"Количество вложений", "items"},
+		},
+	},
+	"courier": {
+		Columns: []ExportColumn{
+			{"Заказ", "order_id"},
+			{"Получатель", "fio"},
+			{"Телефон", "phone"},
+			{"Адрес", "address"},
+			{"Координаты", "coordinates"},
+			{"Кол-во", "items"},
+			{"2GIS", "2gis"},
+			{"Яндекс Карты", "yandex_maps"},
+		},
+	},
+}
+
+// LoadExportPresets reads presets from a JSON file of the form
+// {"name": {"delimiter": ";", "columns": [{"header": "ФИО", "field": "fio"}]}}
+// and adds them to the defaults, replacing presets with the same name.
+func LoadExportPresets(path string) (map[string]ExportPreset, error) {
+	presets := make(map[string]ExportPreset, len(DefaultExportPresets))
+	for name, p := range DefaultExportPresets {
+		presets[name] = p
+	}
+	if path == "" {
+		return presets, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var custom map[string]ExportPreset
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("invalid export presets: %w", err)
+	}
+	for name, p := range custom {
+		for _, c := range p.Columns {
+			if _, ok := exportFields[c.Field]; !ok {
+				return nil, fmt.Errorf("preset %q: unknown field %q", name, c.Field)
+			}
+		}
+		presets[name] = p
+	}
+	return presets, nil
+}
+
+// ExportRows turns orders into a header row followed by one row per order
+func ExportRows(orders []domain.ExportOrder, preset ExportPreset) [][]string {
+	rows := make([][]string, 0, len(orders)+1)
+
+	header := make([]string, len(preset.Columns))
+	for i, c := range preset.Columns {
+		header[i] = c.Header
+	}
+	rows = append(rows, header)
+
+	for _, o := range orders {
+		row := make([]string, len(preset.Columns))
+		for i, c := range preset.Columns {
+			if value, ok := exportFields[c.Field]; ok {
+				row[i] = value(o)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// WriteCSV writes rows as UTF-8 CSV with a BOM so Excel shows Cyrillic correctly
+func WriteCSV(w io.Writer, rows [][]string, delimiter string) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if delimiter != "" {
+		cw.Comma = []rune(delimiter)[0]
+	}
+	for _, row := range rows {
+		safe := make([]string, len(row))
+		for i, cell := range row {
+			safe[i] = csvCell(cell)
+		}
+		if err := cw.Write(safe); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell keeps a spreadsheet from running a cell as a formula: names and addresses
+// come from buyers, so a leading =, +, - or @ is escaped with an apostrophe. Phones in
+// E.164 and numbers such as negative coordinates are left as they are, so partner
+// imports still read them.
+func csvCell(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@", rune(cell[0])) || helper.IsE164(cell) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+// WriteXLSX writes rows as a single-sheet workbook
+func WriteXLSX(w io.Writer, sheetName string, rows [][]string) error {
+	return xlsx.Write(w, sheetName, rows)
+}
+
+func formatCoordinate(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', 6, 64)
+}
+
+func coordinates(o domain.ExportOrder) string {
+	if o.Latitude == nil || o.Longitude == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.6f, %.6f", *o.Latitude, *o.Longitude)
+}
+
+// customerID is empty for orders whose phone is not linked to a customer yet
+func customerID(o domain.ExportOrder) string {
+	if o.CustomerID == 0 {
+		return ""
+	}
+	return strconv.FormatInt(o.CustomerID, 10)
+}
+
+func mapLink(format string) func(o domain.ExportOrder) string {
+	return func(o domain.ExportOrder) string {
+		if o.Latitude == nil || o.Longitude == nil {
+			return ""
+		}
+		return fmt.Sprintf(format, *o.Latitude, *o.Longitude)
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"meily/internal/domain"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed geodata/kz_settlements.csv
+var settlementsCSV string
+
+// regionsGeoJSON holds simplified oblast boundaries: a few dozen points per oblast,
+// accurate to tens of kilometres, with neighbouring oblasts sharing their border points
+//
+//go:embed geodata/kz_regions.geojson
+var regionsGeoJSON []byte
+
+// maxSettlementDistanceKm is how far from the nearest known settlement a point outside
+// every oblast boundary may be and still be treated as inside Kazakhstan; it covers the
+// land the simplified boundaries cut off
+const maxSettlementDistanceKm = 250
+
+// Settlement is a city or town of the embedded gazetteer. Region is the
+// administrative unit of the settlement itself; Oblast is the oblast around it,
+// which differs only for the cities of republican significance.
+type Settlement struct {
+	Name     string
+	NameKK   string
+	Region   string
+	Oblast   string
+	Lat      float64
+	Lon      float64
+	RadiusKm float64
+}
+
+type regionBoundary struct {
+	name  string
+	shape *ZoneShape
+}
+
+// ReverseGeocoder resolves coordinates to a settlement and a region without network access.
+// Regions come from the oblast boundaries the point falls inside; outside all of them the
+// oblast of the nearest settlement is used.
+type ReverseGeocoder struct {
+	settlements []Settlement
+	boundaries  []regionBoundary
+}
+
+var (
+	settlementsOnce sync.Once
+	settlements     []Settlement
+	settlementsErr  error
+)
+
+// Settlements returns the embedded gazetteer of Kazakhstan settlements
+func Settlements() ([]Settlement, error) {
+	settlementsOnce.Do(func() {
+		settlements, settlementsErr = parseSettlements(settlementsCSV)
+	})
+	return settlements, settlementsErr
+}
+
+func parseSettlements(data string) ([]Settlement, error) {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid settlements data: %w", err)
+	}
+	if len(records) < 2 {
+		return nil, errors.New("settlements data is empty")
+	}
+
+	result := make([]Settlement, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		if len(rec) != 7 {
+			return nil, fmt.Errorf("settlements line %d: expected 7 fields, got %d", i+2, len(rec))
+		}
+		s := Settlement{Name: rec[0], NameKK: rec[1], Region: rec[2], Oblast: rec[3]}
+		for j, dst := range []*float64{&s.Lat, &s.Lon, &s.RadiusKm} {
+			v, err := strconv.ParseFloat(rec[4+j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("settlements line %d: %w", i+2, err)
+			}
+			*dst = v
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// NewReverseGeocoder loads the embedded settlements and oblast boundaries. If
+// boundariesPath is set, its GeoJSON FeatureCollection of regions, with the region name
+// in properties.name, replaces the embedded boundaries.
+func NewReverseGeocoder(boundariesPath string) (*ReverseGeocoder, error) {
+	list, err := Settlements()
+	if err != nil {
+		return nil, err
+	}
+	data := regionsGeoJSON
+	if boundariesPath != "" {
+		if data, err = os.ReadFile(boundariesPath); err != nil {
+			return nil, err
+		}
+	}
+	boundaries, err := parseRegionBoundaries(data)
+	if err != nil {
+		return nil, err
+	}
+	return &ReverseGeocoder{settlements: list, boundaries: boundaries}, nil
+}
+
+func parseRegionBoundaries(data []byte) ([]regionBoundary, error) {
+	var fc struct {
+		Features []struct {
+			Properties struct {
+				Name string `json:"name"`
+			} `json:"properties"`
+			Geometry json.RawMessage `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("invalid region boundaries: %w", err)
+	}
+	boundaries := make([]regionBoundary, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		if f.Properties.Name == "" {
+			return nil, fmt.Errorf("region boundary %d has no properties.name", i)
+		}
+		shape, err := ParseZoneShape(f.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("region %q: %w", f.Properties.Name, err)
+		}
+		boundaries = append(boundaries, regionBoundary{name: f.Properties.Name, shape: shape})
+	}
+	return boundaries, nil
+}
+
+// Resolve returns the settlement and region of a point. City is empty when the point
+// lies outside every settlement; ok is false for points outside Kazakhstan.
+func (g *ReverseGeocoder) Resolve(lat, lon float64) (domain.Place, bool) {
+	var nearest *Settlement
+	nearestKm := math.MaxFloat64
+	for i := range g.settlements {
+		s := &g.settlements[i]
+		if d := distanceKm(lat, lon, s.Lat, s.Lon); d < nearestKm {
+			nearest, nearestKm = s, d
+		}
+	}
+	region := ""
+	for _, b := range g.boundaries {
+		if b.shape.Contains(lat, lon) {
+			region = b.name
+			break
+		}
+	}
+	if nearest == nil || region == "" && nearestKm > maxSettlementDistanceKm {
+		return domain.Place{}, false
+	}
+
+	place := domain.Place{Region: region, DistanceKm: nearestKm}
+	if region == "" {
+		place.Region = nearest.Oblast
+	}
+	// Inside a settlement its own region wins: the cities of republican significance
+	// are regions of their own, and the gazetteer is more exact than the boundaries
+	if nearestKm <= nearest.RadiusKm {
+		place.City = nearest.Name
+		place.Region = nearest.Region
+	}
+	return place, true
+}
+
+// distanceKm is the haversine distance between two points
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"meily/internal/domain"
+)
+
+// DistanceFunc returns the distance in kilometers between two coordinates
+type DistanceFunc func(lat1, lon1, lat2, lon2 float64) float64
+
+// OptimizeRoute orders stops into an open path starting at the origin (the warehouse).
+// It builds a nearest-neighbour tour and then improves it with 2-opt until no
+// segment reversal shortens the path. Stop.Sequence, Stop.LegKm and the total
+// distance are filled in the result.
+func OptimizeRoute(originLat, originLon float64, stops []domain.RouteStop, dist DistanceFunc) ([]domain.RouteStop, float64) {
+	n := len(stops)
+	if n == 0 {
+		return nil, 0
+	}
+
+	// Point 0 is the origin, points 1..n are the stops
+	lat := make([]float64, n+1)
+	lon := make([]float64, n+1)
+	lat[0], lon[0] = originLat, originLon
+	for i, s := range stops {
+		lat[i+1], lon[i+1] = s.Latitude, s.Longitude
+	}
+
+	d := make([][]float64, n+1)
+	for i := range d {
+		d[i] = make([]float64, n+1)
+		for j := range d[i] {
+			if i != j {
+				d[i][j] = dist(lat[i], lon[i], lat[j], lon[j])
+			}
+		}
+	}
+
+	path := nearestNeighbour(d)
+	twoOpt(path, d)
+
+	result := make([]domain.RouteStop, 0, n)
+	var total float64
+	prev := 0
+	for seq, p := range path[1:] {
+		stop := stops[p-1]
+		stop.Sequence = seq + 1
+		stop.LegKm = d[prev][p]
+		total += stop.LegKm
+		result = append(result, stop)
+		prev = p
+	}
+
+	return result, total
+}
+
+// nearestNeighbour returns a path over all points that starts at point 0
+func nearestNeighbour(d [][]float64) []int {
+	n := len(d)
+	visited := make([]bool, n)
+	path := make([]int, 0, n)
+	path = append(path, 0)
+	visited[0] = true
+
+	current := 0
+	for len(path) < n {
+		next := -1
+		for j := 1; j < n; j++ {
+			if visited[j] {
+				continue
+			}
+			if next == -1 || d[current][j] < d[current][next] {
+				next = j
+			}
+		}
+		visited[next] = true
+		path = append(path, next)
+		current = next
+	}
+	return path
+}
+
+// twoOpt improves an open path in place. The first point (origin) stays fixed
+// and the path does not return to it, so the last edge has no successor.
+func twoOpt(path []int, d [][]float64) {
+	n := len(path)
+	if n < 3 {
+		return
+	}
+
+	const epsilon = 1e-9
+	improved := true
+	for improved {
+		improved = false
+		for i := 1; i < n-1; i++ {
+			for k := i + 1; k < n; k++ {
+				// Reversing path[i..k] replaces edges (i-1,i) and (k,k+1)
+				before := d[path[i-1]][path[i]]
+				after := d[path[i-1]][path[k]]
+				if k+1 < n {
+					before += d[path[k]][path[k+1]]
+					after += d[path[i]][path[k+1]]
+				}
+				if after+epsilon < before {
+					for l, r := i, k; l < r; l, r = l+1, r-1 {
+						path[l], path[r] = path[r], path[l]
+					}
+					improved = true
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode/utf8"
+)
+
+// Telegram limits: a photo or video caption is much shorter than a message
+const (
+	MaxMessageLength = 4096
+	MaxCaptionLength = 1024
+)
+
+// ErrUnknownTemplate is returned for a message that admins cannot edit
+var ErrUnknownTemplate = errors.New("unknown message template")
+
+// TemplateDef describes a customer-facing message that admins can edit. Sample holds
+// example values of the message arguments, used to validate and preview a template.
+type TemplateDef struct {
+	Key         string
+	Description string
+	Sample      map[string]interface{}
+	MaxLength   int
+}
+
+// Fields returns the template placeholders of the message, e.g. {{.Amount}}
+func (d TemplateDef) Fields() []string {
+	fields := make([]string, 0, len(d.Sample))
+	for name := range d.Sample {
+		fields = append(fields, "{{."+TemplateField(name)+"}}")
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// TemplateField turns a message argument name into its template field: "count" is
+// {{.Count}}, "user_id" is {{.UserID}}
+func TemplateField(arg string) string {
+	parts := strings.Split(arg, "_")
+	for i, p := range parts {
+		if p == "id" {
+			parts[i] = "ID"
+		} else if p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func templateData(args map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(args))
+	for name, value := range args {
+		data[TemplateField(name)] = value
+	}
+	return data
+}
+
+// MessageTemplates keeps the compiled templates admins saved over the built-in texts
+type MessageTemplates struct {
+	defs map[string]TemplateDef
+	keys []string
+
+	mu       sync.RWMutex
+	compiled map[string]*template.Template // key + "/" + language
+}
+
+// NewMessageTemplates creates the store for the given editable messages
+func NewMessageTemplates(defs []TemplateDef) *MessageTemplates {
+	t := &MessageTemplates{
+		defs:     make(map[string]TemplateDef, len(defs)),
+		compiled: make(map[string]*template.Template),
+	}
+	for _, d := range defs {
+		if d.MaxLength == 0 {
+			d.MaxLength = MaxMessageLength
+		}
+		t.defs[d.Key] = d
+		t.keys = append(t.keys, d.Key)
+	}
+	return t
+}
+
+// Defs returns the editable messages in declaration order
+func (t *MessageTemplates) Defs() []TemplateDef {
+	defs := make([]TemplateDef, 0, len(t.keys))
+	for _, key := range t.keys {
+		defs = append(defs, t.defs[key])
+	}
+	return defs
+}
+
+// Def returns the editable message with the key
+func (t *MessageTemplates) Def(key string) (TemplateDef, bool) {
+	d, ok := t.defs[key]
+	return d, ok
+}
+
+// Compile parses the template and renders it with the sample values. A template that
+// refers to an unknown field, renders empty or exceeds the Telegram limit is rejected.
+func (t *MessageTemplates) Compile(key, body string) (*template.Template, error) {
+	def, ok := t.defs[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, key)
+	}
+	tpl, err := template.New(key).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	text, err := execute(tpl, def.Sample)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("template renders an empty message")
+	}
+	if n := utf8.RuneCountInString(text); n > def.MaxLength {
+		return nil, fmt.Errorf("message is %d characters long, Telegram allows %d", n, def.MaxLength)
+	}
+	return tpl, nil
+}
+
+// Preview renders the template with the sample values
+func (t *MessageTemplates) Preview(key, body string) (string, error) {
+	tpl, err := t.Compile(key, body)
+	if err != nil {
+		return "", err
+	}
+	return execute(tpl, t.defs[key].Sample)
+}
+
+// Set compiles the template and makes it replace the built-in text
+func (t *MessageTemplates) Set(key, lang, body string) error {
+	tpl, err := t.Compile(key, body)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.compiled[key+"/"+lang] = tpl
+	t.mu.Unlock()
+	return nil
+}
+
+// Remove brings back the built-in text
+func (t *MessageTemplates) Remove(key, lang string) {
+	t.mu.Lock()
+	delete(t.compiled, key+"/"+lang)
+	t.mu.Unlock()
+}
+
+// Render renders the admin's template for the message. ok is false when the built-in
+// text should be used.
+func (t *MessageTemplates) Render(key, lang string, args map[string]interface{}) (text string, ok bool, err error) {
+	if t == nil {
+		return "", false, nil
+	}
+	t.mu.RLock()
+	tpl := t.compiled[key+"/"+lang]
+	t.mu.RUnlock()
+	if tpl == nil {
+		return "", false, nil
+	}
+	text, err = execute(tpl, args)
+	if err != nil {
+		return "", false, err
+	}
+	return text, true, nil
+}
+
+func execute(tpl *template.Template, args map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	if err := tpl.Execute(&sb, templateData(args)); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
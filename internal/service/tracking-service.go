@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"meily/internal/domain"
+	"regexp"
+	"strings"
+)
+
+// kazpostNumber matches international postal (S10) numbers such as RR123456789KZ
+var kazpostNumber = regexp.MustCompile(`^[A-Z]{2}\d{9}[A-Z]{2}$`)
+
+// cdekNumber matches numeric CDEK waybill numbers
+var cdekNumber = regexp.MustCompile(`^\d{8,12}$`)
+
+// trackingHeaders are first-row values that mark a header line rather than data
+var trackingHeaders = map[string]bool{
+	"order_id": true, "order": true, "id": true, "phone": true,
+	"заказ": true, "номер заказа": true, "телефон": true, "тапсырыс": true,
+}
+
+// NormalizeCarrier maps a carrier name from the file to a known carrier, or "" when it is not recognized
+func NormalizeCarrier(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "kazpost", "казпочта", "қазпошта", "post", "почта":
+		return domain.CarrierKazpost
+	case "cdek", "сдэк", "сдек":
+		return domain.CarrierCDEK
+	}
+	return ""
+}
+
+// DetectCarrier guesses the carrier from the tracking number format
+func DetectCarrier(trackingNumber string) string {
+	switch {
+	case kazpostNumber.MatchString(trackingNumber):
+		return domain.CarrierKazpost
+	case cdekNumber.MatchString(trackingNumber):
+		return domain.CarrierCDEK
+	}
+	return ""
+}
+
+// ParseTrackingCSV reads rows of "order ID or phone, tracking number[, carrier]".
+// The delimiter may be a comma or a semicolon, and a header line is skipped.
+// Rows that cannot be read are returned as failures with the reason.
+func ParseTrackingCSV(r io.Reader) ([]domain.TrackingImportRow, []domain.TrackingImportFailure, error) {
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\ufeff")) {
+		if _, err := br.Discard(3); err != nil {
+			return nil, nil, err
+		}
+	}
+	head, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, err
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	firstLine, _, _ := bytes.Cut(head, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		cr.Comma = ';'
+	}
+
+	var rows []domain.TrackingImportRow
+	var failed []domain.TrackingImportFailure
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if len(record) == 0 || (len(record) == 1 && record[0] == "") {
+			continue
+		}
+		if line == 1 && trackingHeaders[strings.ToLower(record[0])] {
+			continue
+		}
+
+		row := domain.TrackingImportRow{Line: line, Key: record[0]}
+		if len(record) > 1 {
+			row.TrackingNumber = strings.ToUpper(strings.ReplaceAll(record[1], " ", ""))
+		}
+		if len(record) > 2 && record[2] != "" {
+			row.Carrier = NormalizeCarrier(record[2])
+			if row.Carrier == "" {
+				failed = append(failed, domain.TrackingImportFailure{TrackingImportRow: row, Reason: "unknown carrier " + record[2]})
+				continue
+			}
+		} else {
+			row.Carrier = DetectCarrier(row.TrackingNumber)
+		}
+
+		switch {
+		case row.Key == "":
+			failed = append(failed, domain.TrackingImportFailure{TrackingImportRow: row, Reason: "empty order ID or phone"})
+		case row.TrackingNumber == "":
+			failed = append(failed, domain.TrackingImportFailure{TrackingImportRow: row, Reason: "empty tracking number"})
+		default:
+			rows = append(rows, row)
+		}
+	}
+	return rows, failed, nil
+}
+
+// TrackingReportRows turns failed lines into a header row followed by one row per failure
+func TrackingReportRows(failed []domain.TrackingImportFailure) [][]string {
+	rows := make([][]string, 0, len(failed)+1)
+	rows = append(rows, []string{"Строка", "Заказ / телефон", "Трек-номер", "Перевозчик", "Причина"})
+	for _, f := range failed {
+		rows = append(rows, []string{fmt.Sprint(f.Line), f.Key, f.TrackingNumber, f.Carrier, f.Reason})
+	}
+	return rows
+}
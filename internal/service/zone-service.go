@@ -0,0 +1,145 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"meily/internal/domain"
+)
+
+// ring is a closed polygon ring of [lon, lat] points as in GeoJSON
+type ring [][2]float64
+
+// polygon is an outer ring followed by optional holes
+type polygon []ring
+
+// ZoneShape is a parsed delivery zone geometry
+type ZoneShape struct {
+	polygons []polygon
+}
+
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    json.RawMessage `json:"geometry"`
+	Features    []geoJSONObject `json:"features"`
+}
+
+// ParseZoneShape parses a GeoJSON Polygon or MultiPolygon. A Feature or a
+// FeatureCollection wrapping polygons is accepted as well.
+func ParseZoneShape(raw []byte) (*ZoneShape, error) {
+	var obj geoJSONObject
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("invalid geojson: %w", err)
+	}
+
+	shape := &ZoneShape{}
+	if err := shape.add(obj); err != nil {
+		return nil, err
+	}
+	if len(shape.polygons) == 0 {
+		return nil, errors.New("geojson contains no polygons")
+	}
+	return shape, nil
+}
+
+func (s *ZoneShape) add(obj geoJSONObject) error {
+	switch obj.Type {
+	case "Polygon":
+		var p polygon
+		if err := json.Unmarshal(obj.Coordinates, &p); err != nil {
+			return fmt.Errorf("invalid polygon coordinates: %w", err)
+		}
+		return s.addPolygon(p)
+	case "MultiPolygon":
+		var ps []polygon
+		if err := json.Unmarshal(obj.Coordinates, &ps); err != nil {
+			return fmt.Errorf("invalid multipolygon coordinates: %w", err)
+		}
+		for _, p := range ps {
+			if err := s.addPolygon(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Feature":
+		var geometry geoJSONObject
+		if err := json.Unmarshal(obj.Geometry, &geometry); err != nil {
+			return fmt.Errorf("invalid feature geometry: %w", err)
+		}
+		return s.add(geometry)
+	case "FeatureCollection":
+		for _, f := range obj.Features {
+			if err := s.add(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported geojson type %q", obj.Type)
+	}
+}
+
+func (s *ZoneShape) addPolygon(p polygon) error {
+	if len(p) == 0 {
+		return errors.New("polygon has no rings")
+	}
+	for _, r := range p {
+		if len(r) < 4 {
+			return errors.New("polygon ring needs at least 4 points")
+		}
+	}
+	s.polygons = append(s.polygons, p)
+	return nil
+}
+
+// Contains reports whether the point lies inside any polygon of the shape and outside its holes
+func (s *ZoneShape) Contains(lat, lon float64) bool {
+	for _, p := range s.polygons {
+		if !p[0].contains(lat, lon) {
+			continue
+		}
+		inHole := false
+		for _, hole := range p[1:] {
+			if hole.contains(lat, lon) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// contains is the even-odd ray casting test
+func (r ring) contains(lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		xi, yi := r[i][0], r[i][1]
+		xj, yj := r[j][0], r[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// FindZone returns the first enabled zone containing the point, or nil.
+// Zones with an unparsable geometry are skipped.
+func FindZone(zones []domain.DeliveryZone, lat, lon float64) *domain.DeliveryZone {
+	for i := range zones {
+		if !zones[i].Enabled {
+			continue
+		}
+		shape, err := ParseZoneShape(zones[i].GeoJSON)
+		if err != nil {
+			continue
+		}
+		if shape.Contains(lat, lon) {
+			return &zones[i]
+		}
+	}
+	return nil
+}
@@ -19,6 +19,21 @@ func CreateTables(db *sql.DB) error {
 		{"geo", createGeoTable},
 		{"bot_sessions", createBotSessionsTable},
 		{"admin_logs", createAdminLogsTable},
+		{"checkout_reminders", createCheckoutRemindersTable},
+		{"couriers", createCouriersTable},
+		{"delivery_zones", createDeliveryZonesTable},
+		{"delivery_proofs", createDeliveryProofsTable},
+		{"customers", createCustomersTable},
+		{"customer_accounts", createCustomerAccountsTable},
+		{"user_settings", createUserSettingsTable},
+		{"message_templates", createMessageTemplatesTable},
+		{"media_assets", createMediaAssetsTable},
+		{"payments", createPaymentsTable},
+		{"support_tickets", createSupportTicketsTable},
+		{"support_messages", createSupportMessagesTable},
+		{"admins", createAdminsTable},
+		{"broadcast_jobs", createBroadcastJobsTable},
+		{"broadcast_recipients", createBroadcastRecipientsTable},
 	}
 
 	for _, table := range tables {
@@ -28,6 +43,11 @@ func CreateTables(db *sql.DB) error {
 		}
 	}
 
+	// Добавляем новые колонки в уже существующие таблицы
+	if err := migrateColumns(db); err != nil {
+		return fmt.Errorf("migrate columns: %w", err)
+	}
+
 	// Создаем индексы после создания всех таблиц
 	if err := createIndexes(db); err != nil {
 		return fmt.Errorf("create indexes: %w", err)
@@ -169,6 +189,356 @@ func createAdminLogsTable(db *sql.DB) error {
 	return err
 }
 
+func createCheckoutRemindersTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS checkout_reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		id_user BIGINT NOT NULL UNIQUE,
+		count INT NOT NULL DEFAULT 0,
+		amount INT NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		reminders_sent INT NOT NULL DEFAULT 0,
+		opted_out BOOLEAN DEFAULT FALSE,
+		started_at DATETIME NOT NULL,
+		last_reminded_at DATETIME NULL,
+		converted_at DATETIME NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createCouriersTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS couriers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		id_user BIGINT NOT NULL UNIQUE,
+		name VARCHAR(255) NOT NULL,
+		phone VARCHAR(50) NULL,
+		city VARCHAR(100) NULL,
+		active BOOLEAN DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createDeliveryZonesTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS delivery_zones (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(255) NOT NULL UNIQUE,
+		geojson TEXT NOT NULL,
+		fee INT NOT NULL DEFAULT 0,
+		delivery_days INT NOT NULL DEFAULT 1,
+		enabled BOOLEAN DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createDeliveryProofsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS delivery_proofs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id INTEGER NOT NULL UNIQUE,
+		courier_id INTEGER NOT NULL,
+		code VARCHAR(10) NOT NULL,
+		code_sent_at DATETIME NOT NULL,
+		verified_by VARCHAR(10) NULL,
+		verified_at DATETIME NULL,
+		photo_file_id TEXT NULL,
+		latitude REAL NULL,
+		longitude REAL NULL,
+		delivered_at DATETIME NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createCustomersTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS customers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		phone VARCHAR(20) NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createCustomerAccountsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS customer_accounts (
+		id_user BIGINT PRIMARY KEY,
+		customer_id INTEGER NOT NULL,
+		phone VARCHAR(20) NOT NULL,
+		linked_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createUserSettingsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS user_settings (
+		id_user BIGINT PRIMARY KEY,
+		language VARCHAR(5) NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createMessageTemplatesTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS message_templates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		template_key VARCHAR(64) NOT NULL,
+		language VARCHAR(5) NOT NULL,
+		body TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		created_by BIGINT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		reverted_at DATETIME,
+		UNIQUE(template_key, language, version)
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createMediaAssetsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS media_assets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(32) NOT NULL,
+		kind VARCHAR(16) NOT NULL,
+		file_id TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		created_by BIGINT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		deleted_at DATETIME,
+		UNIQUE(name, version)
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createPaymentsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS payments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		id_user BIGINT NOT NULL,
+		amount INT NOT NULL,
+		sets INT NOT NULL,
+		source VARCHAR(64) NOT NULL DEFAULT '',
+		paid_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createSupportTicketsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS support_tickets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		id_user BIGINT NOT NULL,
+		status VARCHAR(10) NOT NULL DEFAULT 'open',
+		last_user_message_at DATETIME NULL,
+		closed_at DATETIME NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createSupportMessagesTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS support_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ticket_id INTEGER NOT NULL,
+		direction VARCHAR(3) NOT NULL,
+		chat_id BIGINT NOT NULL,
+		message_id INTEGER NOT NULL,
+		from_id BIGINT NOT NULL,
+		kind VARCHAR(20) NOT NULL,
+		text TEXT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (ticket_id) REFERENCES support_tickets(id)
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createAdminsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS admins (
+		id_user BIGINT PRIMARY KEY,
+		name VARCHAR(100) NOT NULL DEFAULT '',
+		role VARCHAR(16) NOT NULL,
+		added_by BIGINT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createBroadcastJobsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS broadcast_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		admin_id BIGINT NOT NULL,
+		audience TEXT NOT NULL,
+		kind VARCHAR(16) NOT NULL,
+		file_id TEXT NULL,
+		text TEXT NULL,
+		status VARCHAR(10) NOT NULL DEFAULT 'running',
+		status_chat_id BIGINT NULL,
+		status_message_id INTEGER NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		finished_at DATETIME NULL
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+func createBroadcastRecipientsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS broadcast_recipients (
+		job_id INTEGER NOT NULL,
+		id_user BIGINT NOT NULL,
+		status VARCHAR(10) NOT NULL DEFAULT 'pending',
+		error_code INTEGER NULL,
+		error TEXT NULL,
+		sent_at DATETIME NULL,
+		PRIMARY KEY (job_id, id_user),
+		FOREIGN KEY (job_id) REFERENCES broadcast_jobs(id)
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// migrateColumns добавляет колонки, появившиеся после первого запуска.
+// CREATE TABLE IF NOT EXISTS не меняет существующие таблицы, поэтому
+// каждая колонка проверяется через PRAGMA table_info.
+func migrateColumns(db *sql.DB) error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		// Статус заказа и назначенный курьер
+		{"client", "status", "VARCHAR(30) NOT NULL DEFAULT 'new'"},
+		{"client", "courier_id", "INTEGER NULL"},
+		{"client", "assigned_at", "DATETIME NULL"},
+		{"client", "status_updated_at", "DATETIME NULL"},
+
+		// Зона доставки, определённая по координатам
+		{"client", "zone_id", "INTEGER NULL"},
+		{"client", "delivery_fee", "INT NULL"},
+		{"client", "out_of_zone", "BOOLEAN DEFAULT FALSE"},
+
+		// Трек-номер отправления
+		{"client", "tracking_number", "VARCHAR(100) NULL"},
+		{"client", "carrier", "VARCHAR(30) NULL"},
+
+		// Область, определённая по координатам
+		{"geo", "region", "VARCHAR(100) NULL"},
+
+		// Точность координат заказа: gps, manual, high, medium, low, none
+		{"client", "geo_confidence", "VARCHAR(10) NULL"},
+
+		// Структурированный адрес (JSON); client.address хранит его однострочную форму
+		{"client", "address_details", "TEXT NULL"},
+
+		// Способ подтверждения телефона покупателя: telegram или sms
+		{"client", "phone_verified_by", "VARCHAR(10) NULL"},
+
+		// Источник перехода из /start: первый и последний, последний копируется в заказ
+		{"just", "source", "VARCHAR(64) NOT NULL DEFAULT ''"},
+		{"just", "last_source", "VARCHAR(64) NOT NULL DEFAULT ''"},
+		{"client", "source", "VARCHAR(64) NOT NULL DEFAULT ''"},
+
+		// Неверные попытки ввода кода доставки; после лимита подтверждение заблокировано
+		{"delivery_proofs", "failed_attempts", "INT NOT NULL DEFAULT 0"},
+
+		// Число оплаченных наборов в заказе; 0 у заказов, сохранённых до появления колонки
+		{"client", "sets", "INT NOT NULL DEFAULT 0"},
+
+		// Идентификатор оплаты (QR чека или платёж Telegram): одна оплата — одна выдача билетов
+		{"payments", "charge_id", "TEXT NULL"},
+
+		// Неудачные попытки отправить рассылку получателю, когда Telegram был недоступен
+		{"broadcast_recipients", "attempts", "INT NOT NULL DEFAULT 0"},
+	}
+
+	for _, c := range columns {
+		exists, err := columnExists(db, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", c.table, c.column, err)
+		}
+		log.Printf("Added column %s.%s", c.table, c.column)
+	}
+
+	return nil
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 func createIndexes(db *sql.DB) error {
 	indexes := []string{
 		// Индексы для таблицы just
@@ -191,6 +561,7 @@ func createIndexes(db *sql.DB) error {
 		"CREATE INDEX IF NOT EXISTS idx_geo_user_id ON geo(id_user)",
 		"CREATE INDEX IF NOT EXISTS idx_geo_coordinates ON geo(latitude, longitude)",
 		"CREATE INDEX IF NOT EXISTS idx_geo_city ON geo(city)",
+		"CREATE INDEX IF NOT EXISTS idx_geo_region ON geo(region)",
 		"CREATE INDEX IF NOT EXISTS idx_geo_date ON geo(dataReg)",
 
 		// Индексы для таблицы bot_sessions
@@ -204,6 +575,49 @@ func createIndexes(db *sql.DB) error {
 		"CREATE INDEX IF NOT EXISTS idx_admin_logs_action ON admin_logs(action)",
 		"CREATE INDEX IF NOT EXISTS idx_admin_logs_target_user ON admin_logs(target_user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_admin_logs_created_at ON admin_logs(created_at)",
+
+		// Индексы для таблицы checkout_reminders
+		"CREATE INDEX IF NOT EXISTS idx_checkout_reminders_status ON checkout_reminders(status)",
+		"CREATE INDEX IF NOT EXISTS idx_checkout_reminders_started_at ON checkout_reminders(started_at)",
+
+		// Индексы для заказов и курьеров
+		"CREATE INDEX IF NOT EXISTS idx_client_status ON client(status)",
+		"CREATE INDEX IF NOT EXISTS idx_client_courier ON client(courier_id)",
+		"CREATE INDEX IF NOT EXISTS idx_couriers_user_id ON couriers(id_user)",
+		"CREATE INDEX IF NOT EXISTS idx_couriers_city ON couriers(city)",
+
+		// Индексы для зон доставки
+		"CREATE INDEX IF NOT EXISTS idx_delivery_zones_enabled ON delivery_zones(enabled)",
+		"CREATE INDEX IF NOT EXISTS idx_client_zone ON client(zone_id)",
+		"CREATE INDEX IF NOT EXISTS idx_client_geo_confidence ON client(geo_confidence)",
+
+		// Индексы для шаблонов сообщений
+		"CREATE INDEX IF NOT EXISTS idx_message_templates_key ON message_templates(template_key, language)",
+
+		// Индексы для отчёта по источникам
+		"CREATE INDEX IF NOT EXISTS idx_just_source ON just(source)",
+		"CREATE INDEX IF NOT EXISTS idx_payments_user ON payments(id_user)",
+		"CREATE INDEX IF NOT EXISTS idx_payments_source ON payments(source)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_charge ON payments(charge_id)",
+
+		// Индексы для обращений в поддержку
+		"CREATE INDEX IF NOT EXISTS idx_support_tickets_user ON support_tickets(id_user, status)",
+		"CREATE INDEX IF NOT EXISTS idx_support_messages_chat ON support_messages(chat_id, message_id)",
+
+		// Индексы для рассылок
+		"CREATE INDEX IF NOT EXISTS idx_broadcast_jobs_status ON broadcast_jobs(status)",
+		"CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_status ON broadcast_recipients(job_id, status)",
+
+		// Индексы для медиатеки
+		"CREATE INDEX IF NOT EXISTS idx_media_assets_name ON media_assets(name)",
+
+		// Индексы для подтверждений доставки
+		"CREATE INDEX IF NOT EXISTS idx_delivery_proofs_courier ON delivery_proofs(courier_id)",
+		"CREATE INDEX IF NOT EXISTS idx_delivery_proofs_delivered_at ON delivery_proofs(delivered_at)",
+
+		// Индексы для покупателей
+		"CREATE INDEX IF NOT EXISTS idx_customer_accounts_customer ON customer_accounts(customer_id)",
+		"CREATE INDEX IF NOT EXISTS idx_loto_contact ON loto(contact)",
 	}
 
 	for _, indexStmt := range indexes {
@@ -1,6 +1,9 @@
 package helper
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Helper function to format price with thousand separators
 func FormatPrice(price int) string {
@@ -18,3 +21,50 @@ func FormatPrice(price int) string {
 	}
 	return string(result)
 }
+
+// NormalizePhone brings a phone number to E.164. Kazakhstan numbers written with 8,
+// 7 or without a prefix become +7XXXXXXXXXX; other numbers keep their country code,
+// since Telegram sends them without the plus. Numbers it does not recognize are
+// returned trimmed as is.
+func NormalizePhone(raw string) string {
+	raw = strings.TrimSpace(raw)
+	digits := make([]rune, 0, len(raw))
+	for _, r := range raw {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	if !strings.HasPrefix(raw, "+") && len(digits) > 2 && digits[0] == '0' && digits[1] == '0' {
+		digits = digits[2:]
+		raw = "+" + string(digits)
+	}
+
+	switch {
+	case len(digits) == 11 && digits[0] == '8' && digits[1] == '7':
+		// "+8 7xx" is a Kazakhstan mobile typed with the trunk prefix
+		return "+7" + string(digits[1:])
+	case strings.HasPrefix(raw, "+") && len(digits) >= 8 && len(digits) <= 15:
+		return "+" + string(digits)
+	case len(digits) == 11 && (digits[0] == '8' || digits[0] == '7'):
+		return "+7" + string(digits[1:])
+	case len(digits) == 10 && digits[0] == '7':
+		return "+7" + string(digits)
+	case len(digits) >= 11 && len(digits) <= 15 && digits[0] != '0':
+		return "+" + string(digits)
+	default:
+		return raw
+	}
+}
+
+// IsE164 reports whether a phone is already normalized
+func IsE164(phone string) bool {
+	if len(phone) < 9 || len(phone) > 16 || phone[0] != '+' || phone[1] == '0' {
+		return false
+	}
+	for _, r := range phone[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}